@@ -2,682 +2,8495 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type Note struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Content     string    `json:"content"`
-	Tags        []string  `json:"tags"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Type        string    `json:"type"` // "text" or "screenshot"
-	FilePath    string    `json:"file_path,omitempty"`
-	Screenshot  string    `json:"screenshot,omitempty"`
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	Content     string       `json:"content"`
+	Tags        []string     `json:"tags"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Type        string       `json:"type"` // "text" or "screenshot"
+	FilePath    string       `json:"file_path,omitempty"`
+	Screenshot  string       `json:"screenshot,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Order       int          `json:"order,omitempty"` // manual position, from 1; 0 when never placed
+	PinnedAt    *time.Time   `json:"pinned_at,omitempty"`
+	Priority    *int         `json:"priority,omitempty"` // higher comes first; nil when unset
+	MirrorPath  string       `json:"mirror_path,omitempty"` // kept up to date with the scroll as Markdown
+	OCRText     string       `json:"ocr_text,omitempty"`    // text read from a captured image
+	DueAt       *time.Time   `json:"due_at,omitempty"`
+	Notified    bool         `json:"notified,omitempty"` // set once the reminder for DueAt has fired
+	Region      string       `json:"region,omitempty"`   // area of the screen captured, as WxH+X+Y, when known
+	Read        bool         `json:"read,omitempty"`     // set once the scroll has been viewed or marked read
+	SearchBoost int          `json:"search_boost,omitempty"` // added to the scroll's rank in search results
+}
+
+// Attachment is a file of any kind kept alongside a scroll. File is relative
+// to the notebook's directory so the archives can be moved as a whole.
+type Attachment struct {
+	Name     string    `json:"name"`
+	File     string    `json:"file"`
+	MIMEType string    `json:"mime_type"`
+	Size     int64     `json:"size"`
+	AddedAt  time.Time `json:"added_at"`
 }
 
 type NotesApp struct {
 	Notes      []Note `json:"notes"`
 	NextID     int    `json:"next_id"`
-	NotesDir   string
-	ConfigFile string
+	NotesDir   string `json:"-"`
+	ConfigFile string `json:"-"`
+	Settings   Settings `json:"-"`
+	
+	// store reads and writes the scrolls in the format chosen in settings;
+	// saved is a snapshot of the scrolls as last loaded or saved.
+	store Store
+	saved []byte
+	
+	// Notebook names the archive in use; the default notebook lives directly
+	// in the base notes directory.
+	Notebook string `json:"-"`
+	
+	// LastResults holds the scroll IDs of the most recent search, in the
+	// order they were shown, so that view-result can follow up on them.
+	LastResults []int `json:"-"`
+	
+	// Output is the default format for list and search, chosen by --output.
+	Output string `json:"-"`
+	
+	// Recent holds recently viewed scroll IDs, newest first; recentPos is how
+	// far back the seeker has stepped with the back command.
+	Recent    []int `json:"-"`
+	recentPos int
+	
+	// ExitCode records the first failure of a command, for scripts that run
+	// single commands from the command line.
+	ExitCode int `json:"-"`
+	
+	// screenshotTool caches the capture tool found by detectScreenshotTool.
+	screenshotTool string
+	
+	// SettingSources records where each overridable setting came from.
+	SettingSources map[string]string `json:"-"`
+	
+	// colors is set when listings may be decorated with terminal colors.
+	colors bool
+	
+	// previewLines is how many lines of content the table shows under each
+	// scroll; 0 gives the usual single flattened preview.
+	previewLines int
+	
+	// locks holds the scrolls open in an editor; editorDone receives their
+	// content when the editor closes.
+	locks      map[int]string
+	editorDone chan editorResult
+	
+	// Invalid holds a description of each scroll that failed validation
+	// when the archives were loaded.
+	Invalid []string `json:"-"`
+	
+	// searchCache holds the results of the latest searches, newest first;
+	// generation counts loads and saves, so that a change to any scroll
+	// leaves the cached results behind.
+	searchCache []searchCacheEntry
+	generation  int
+	
+	// menu is set while the interactive menu runs. Its commands share the
+	// menu's input, so none may read that input to its end.
+	menu bool
 }
 
-func NewNotesApp() *NotesApp {
-	homeDir, _ := os.UserHomeDir()
-	notesDir := filepath.Join(homeDir, "ancient-scrolls")
-	configFile := filepath.Join(notesDir, "scrolls.json")
+// Exit codes returned by commands given on the command line.
+const (
+	ExitOK       = 0
+	ExitNotFound = 2
+	ExitIOError  = 3
+	ExitInvalid  = 4
 	
-	// Create notes directory if it doesn't exist
-	os.MkdirAll(notesDir, 0755)
-	os.MkdirAll(filepath.Join(notesDir, "screenshots"), 0755)
+	// ExitInterrupted follows the shell convention of 128 plus SIGINT.
+	ExitInterrupted = 130
+)
+
+// exitOnInterrupt ends the program cleanly on Ctrl+C or SIGTERM. It waits
+// for any save in progress to finish and keeps later saves from starting.
+func exitOnInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 	
-	app := &NotesApp{
-		Notes:      []Note{},
-		NextID:     1,
-		NotesDir:   notesDir,
-		ConfigFile: configFile,
+	go func() {
+		<-signals
+		saveMu.Lock()
+		fmt.Println("\nThe archives are sealed. Farewell!")
+		os.Exit(ExitInterrupted)
+	}()
+}
+
+// fail reports a problem on stderr and records the exit code for it, keeping
+// the first failure when a command meets several.
+func (app *NotesApp) fail(code int, format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	if app.ExitCode == ExitOK {
+		app.ExitCode = code
 	}
+}
+
+// Settings holds the seeker's preferences, chosen by the first-run wizard and
+// kept outside the archives so that the archives themselves can be relocated.
+type Settings struct {
+	NotesDir       string `json:"notes_dir"`
+	Editor         string `json:"editor"`
+	ScreenshotTool string `json:"screenshot_tool"` // empty means the platform default
+	DateFormat     string `json:"date_format"`
+	DailyWordGoal  int    `json:"daily_word_goal,omitempty"`
+	RawPreviews    bool   `json:"raw_previews,omitempty"`   // show previews without stripping markdown
+	Pager          string `json:"pager,omitempty"`          // command for long output; "none" turns paging off
+	TagDelimiter   string `json:"tag_delimiter,omitempty"`  // separates typed runes: comma (the default), space or semicolon
+	ListTitleWidth int    `json:"list_title_width"`         // cut longer titles in the list table; 0 shows them whole
 	
-	app.LoadNotes()
-	return app
+	// TagStyles decorate listed scrolls by rune on a terminal, as
+	// "rune=color icon" entries such as "urgent=red ⚠". The first entry
+	// matching one of a scroll's runes wins.
+	TagStyles []string `json:"tag_styles,omitempty"`
+	Storage        string `json:"storage,omitempty"`        // "json" (the default) or "markdown"
+	ImagesDir      string `json:"images_dir,omitempty"`     // where captured images go, if not beside the scrolls
+	CompactJSON    bool   `json:"compact_json,omitempty"`   // write scrolls.json without indentation
+	CompressStore  bool   `json:"compress_store,omitempty"` // gzip scrolls.json to scrolls.json.gz
+	
+	// AutoBackupOnStart copies the notebook into backups/ when the archives
+	// are opened, keeping the newest BackupsToKeep copies (default 5).
+	AutoBackupOnStart bool `json:"auto_backup_on_start,omitempty"`
+	BackupsToKeep     int  `json:"backups_to_keep,omitempty"`
+	
+	// StrictDeleteConfirm makes erase ask for the scroll's title to be
+	// retyped rather than a simple yes.
+	StrictDeleteConfirm bool `json:"strict_delete_confirm,omitempty"`
+	
+	// KeepTombstones records each erased scroll's ID in tombstones.json, so
+	// that other copies of the notebook can learn of the erasure.
+	KeepTombstones bool `json:"keep_tombstones,omitempty"`
+	
+	// MaxPinned limits how many scrolls may be pinned (0 for no limit).
+	// PinOverflow says what pinning past it does: "refuse" (the default) or
+	// "evict", which offers to unpin the oldest pin.
+	MaxPinned   int    `json:"max_pinned,omitempty"`
+	PinOverflow string `json:"pin_overflow,omitempty"`
 }
 
-func (app *NotesApp) LoadNotes() {
-	if _, err := os.Stat(app.ConfigFile); os.IsNotExist(err) {
-		return
+// Build information, injected at build time with
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=abc123 -X main.buildDate=2024-01-01"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("The Ancient Scrolls %s (commit %s, built %s, %s)", version, commit, buildDate, runtime.Version())
+}
+
+// stdin is shared by every prompt so that buffered input is never lost
+// between readers.
+var stdin = bufio.NewReader(os.Stdin)
+
+func defaultSettings() Settings {
+	homeDir, _ := os.UserHomeDir()
+	
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "nano"
+		}
 	}
 	
-	data, err := ioutil.ReadFile(app.ConfigFile)
+	return Settings{
+		NotesDir:       filepath.Join(homeDir, "ancient-scrolls"),
+		Editor:         editor,
+		DateFormat:     "2006-01-02 15:04",
+		ListTitleWidth: 40,
+	}
+}
+
+func settingsPath() string {
+	configDir, err := os.UserConfigDir()
 	if err != nil {
-		fmt.Printf("Error loading notes: %v\n", err)
-		return
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, ".ancient-scrolls.json")
 	}
+	return filepath.Join(configDir, "ancient-scrolls", "settings.json")
+}
+
+// LoadSettings reads the settings file, filling any missing values with the
+// defaults. The boolean result reports whether a settings file was found.
+func LoadSettings(path string) (Settings, bool) {
+	settings := defaultSettings()
 	
-	if err := json.Unmarshal(data, app); err != nil {
-		fmt.Printf("Error parsing notes: %v\n", err)
-		return
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return settings, false
 	}
+	
+	if err := json.Unmarshal(data, &settings); err != nil {
+		fmt.Printf("Error parsing settings: %v\n", err)
+		return defaultSettings(), true
+	}
+	
+	defaults := defaultSettings()
+	if settings.NotesDir == "" {
+		settings.NotesDir = defaults.NotesDir
+	}
+	if settings.Editor == "" {
+		settings.Editor = defaults.Editor
+	}
+	if settings.DateFormat == "" {
+		settings.DateFormat = defaults.DateFormat
+	}
+	return settings, true
 }
 
-func (app *NotesApp) SaveNotes() {
-	data, err := json.MarshalIndent(app, "", "  ")
+func SaveSettings(path string, settings Settings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
 	if err != nil {
-		fmt.Printf("Error marshaling notes: %v\n", err)
-		return
+		return err
 	}
 	
-	if err := ioutil.WriteFile(app.ConfigFile, data, 0644); err != nil {
-		fmt.Printf("Error saving notes: %v\n", err)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	return ioutil.WriteFile(path, data, 0644)
 }
 
-func (app *NotesApp) CreateTextNote(title, content string, tags []string) {
-	note := Note{
-		ID:        app.NextID,
-		Title:     title,
-		Content:   content,
-		Tags:      tags,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Type:      "text",
+// settingOverride describes a setting that may be overridden, in rising
+// order of precedence, by an environment variable and a command-line flag.
+type settingOverride struct {
+	Flag  string
+	Env   string
+	Usage string
+	Value func(*Settings) *string
+}
+
+var settingOverrides = []settingOverride{
+	{"notes-dir", "SKELOS_NOTES_DIR", "directory holding the archives", func(s *Settings) *string { return &s.NotesDir }},
+	{"screenshot-tool", "SKELOS_SCREENSHOT_TOOL", "screenshot tool to capture images with", func(s *Settings) *string { return &s.ScreenshotTool }},
+	{"editor", "SKELOS_EDITOR", "preferred editor", func(s *Settings) *string { return &s.Editor }},
+	{"date-format", "SKELOS_DATE_FORMAT", "Go layout used to show dates", func(s *Settings) *string { return &s.DateFormat }},
+	{"storage", "SKELOS_STORAGE", "how scrolls are stored: json or markdown", func(s *Settings) *string { return &s.Storage }},
+}
+
+// applyOverrides layers environment variables and then the given flag values
+// over settings, returning where each overridable setting came from.
+func applyOverrides(settings *Settings, base string, flags map[string]string) map[string]string {
+	sources := make(map[string]string)
+	for _, o := range settingOverrides {
+		sources[o.Flag] = base
+		if value, ok := os.LookupEnv(o.Env); ok {
+			*o.Value(settings) = value
+			sources[o.Flag] = "environment " + o.Env
+		}
+		if value, ok := flags[o.Flag]; ok {
+			*o.Value(settings) = value
+			sources[o.Flag] = "flag --" + o.Flag
+		}
+	}
+	return sources
+}
+
+var dateFormatChoices = []string{
+	"2006-01-02 15:04",
+	"01/02/2006 3:04 PM",
+	"02/01/2006 15:04",
+	"Jan 2, 2006 15:04",
+}
+
+// RunSetupWizard walks a new seeker through the essential settings, offering
+// the current values as defaults.
+func RunSetupWizard(reader *bufio.Reader, current Settings) Settings {
+	settings := current
+	
+	ask := func(prompt, current string) string {
+		fmt.Printf("%s [%s]: ", prompt, current)
+		answer, _ := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return current
+		}
+		return answer
 	}
 	
-	app.Notes = append(app.Notes, note)
-	app.NextID++
-	app.SaveNotes()
+	fmt.Println("\n=== Preparing the Archives ===")
+	fmt.Println("Answer a few questions to set up The Ancient Scrolls (press Enter to accept the default).")
 	
-	fmt.Printf("Created scroll #%d: %s\n", note.ID, note.Title)
+	settings.NotesDir = ask("Where shall the archives be kept?", settings.NotesDir)
+	settings.Editor = ask("Which editor do you prefer?", settings.Editor)
+	
+	tool := settings.ScreenshotTool
+	if tool == "" {
+		tool = "default"
+	}
+	fmt.Printf("Screenshot tools: %s\n", strings.Join(screenshotTools(), ", "))
+	tool = ask("Which screenshot tool shall capture images?", tool)
+	if tool == "default" {
+		tool = ""
+	}
+	settings.ScreenshotTool = tool
+	
+	fmt.Println("Date formats:")
+	for i, layout := range dateFormatChoices {
+		fmt.Printf("  %d) %s\n", i+1, layout)
+	}
+	layout := ask("Choose a date format (number or Go layout)", settings.DateFormat)
+	if n, err := strconv.Atoi(layout); err == nil && n >= 1 && n <= len(dateFormatChoices) {
+		layout = dateFormatChoices[n-1]
+	}
+	settings.DateFormat = layout
+	
+	return settings
 }
 
-func (app *NotesApp) TakeScreenshot(title string, tags []string) {
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("scroll_capture_%s_%d.png", timestamp, app.NextID)
-	screenshotPath := filepath.Join(app.NotesDir, "screenshots", filename)
-	
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		cmd = exec.Command("screencapture", "-i", screenshotPath)
-	case "linux":
-		cmd = exec.Command("gnome-screenshot", "-a", "-f", screenshotPath)
-	case "windows":
-		// For Windows, we'll use a PowerShell command
-		psScript := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; $Screen = [System.Windows.Forms.SystemInformation]::VirtualScreen; $Width = $Screen.Width; $Height = $Screen.Height; $Left = $Screen.Left; $Top = $Screen.Top; $bitmap = New-Object System.Drawing.Bitmap $Width, $Height; $graphic = [System.Drawing.Graphics]::FromImage($bitmap); $graphic.CopyFromScreen($Left, $Top, 0, 0, $bitmap.Size); $bitmap.Save('%s'); $graphic.Dispose(); $bitmap.Dispose()`, screenshotPath)
-		cmd = exec.Command("powershell", "-Command", psScript)
-	default:
-		fmt.Println("Screenshot feature not supported on this platform")
+// DefaultNotebook is the notebook kept directly in the base notes directory.
+const DefaultNotebook = "default"
+
+func validNotebookName(name string) error {
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid notebook name %q", name)
+	}
+	return nil
+}
+
+// notebookDir returns the directory holding the named notebook's store.
+func notebookDir(baseDir, notebook string) string {
+	if notebook == "" || notebook == DefaultNotebook {
+		return baseDir
+	}
+	return filepath.Join(baseDir, "notebooks", notebook)
+}
+
+// notebookNames lists the default notebook followed by every named one.
+func notebookNames(baseDir string) []string {
+	names := []string{DefaultNotebook}
+	entries, err := ioutil.ReadDir(filepath.Join(baseDir, "notebooks"))
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func (app *NotesApp) ListNotebooks() {
+	fmt.Println("\n=== Notebooks of the Archives ===")
+	for _, name := range notebookNames(app.Settings.NotesDir) {
+		notes, _, err := newStore(app.Settings, notebookDir(app.Settings.NotesDir, name)).Load()
+		
+		marker := " "
+		if name == app.Notebook {
+			marker = "*"
+		}
+		if err != nil {
+			fmt.Printf("%s %s (unreadable: %v)\n", marker, name, err)
+			continue
+		}
+		fmt.Printf("%s %s (%d scrolls)\n", marker, name, len(notes))
+	}
+}
+
+// MoveNote relocates a scroll, and its captured image, into another notebook
+// where it receives a fresh ID.
+func (app *NotesApp) MoveNote(id int, notebook string) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
 		return
 	}
-	
-	fmt.Println("Capturing ancient knowledge... (follow system prompts)")
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error taking screenshot: %v\n", err)
+	if err := validNotebookName(notebook); err != nil {
+		app.fail(ExitInvalid, "Error: %v\n", err)
 		return
 	}
-	
-	// Check if screenshot file was created
-	if _, err := os.Stat(screenshotPath); os.IsNotExist(err) {
-		fmt.Println("Knowledge capture cancelled or failed")
+	if notebook == app.Notebook {
+		fmt.Printf("Scroll #%d already rests in the %s notebook.\n", id, notebook)
 		return
 	}
 	
-	note := Note{
-		ID:         app.NextID,
-		Title:      title,
-		Tags:       tags,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-		Type:       "screenshot",
-		FilePath:   screenshotPath,
-		Screenshot: filename,
+	target := NewNotesApp(app.Settings, notebook)
+	note := app.Notes[i]
+	
+	if note.Type == "screenshot" && note.FilePath != "" {
+		newPath := filepath.Join(target.imagesDir(), note.Screenshot)
+		if _, err := os.Stat(newPath); err == nil {
+			app.fail(ExitIOError, "Error moving scroll: %s already exists in the %s notebook\n", note.Screenshot, notebook)
+			return
+		}
+		if err := moveFile(note.FilePath, newPath); err != nil {
+			app.fail(ExitIOError, "Error moving captured image: %v\n", err)
+			return
+		}
+		note.FilePath = newPath
 	}
 	
-	app.Notes = append(app.Notes, note)
-	app.NextID++
+	note.ID = target.NextID
+	note.Order = 0
+	for n, a := range note.Attachments {
+		rel := filepath.Join("attachments", strconv.Itoa(note.ID), a.Name)
+		newPath := filepath.Join(target.NotesDir, rel)
+		err := os.MkdirAll(filepath.Dir(newPath), 0755)
+		if err == nil {
+			err = moveFile(app.attachmentPath(a), newPath)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Could not move attachment %s: %v\n", a.Name, err)
+			continue
+		}
+		note.Attachments[n].File = rel
+	}
+	target.Notes = append(target.Notes, note)
+	target.NextID++
+	target.SaveNotes()
+	
+	app.Notes = append(app.Notes[:i], app.Notes[i+1:]...)
 	app.SaveNotes()
 	
-	fmt.Printf("Scroll captured and saved as scroll #%d: %s\n", note.ID, note.Title)
+	fmt.Printf("Scroll #%d has been moved to the %s notebook as scroll #%d.\n", id, notebook, note.ID)
 }
 
-func (app *NotesApp) ListNotes() {
-	if len(app.Notes) == 0 {
-		fmt.Println("No scrolls found in the archives.")
-		return
+// RenameNotebook renames a notebook's directory and repoints its captured
+// images, which are recorded by full path. The default notebook holds the
+// others, so it cannot be renamed.
+func (app *NotesApp) RenameNotebook(oldName, newName string) {
+	for _, name := range []string{oldName, newName} {
+		if err := validNotebookName(name); err != nil {
+			app.fail(ExitInvalid, "Error: %v\n", err)
+			return
+		}
+		if name == DefaultNotebook {
+			app.fail(ExitInvalid, "The %s notebook cannot be renamed, nor can another take its name.\n", DefaultNotebook)
+			return
+		}
 	}
 	
-	// Sort notes by creation time (newest first)
-	sort.Slice(app.Notes, func(i, j int) bool {
-		return app.Notes[i].CreatedAt.After(app.Notes[j].CreatedAt)
-	})
+	oldDir := notebookDir(app.Settings.NotesDir, oldName)
+	newDir := notebookDir(app.Settings.NotesDir, newName)
+	if info, err := os.Stat(oldDir); err != nil || !info.IsDir() {
+		app.fail(ExitNotFound, "No notebook named %s exists.\n", oldName)
+		return
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		app.fail(ExitInvalid, "A notebook named %s already exists.\n", newName)
+		return
+	}
 	
-	fmt.Println("\n=== The Ancient Scrolls ===")
-	for _, note := range app.Notes {
-		fmt.Printf("\n[%d] %s (%s)\n", note.ID, note.Title, note.Type)
-		fmt.Printf("Created: %s\n", note.CreatedAt.Format("2006-01-02 15:04"))
-		if len(note.Tags) > 0 {
-			fmt.Printf("Tags: %s\n", strings.Join(note.Tags, ", "))
+	if err := os.Rename(oldDir, newDir); err != nil {
+		app.fail(ExitIOError, "Error renaming notebook: %v\n", err)
+		return
+	}
+	moved := map[string]string{oldDir: newDir}
+	if app.Settings.ImagesDir != "" {
+		oldImages := notebookDir(app.Settings.ImagesDir, oldName)
+		newImages := notebookDir(app.Settings.ImagesDir, newName)
+		if err := os.Rename(oldImages, newImages); err == nil {
+			moved[oldImages] = newImages
+		} else if !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not rename the notebook's image directory: %v\n", err)
 		}
-		if note.Type == "text" {
-			preview := note.Content
-			if len(preview) > 100 {
-				preview = preview[:100] + "..."
+	}
+	
+	renamed := app
+	if app.Notebook != oldName {
+		renamed = NewNotesApp(app.Settings, newName)
+	} else {
+		app.Notebook = newName
+		app.NotesDir = newDir
+		app.ConfigFile = filepath.Join(newDir, "scrolls.json")
+		app.store = newStore(app.Settings, newDir)
+	}
+	
+	repointed := false
+	for from, to := range moved {
+		prefix := from + string(filepath.Separator)
+		for i, note := range renamed.Notes {
+			if strings.HasPrefix(note.FilePath, prefix) {
+				renamed.Notes[i].FilePath = filepath.Join(to, strings.TrimPrefix(note.FilePath, prefix))
+				repointed = true
 			}
-			fmt.Printf("Preview: %s\n", preview)
-		} else {
-			fmt.Printf("Captured Image: %s\n", note.Screenshot)
 		}
-		fmt.Println(strings.Repeat("-", 40))
 	}
+	if repointed {
+		renamed.SaveNotes()
+	}
+	
+	fmt.Printf("The %s notebook is now called %s.\n", oldName, newName)
+}
+
+// imagesDir is where the notebook's captured images are kept: screenshots/
+// beside the scrolls, unless the settings name another root for them.
+func (app *NotesApp) imagesDir() string {
+	if app.Settings.ImagesDir != "" {
+		return notebookDir(app.Settings.ImagesDir, app.Notebook)
+	}
+	return filepath.Join(app.NotesDir, "screenshots")
 }
 
-func (app *NotesApp) ViewNote(id int) {
-	for _, note := range app.Notes {
-		if note.ID == id {
-			fmt.Printf("\n=== Ancient Scroll #%d ===\n", note.ID)
-			fmt.Printf("Title: %s\n", note.Title)
-			fmt.Printf("Type: %s\n", note.Type)
-			fmt.Printf("Created: %s\n", note.CreatedAt.Format("2006-01-02 15:04:05"))
-			fmt.Printf("Updated: %s\n", note.UpdatedAt.Format("2006-01-02 15:04:05"))
-			
-			if len(note.Tags) > 0 {
-				fmt.Printf("Tags: %s\n", strings.Join(note.Tags, ", "))
+// RelocateImages moves the captured images of every notebook under a new
+// root, repoints their scrolls and records the root in the settings, so
+// that images may live on another disk than the scrolls.
+func (app *NotesApp) RelocateImages(root string) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		app.fail(ExitInvalid, "Error: %v\n", err)
+		return
+	}
+	
+	moved, failed := 0, 0
+	for _, name := range notebookNames(app.Settings.NotesDir) {
+		book := app
+		if name != app.Notebook {
+			book = NewNotesApp(app.Settings, name)
+		}
+		dir := notebookDir(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			app.fail(ExitIOError, "Error creating %s: %v\n", dir, err)
+			return
+		}
+		
+		changed := false
+		for i, note := range book.Notes {
+			if note.Type != "screenshot" || note.FilePath == "" {
+				continue
 			}
-			
-			if note.Type == "text" {
-				fmt.Printf("\nContent:\n%s\n", note.Content)
-			} else {
-				fmt.Printf("\nCaptured Image: %s\n", note.Screenshot)
-				fmt.Printf("File path: %s\n", note.FilePath)
-				
-				// Try to open the screenshot
-				fmt.Print("Would you like to reveal this captured image? (y/n): ")
-				reader := bufio.NewReader(os.Stdin)
-				response, _ := reader.ReadString('\n')
-				response = strings.TrimSpace(strings.ToLower(response))
-				
-				if response == "y" || response == "yes" {
-					app.openFile(note.FilePath)
-				}
+			base := note.Screenshot
+			if base == "" {
+				base = filepath.Base(note.FilePath)
 			}
-			return
+			newPath := filepath.Join(dir, base)
+			if newPath == note.FilePath {
+				continue
+			}
+			if _, err := os.Stat(newPath); err == nil {
+				fmt.Printf("Warning: %s already exists; the image of scroll #%d was left where it is.\n", newPath, note.ID)
+				failed++
+				continue
+			}
+			if err := moveFile(note.FilePath, newPath); err != nil {
+				fmt.Printf("Warning: Could not move the image of scroll #%d: %v\n", note.ID, err)
+				failed++
+				continue
+			}
+			book.Notes[i].FilePath = newPath
+			changed = true
+			moved++
 		}
+		if changed {
+			book.SaveNotes()
+		}
+	}
+	
+	fileSettings, _ := LoadSettings(settingsPath())
+	fileSettings.ImagesDir = root
+	if err := SaveSettings(settingsPath(), fileSettings); err != nil {
+		app.fail(ExitIOError, "Error saving settings: %v\n", err)
+		return
+	}
+	app.Settings.ImagesDir = root
+	
+	fmt.Printf("Moved %d captured images to %s.\n", moved, root)
+	if failed > 0 {
+		app.fail(ExitIOError, "%d images could not be moved.\n", failed)
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
 }
 
-func (app *NotesApp) openFile(filePath string) {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = exec.Command("open", filePath)
-	case "linux":
-		cmd = exec.Command("xdg-open", filePath)
-	case "windows":
-		cmd = exec.Command("cmd", "/c", "start", filePath)
+// moveFile renames src to dst, falling back to copy and remove when the two
+// live on different filesystems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
 	}
 	
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
+	if err := copyFile(src, dst); err != nil {
+		return err
 	}
+	return os.Remove(src)
 }
 
-func (app *NotesApp) SearchNotes(query string) {
-	query = strings.ToLower(query)
-	var matches []Note
-	
-	for _, note := range app.Notes {
-		// Search in title, content, and tags
-		if strings.Contains(strings.ToLower(note.Title), query) ||
-		   strings.Contains(strings.ToLower(note.Content), query) ||
-		   app.containsTag(note.Tags, query) {
-			matches = append(matches, note)
-		}
+// openForRead opens a file whose bytes are to be read, such as a captured
+// image. It is a variable so that tests can see which files are read.
+var openForRead = os.Open
+
+// copyFile copies src to dst, creating dst's directory if need be.
+func copyFile(src, dst string) error {
+	f, err := openForRead(src)
+	if err != nil {
+		return err
 	}
-	
-	if len(matches) == 0 {
-		fmt.Printf("No scrolls found containing '%s' in the archives\n", query)
-		return
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
 	}
-	
-	fmt.Printf("\n=== Ancient Knowledge Found: '%s' ===\n", query)
-	for _, note := range matches {
-		fmt.Printf("\n[%d] %s (%s)\n", note.ID, note.Title, note.Type)
-		fmt.Printf("Created: %s\n", note.CreatedAt.Format("2006-01-02 15:04"))
-		if len(note.Tags) > 0 {
-			fmt.Printf("Tags: %s\n", strings.Join(note.Tags, ", "))
-		}
-		if note.Type == "text" {
-			preview := note.Content
-			if len(preview) > 100 {
-				preview = preview[:100] + "..."
-			}
-			fmt.Printf("Preview: %s\n", preview)
-		}
-		fmt.Println(strings.Repeat("-", 40))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
 	}
+	return ioutil.WriteFile(dst, data, 0644)
 }
 
-func (app *NotesApp) containsTag(tags []string, query string) bool {
-	for _, tag := range tags {
-		if strings.Contains(strings.ToLower(tag), query) {
-			return true
-		}
+// writeFileAtomic writes data to a temporary file beside path and renames
+// it into place, so that a crash mid-write leaves the old file whole.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
 	}
-	return false
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
-func (app *NotesApp) EditScroll(id int) {
-	for i, note := range app.Notes {
-		if note.ID == id {
-			reader := bufio.NewReader(os.Stdin)
-			
-			fmt.Printf("\n=== Modifying Ancient Scroll #%d ===\n", note.ID)
-			fmt.Printf("Current Title: %s\n", note.Title)
-			fmt.Printf("Type: %s\n", note.Type)
-			
-			if note.Type == "text" {
-				// Edit text scroll
-				fmt.Print("Enter new title (press Enter to keep current): ")
-				newTitle, _ := reader.ReadString('\n')
-				newTitle = strings.TrimSpace(newTitle)
-				if newTitle != "" {
-					app.Notes[i].Title = newTitle
-				}
-				
-				fmt.Printf("Current content:\n%s\n\n", note.Content)
-				fmt.Print("Enter new content (press Enter to keep current): ")
-				newContent, _ := reader.ReadString('\n')
-				newContent = strings.TrimSpace(newContent)
-				if newContent != "" {
-					app.Notes[i].Content = newContent
-				}
-			} else {
-				// Edit image scroll title only
-				fmt.Print("Enter new title (press Enter to keep current): ")
-				newTitle, _ := reader.ReadString('\n')
-				newTitle = strings.TrimSpace(newTitle)
-				if newTitle != "" {
-					app.Notes[i].Title = newTitle
-				}
-			}
-			
-			// Edit tags for both types
-			if len(note.Tags) > 0 {
-				fmt.Printf("Current runes (tags): %s\n", strings.Join(note.Tags, ", "))
-			} else {
-				fmt.Println("Current runes (tags): none")
-			}
-			fmt.Print("Enter new runes (comma-separated, press Enter to keep current): ")
-			newTagsInput, _ := reader.ReadString('\n')
-			newTagsInput = strings.TrimSpace(newTagsInput)
-			
-			if newTagsInput != "" {
-				var newTags []string
-				if newTagsInput != "" {
-					newTags = strings.Split(newTagsInput, ",")
-					for j, tag := range newTags {
-						newTags[j] = strings.TrimSpace(tag)
-					}
-				}
-				app.Notes[i].Tags = newTags
-			}
-			
-			app.Notes[i].UpdatedAt = time.Now()
-			app.SaveNotes()
-			fmt.Printf("Scroll #%d has been modified in the archives.\n", id)
-			return
-		}
+func NewNotesApp(settings Settings, notebook string) *NotesApp {
+	if notebook == "" {
+		notebook = DefaultNotebook
+	}
+	notesDir := notebookDir(settings.NotesDir, notebook)
+	configFile := filepath.Join(notesDir, "scrolls.json")
+	
+	// Create notes directory if it doesn't exist
+	os.MkdirAll(notesDir, 0755)
+	
+	app := &NotesApp{
+		Notes:      []Note{},
+		NextID:     1,
+		NotesDir:   notesDir,
+		ConfigFile: configFile,
+		Settings:   settings,
+		store:      newStore(settings, notesDir),
+		Notebook:   notebook,
+		Output:     OutputTable,
+		locks:      make(map[int]string),
+		editorDone: make(chan editorResult),
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
+	
+	os.MkdirAll(app.imagesDir(), 0755)
+	
+	app.colors = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	app.LoadNotes()
+	app.loadRecent()
+	app.detectScreenshotTool()
+	return app
 }
 
-func (app *NotesApp) RetitleScroll(id int) {
-	for i, note := range app.Notes {
-		if note.ID == id {
-			reader := bufio.NewReader(os.Stdin)
-			
-			fmt.Printf("Current title: %s\n", note.Title)
-			fmt.Print("Enter new title: ")
-			newTitle, _ := reader.ReadString('\n')
-			newTitle = strings.TrimSpace(newTitle)
-			
-			if newTitle != "" {
-				app.Notes[i].Title = newTitle
-				app.Notes[i].UpdatedAt = time.Now()
-				app.SaveNotes()
-				fmt.Printf("Scroll #%d has been retitled to: %s\n", id, newTitle)
-			} else {
-				fmt.Println("Title unchanged.")
-			}
-			return
+func (app *NotesApp) LoadNotes() {
+	notes, nextID, err := app.store.Load()
+	if err != nil {
+		app.fail(ExitIOError, "Error loading notes: %v\n", err)
+		return
+	}
+	if notes != nil {
+		app.Notes = notes
+	}
+	if nextID > 0 {
+		app.NextID = nextID
+	}
+	app.saved = app.snapshot()
+	app.generation++
+	
+	app.Invalid = nil
+	seen := make(map[int]bool)
+	for n, note := range app.Notes {
+		err := validateNote(note)
+		if err == nil && seen[note.ID] {
+			err = errors.New("another scroll has the same ID")
+		}
+		seen[note.ID] = true
+		if err != nil {
+			problem := fmt.Sprintf("%s: scroll #%d (entry %d): %v", app.store.Location(), note.ID, n+1, err)
+			app.Invalid = append(app.Invalid, problem)
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
 		}
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
 }
 
-func (app *NotesApp) RetagScroll(id int) {
-	for i, note := range app.Notes {
-		if note.ID == id {
-			reader := bufio.NewReader(os.Stdin)
-			
-			if len(note.Tags) > 0 {
-				fmt.Printf("Current runes (tags): %s\n", strings.Join(note.Tags, ", "))
-			} else {
-				fmt.Println("Current runes (tags): none")
-			}
-			
-			fmt.Print("Enter new runes (comma-separated, leave empty to remove all): ")
-			newTagsInput, _ := reader.ReadString('\n')
-			newTagsInput = strings.TrimSpace(newTagsInput)
-			
-			var newTags []string
-			if newTagsInput != "" {
-				newTags = strings.Split(newTagsInput, ",")
-				for j, tag := range newTags {
-					newTags[j] = strings.TrimSpace(tag)
-				}
-			}
-			
-			app.Notes[i].Tags = newTags
-			app.Notes[i].UpdatedAt = time.Now()
-			app.SaveNotes()
-			
-			if len(newTags) > 0 {
-				fmt.Printf("Scroll #%d runes updated to: %s\n", id, strings.Join(newTags, ", "))
-			} else {
-				fmt.Printf("All runes removed from scroll #%d\n", id)
-			}
-			return
+// validateNote checks a loaded scroll for the damage a hand edit can do.
+func validateNote(note Note) error {
+	switch {
+	case note.ID <= 0:
+		return fmt.Errorf("ID %d is not positive", note.ID)
+	case strings.TrimSpace(note.Title) == "":
+		return errors.New("the title is empty")
+	case note.Type != "text" && note.Type != "screenshot":
+		return fmt.Errorf("unknown type %q (expected text or screenshot)", note.Type)
+	case note.CreatedAt.IsZero():
+		return errors.New("created_at is missing")
+	case note.UpdatedAt.IsZero():
+		return errors.New("updated_at is missing")
+	case note.UpdatedAt.Before(note.CreatedAt):
+		return errors.New("updated_at is earlier than created_at")
+	case note.CreatedAt.After(time.Now().Add(24 * time.Hour)):
+		return errors.New("created_at lies in the future")
+	}
+	return nil
+}
+
+// snapshot captures the scrolls for comparison with the last save. Their
+// order is ignored, since listing sorts them in place.
+func (app *NotesApp) snapshot() []byte {
+	notes := make([]Note, len(app.Notes))
+	copy(notes, app.Notes)
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+	data, _ := json.Marshal(jsonArchive{Notes: notes, NextID: app.NextID})
+	return data
+}
+
+// flush saves the scrolls if they have changed since they were last loaded
+// or saved, so that no exit from the archives loses an edit.
+func (app *NotesApp) flush() {
+	if !bytes.Equal(app.snapshot(), app.saved) {
+		app.SaveNotes()
+	}
+}
+
+// saveMu is held while the archives are written, so an interrupt waits for
+// the save in progress instead of leaving a partial file behind.
+var saveMu sync.Mutex
+
+// sortTags orders runes alphabetically, ignoring case, so that the same set
+// of runes is always written the same way.
+func sortTags(tags []string) {
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i]), strings.ToLower(tags[j])
+		if a != b {
+			return a < b
 		}
+		return tags[i] < tags[j]
+	})
+}
+
+func (app *NotesApp) SaveNotes() {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	
+	for _, note := range app.Notes {
+		sortTags(note.Tags)
+	}
+	if err := app.store.Save(app.Notes, app.NextID); err != nil {
+		app.fail(ExitIOError, "Error saving notes: %v\n", err)
+		return
+	}
+	app.saved = app.snapshot()
+	app.generation++
+	app.writeMirrors()
+	
+	if err := app.writeSearchIndex(buildSearchIndex(app.Notes, app.saved)); err != nil {
+		fmt.Printf("Warning: Could not update the search index: %v\n", err)
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
 }
 
-func (app *NotesApp) RecaptureImage(id int) {
-	for i, note := range app.Notes {
-		if note.ID == id {
-			if note.Type != "screenshot" {
-				fmt.Printf("Scroll #%d is not a captured image. Cannot recapture.\n", id)
-				return
-			}
-			
-			reader := bufio.NewReader(os.Stdin)
-			
-			// Ask if they want to delete the old image
-			fmt.Printf("Delete the old captured image '%s'? (y/n): ", note.Screenshot)
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(strings.ToLower(response))
-			
-			deleteOld := response == "y" || response == "yes"
-			oldFilePath := note.FilePath
-			
-			// Create new screenshot
-			timestamp := time.Now().Format("20060102_150405")
-			filename := fmt.Sprintf("scroll_capture_%s_%d.png", timestamp, note.ID)
-			screenshotPath := filepath.Join(app.NotesDir, "screenshots", filename)
-			
-			var cmd *exec.Cmd
-			switch runtime.GOOS {
-			case "darwin": // macOS
-				cmd = exec.Command("screencapture", "-i", screenshotPath)
-			case "linux":
-				cmd = exec.Command("gnome-screenshot", "-a", "-f", screenshotPath)
-			case "windows":
-				// For Windows, we'll use a PowerShell command
-				psScript := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; $Screen = [System.Windows.Forms.SystemInformation]::VirtualScreen; $Width = $Screen.Width; $Height = $Screen.Height; $Left = $Screen.Left; $Top = $Screen.Top; $bitmap = New-Object System.Drawing.Bitmap $Width, $Height; $graphic = [System.Drawing.Graphics]::FromImage($bitmap); $graphic.CopyFromScreen($Left, $Top, 0, 0, $bitmap.Size); $bitmap.Save('%s'); $graphic.Dispose(); $bitmap.Dispose()`, screenshotPath)
-				cmd = exec.Command("powershell", "-Command", psScript)
-			default:
-				fmt.Println("Image recapture not supported on this platform")
-				return
-			}
-			
-			fmt.Println("Recapturing ancient knowledge... (follow system prompts)")
-			if err := cmd.Run(); err != nil {
-				fmt.Printf("Error recapturing image: %v\n", err)
-				return
-			}
-			
-			// Check if new screenshot file was created
-			if _, err := os.Stat(screenshotPath); os.IsNotExist(err) {
-				fmt.Println("Knowledge recapture cancelled or failed")
-				return
-			}
-			
-			// Update the note with new image info
-			app.Notes[i].FilePath = screenshotPath
-			app.Notes[i].Screenshot = filename
-			app.Notes[i].UpdatedAt = time.Now()
-			
-			// Delete old image if requested
-			if deleteOld && oldFilePath != "" {
-				if err := os.Remove(oldFilePath); err != nil {
-					fmt.Printf("Warning: Could not delete old image: %v\n", err)
-				}
-			}
-			
-			app.SaveNotes()
-			fmt.Printf("Scroll #%d image has been recaptured: %s\n", id, filename)
-			return
+// mirrorMarkdown is what a scroll's mirror file holds.
+func mirrorMarkdown(note Note) string {
+	text := "# " + note.Title + "\n"
+	if len(note.Tags) > 0 {
+		text += "\nTags: " + strings.Join(note.Tags, ", ") + "\n"
+	}
+	if note.Type == "text" {
+		text += "\n" + strings.TrimRight(note.Content, "\n") + "\n"
+	} else {
+		text += fmt.Sprintf("\n![%s](%s)\n", note.Title, note.FilePath)
+	}
+	return text
+}
+
+// writeMirrors brings every mirror file up to date with its scroll, leaving
+// files that already match alone.
+func (app *NotesApp) writeMirrors() {
+	for _, note := range app.Notes {
+		if note.MirrorPath == "" {
+			continue
+		}
+		data := []byte(mirrorMarkdown(note))
+		if old, err := ioutil.ReadFile(note.MirrorPath); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err := ioutil.WriteFile(note.MirrorPath, data, 0644); err != nil {
+			fmt.Printf("Warning: Could not update the mirror of scroll #%d: %v\n", note.ID, err)
 		}
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
 }
 
-func (app *NotesApp) DeleteNote(id int) {
-	for i, note := range app.Notes {
-		if note.ID == id {
-			// If it's a screenshot, ask if user wants to delete the file too
-			if note.Type == "screenshot" {
-				fmt.Printf("Destroy the captured image '%s' from the archives as well? (y/n): ", note.Screenshot)
-				reader := bufio.NewReader(os.Stdin)
-				response, _ := reader.ReadString('\n')
-				response = strings.TrimSpace(strings.ToLower(response))
-				
-				if response == "y" || response == "yes" {
-					if err := os.Remove(note.FilePath); err != nil {
-						fmt.Printf("Warning: Could not destroy captured image: %v\n", err)
-					}
-				}
-			}
-			
-			// Remove note from slice
-			app.Notes = append(app.Notes[:i], app.Notes[i+1:]...)
-			app.SaveNotes()
-			fmt.Printf("Scroll #%d has been erased from the archives.\n", id)
+// SetMirror keeps a Markdown copy of a scroll at path, rewritten whenever
+// the scroll is saved. An empty path stops mirroring; the file is left.
+func (app *NotesApp) SetMirror(id int, path string) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	if path == "" {
+		if app.Notes[i].MirrorPath == "" {
+			fmt.Printf("Scroll #%d is not mirrored.\n", id)
 			return
 		}
+		app.Notes[i].MirrorPath = ""
+		app.SaveNotes()
+		fmt.Printf("Scroll #%d is no longer mirrored.\n", id)
+		return
+	}
+	
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		app.fail(ExitInvalid, "Error: %v\n", err)
+		return
 	}
-	fmt.Printf("Scroll with ID %d not found in the archives.\n", id)
+	if info, err := os.Stat(abs); err == nil && info.IsDir() {
+		app.fail(ExitInvalid, "%s is a directory; give a file path for the mirror.\n", abs)
+		return
+	}
+	app.Notes[i].MirrorPath = abs
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d is now mirrored to %s.\n", id, abs)
 }
 
-func (app *NotesApp) ShowHelp() {
-	fmt.Println("\n=== The Ancient Scrolls - Ancient Commands ===")
-	fmt.Println("Available commands:")
-	fmt.Println("  1 or inscribe   - Inscribe a new text scroll")
-	fmt.Println("  2 or capture    - Capture an image scroll")
-	fmt.Println("  3 or archive    - View all scrolls in the archive")
-	fmt.Println("  4 or reveal     - Reveal a specific scroll")
-	fmt.Println("  5 or seek       - Seek knowledge within scrolls")
-	fmt.Println("  6 or modify     - Modify an existing scroll")
-	fmt.Println("  7 or retitle    - Change a scroll's title")
-	fmt.Println("  8 or retag      - Update a scroll's ancient runes")
-	fmt.Println("  9 or recapture  - Replace a captured image")
-	fmt.Println("  10 or erase     - Erase a scroll from existence")
-	fmt.Println("  11 or wisdom    - Show these ancient commands")
-	fmt.Println("  12 or depart    - Depart from the archives")
-	fmt.Println()
+// Storage formats for the scrolls of a notebook.
+const (
+	StorageJSON     = "json"
+	StorageMarkdown = "markdown"
+)
+
+func validStorage(kind string) bool {
+	return kind == "" || kind == StorageJSON || kind == StorageMarkdown
 }
 
-func (app *NotesApp) Run() {
-	reader := bufio.NewReader(os.Stdin)
+// Store keeps the scrolls of one notebook. Load returns no notes and a zero
+// next ID when nothing has been stored yet.
+type Store interface {
+	Load() ([]Note, int, error)
+	Save(notes []Note, nextID int) error
+	Location() string
 	
-	fmt.Println("🏛️  Welcome to The Ancient Scrolls! 🏛️")
-	fmt.Printf("The ancient archives are stored in: %s\n", app.NotesDir)
-	app.ShowHelp()
+	// Each reads the scrolls one at a time, in order of ID, stopping at
+	// the first error fn returns.
+	Each(fn func(Note) error) error
+}
+
+func newStore(settings Settings, notesDir string) Store {
+	if settings.Storage == StorageMarkdown {
+		return markdownStore{dir: notesDir}
+	}
+	return jsonStore{
+		path:     filepath.Join(notesDir, "scrolls.json"),
+		compact:  settings.CompactJSON,
+		compress: settings.CompressStore,
+	}
+}
+
+// jsonStore keeps every scroll in a single scrolls.json file, indented for
+// reading unless compact is set, and gzipped to scrolls.json.gz when
+// compress is set.
+type jsonStore struct {
+	path     string
+	compact  bool
+	compress bool
+}
+
+type jsonArchive struct {
+	Notes  []Note `json:"notes"`
+	NextID int    `json:"next_id"`
+}
+
+// file is where the store is written; other is where it would be with
+// compression switched the other way.
+func (s jsonStore) file() string {
+	if s.compress {
+		return s.path + ".gz"
+	}
+	return s.path
+}
+
+func (s jsonStore) other() string {
+	if s.compress {
+		return s.path
+	}
+	return s.path + ".gz"
+}
+
+func (s jsonStore) Location() string { return s.file() }
+
+// Load reads the store, falling back to the file written before compression
+// was switched on or off so that the next save migrates it.
+func (s jsonStore) Load() ([]Note, int, error) {
+	path := s.file()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		path = s.other()
+		data, err = ioutil.ReadFile(path)
+	}
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
 	
-	for {
-		fmt.Print("\nSpeak your command, seeker of knowledge (or 'wisdom' for guidance): ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-		
-		switch strings.ToLower(input) {
-		case "1", "inscribe", "add":
-			fmt.Print("Enter the title of your scroll: ")
-			title, _ := reader.ReadString('\n')
-			title = strings.TrimSpace(title)
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading %s: %v", path, err)
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return nil, 0, fmt.Errorf("reading %s: %v", path, err)
+		}
+	}
+	
+	var archive jsonArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, 0, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return archive.Notes, archive.NextID, nil
+}
+
+// Each decodes the scrolls one by one, so that the archive is never held in
+// memory whole. The scrolls are saved in order of ID; an archive last
+// written otherwise is read as it lies.
+func (s jsonStore) Each(fn func(Note) error) error {
+	path := s.file()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		path = s.other()
+		f, err = os.Open(path)
+	}
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	var r io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", path, err)
+		}
+		r = zr
+	}
+	
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", path, err)
+		}
+		if key != "notes" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return fmt.Errorf("parsing %s: %v", path, err)
+			}
+			continue
+		}
+		
+		if tok, err := dec.Token(); err != nil || tok == nil {
+			if err != nil {
+				return fmt.Errorf("parsing %s: %v", path, err)
+			}
+			continue // "notes": null
+		}
+		for dec.More() {
+			var note Note
+			if err := dec.Decode(&note); err != nil {
+				return fmt.Errorf("parsing %s: %v", path, err)
+			}
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("parsing %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func (s jsonStore) Save(notes []Note, nextID int) error {
+	// Listing sorts the scrolls in place; they are written in order of ID
+	// all the same, so that Each can hand them over in that order.
+	notes = append([]Note(nil), notes...)
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+	
+	archive := jsonArchive{Notes: notes, NextID: nextID}
+	var data []byte
+	var err error
+	if s.compact {
+		data, err = json.Marshal(archive)
+	} else {
+		data, err = json.MarshalIndent(archive, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	
+	if s.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	if err := writeFileAtomic(s.file(), data, 0644); err != nil {
+		return err
+	}
+	
+	if err := os.Remove(s.other()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Compact rewrites scrolls.json from the scrolls as loaded, dropping fields
+// the archives no longer use and normalising the formatting. A non-nil
+// minify switches between indented and compact JSON for good.
+func (app *NotesApp) Compact(minify *bool) {
+	js, ok := app.store.(jsonStore)
+	if !ok {
+		app.fail(ExitInvalid, "Only the json store can be compacted; markdown scrolls are rewritten whenever they are saved.\n")
+		return
+	}
+	
+	before, err := os.Stat(js.file())
+	if os.IsNotExist(err) {
+		fmt.Println("The archives are empty; there is nothing to compact.")
+		return
+	}
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the archives: %v\n", err)
+		return
+	}
+	
+	if minify != nil && *minify != app.Settings.CompactJSON {
+		fileSettings, _ := LoadSettings(settingsPath())
+		fileSettings.CompactJSON = *minify
+		if err := SaveSettings(settingsPath(), fileSettings); err != nil {
+			app.fail(ExitIOError, "Error saving settings: %v\n", err)
+			return
+		}
+		app.Settings.CompactJSON = *minify
+		js.compact = *minify
+		app.store = js
+	}
+	
+	app.SaveNotes()
+	after, err := os.Stat(js.file())
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the archives: %v\n", err)
+		return
+	}
+	
+	style := "indented"
+	if js.compact {
+		style = "compact"
+	}
+	if saved := before.Size() - after.Size(); saved >= 0 {
+		fmt.Printf("The archives have been rewritten as %s JSON: %d bytes, down from %d (%d saved).\n",
+			style, after.Size(), before.Size(), saved)
+	} else {
+		fmt.Printf("The archives have been rewritten as %s JSON: %d bytes, up from %d.\n",
+			style, after.Size(), before.Size())
+	}
+}
+
+// markdownStore keeps each scroll in its own <id>-<slug>.md file, with the
+// scroll's fields in YAML front matter above the content. The files are the
+// source of truth, so they may be edited by hand.
+type markdownStore struct {
+	dir string
+}
+
+var markdownScrollFile = regexp.MustCompile(`^(\d+)-.*\.md$`)
+
+func (s markdownStore) Location() string { return s.dir }
+
+// nextIDFile remembers the next ID so erased scrolls' IDs are not reused.
+func (s markdownStore) nextIDFile() string {
+	return filepath.Join(s.dir, ".next-id")
+}
+
+// recordFile lists the files the store has written, so that it removes no
+// other file when a scroll is erased or retitled.
+func (s markdownStore) recordFile() string {
+	return filepath.Join(s.dir, ".scroll-files")
+}
+
+// recorded reads the names of the files the store last wrote. It reports
+// false for archives saved before the store kept that record.
+func (s markdownStore) recorded() (map[string]bool, bool) {
+	data, err := ioutil.ReadFile(s.recordFile())
+	if err != nil {
+		return nil, false
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Split(string(data), "\n") {
+		if name != "" {
+			names[name] = true
+		}
+	}
+	return names, true
+}
+
+// loadFile reads the scroll kept in the named file.
+func (s markdownStore) loadFile(name string) (Note, error) {
+	path := filepath.Join(s.dir, name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Note{}, err
+	}
+	note, err := parseMarkdownScroll(string(data))
+	if err != nil {
+		return Note{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if note.ID == 0 {
+		note.ID, _ = strconv.Atoi(markdownScrollFile.FindStringSubmatch(name)[1])
+	}
+	return note, nil
+}
+
+func (s markdownStore) Load() ([]Note, int, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && markdownScrollFile.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	
+	// Read and parse the files on a few workers; each result goes to the
+	// slot of its file, so the scrolls keep the directory's order.
+	notes := make([]Note, len(names))
+	errs := make([]error, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	if workers > len(names) {
+		workers = len(names)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				notes[i], errs[i] = s.loadFile(names[i])
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	
+	nextID := 0
+	for i, note := range notes {
+		if errs[i] != nil {
+			return nil, 0, errs[i]
+		}
+		if note.ID >= nextID {
+			nextID = note.ID + 1
+		}
+	}
+	if len(notes) == 0 {
+		notes = nil
+	}
+	
+	if data, err := ioutil.ReadFile(s.nextIDFile()); err == nil {
+		if stored, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && stored > nextID {
+			nextID = stored
+		}
+	}
+	return notes, nextID, nil
+}
+
+// files lists the store's files holding the scroll with the given ID.
+func (s markdownStore) files(id int) []string {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		if m := markdownScrollFile.FindStringSubmatch(entry.Name()); m != nil && !entry.IsDir() && m[1] == strconv.Itoa(id) {
+			paths = append(paths, filepath.Join(s.dir, entry.Name()))
+		}
+	}
+	return paths
+}
+
+// Each reads the scroll files one at a time, in order of ID.
+func (s markdownStore) Each(fn func(Note) error) error {
+	entries, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && markdownScrollFile.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	fileID := func(name string) int {
+		id, _ := strconv.Atoi(markdownScrollFile.FindStringSubmatch(name)[1])
+		return id
+	}
+	sort.SliceStable(names, func(i, j int) bool { return fileID(names[i]) < fileID(names[j]) })
+	
+	for _, name := range names {
+		note, err := s.loadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s markdownStore) Save(notes []Note, nextID int) error {
+	keep := make(map[string]bool)
+	for _, note := range notes {
+		name := fmt.Sprintf("%d-%s.md", note.ID, slugify(note.Title))
+		path, err := safeJoin(s.dir, name)
+		if err != nil {
+			return err
+		}
+		keep[name] = true
+		
+		data := []byte(formatMarkdownScroll(note))
+		if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	
+	// Files of erased or retitled scrolls are removed: those the store
+	// wrote, and those holding a scroll now kept under another name, as a
+	// hand-named file does once saved. Before the store kept a record, it
+	// had written every file that holds a scroll.
+	recorded, known := s.recorded()
+	saved := make(map[int]bool)
+	for _, note := range notes {
+		saved[note.ID] = true
+	}
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !markdownScrollFile.MatchString(name) || keep[name] {
+			continue
+		}
+		if !recorded[name] {
+			note, err := s.loadFile(name)
+			if err != nil || known && !saved[note.ID] {
+				continue
+			}
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	
+	names := make([]string, 0, len(keep))
+	for name := range keep {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := writeFileAtomic(s.recordFile(), []byte(strings.Join(names, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.nextIDFile(), []byte(strconv.Itoa(nextID)+"\n"), 0644)
+}
+
+// formatMarkdownScroll writes a scroll as front matter and content. Values
+// are written as JSON, which YAML reads as quoted strings and flow lists.
+func formatMarkdownScroll(note Note) string {
+	var b strings.Builder
+	field := func(key string, value interface{}) {
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(&b, "%s: %s\n", key, data)
+	}
+	
+	b.WriteString("---\n")
+	field("id", note.ID)
+	field("title", note.Title)
+	field("type", note.Type)
+	tags := note.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	field("tags", tags)
+	field("created_at", note.CreatedAt)
+	field("updated_at", note.UpdatedAt)
+	if note.Screenshot != "" {
+		field("screenshot", note.Screenshot)
+		field("file_path", note.FilePath)
+	}
+	if len(note.Attachments) > 0 {
+		field("attachments", note.Attachments)
+	}
+	if note.Order != 0 {
+		field("order", note.Order)
+	}
+	if note.PinnedAt != nil {
+		field("pinned_at", note.PinnedAt)
+	}
+	if note.Priority != nil {
+		field("priority", note.Priority)
+	}
+	if note.MirrorPath != "" {
+		field("mirror_path", note.MirrorPath)
+	}
+	if note.OCRText != "" {
+		field("ocr_text", note.OCRText)
+	}
+	if note.DueAt != nil {
+		field("due_at", note.DueAt)
+	}
+	if note.Notified {
+		field("notified", note.Notified)
+	}
+	if note.Region != "" {
+		field("region", note.Region)
+	}
+	if note.Read {
+		field("read", note.Read)
+	}
+	if note.SearchBoost != 0 {
+		field("search_boost", note.SearchBoost)
+	}
+	b.WriteString("---\n")
+	
+	// The content always ends with a newline of its own, which the parser
+	// strips again, so content ending in a newline keeps it.
+	b.WriteString(note.Content)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// parseMarkdownScroll reads a scroll written by formatMarkdownScroll. Plain
+// unquoted values and [a, b] tag lists, as typed by hand, are accepted too.
+func parseMarkdownScroll(text string) (Note, error) {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if !strings.HasPrefix(text, "---\n") {
+		return Note{}, errors.New("missing front matter")
+	}
+	rest := text[4:]
+	var header, body string
+	if rest == "---" || strings.HasPrefix(rest, "---\n") {
+		// The closing line follows the opening one: an empty header.
+		body = strings.TrimPrefix(rest[3:], "\n")
+	} else if end := strings.Index(rest, "\n---\n"); end >= 0 {
+		header, body = rest[:end], rest[end+5:]
+	} else if strings.HasSuffix(rest, "\n---") {
+		header = strings.TrimSuffix(rest, "\n---")
+	} else {
+		return Note{}, errors.New("unterminated front matter")
+	}
+	// One closing newline belongs to the file rather than to the content.
+	content := strings.TrimSuffix(body, "\n")
+	
+	note := Note{Type: "text", Content: content}
+	for _, line := range strings.Split(header, "\n") {
+		colon := strings.Index(line, ":")
+		if strings.TrimSpace(line) == "" || colon < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:colon])
+		raw := strings.TrimSpace(line[colon+1:])
+		
+		str := func() string {
+			var v string
+			if json.Unmarshal([]byte(raw), &v) == nil {
+				return v
+			}
+			return strings.Trim(raw, "'")
+		}
+		var err error
+		switch key {
+		case "id":
+			note.ID, err = strconv.Atoi(raw)
+		case "title":
+			note.Title = str()
+		case "type":
+			note.Type = str()
+		case "tags":
+			if json.Unmarshal([]byte(raw), &note.Tags) != nil {
+				note.Tags = parseTags(strings.Trim(raw, "[]"))
+			}
+		case "created_at":
+			note.CreatedAt, err = time.Parse(time.RFC3339, str())
+		case "updated_at":
+			note.UpdatedAt, err = time.Parse(time.RFC3339, str())
+		case "screenshot":
+			note.Screenshot = str()
+		case "file_path":
+			note.FilePath = str()
+		case "attachments":
+			err = json.Unmarshal([]byte(raw), &note.Attachments)
+		case "order":
+			note.Order, err = strconv.Atoi(raw)
+		case "pinned_at":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, str())
+			note.PinnedAt = &t
+		case "priority":
+			var n int
+			n, err = strconv.Atoi(raw)
+			note.Priority = &n
+		case "mirror_path":
+			note.MirrorPath = str()
+		case "ocr_text":
+			note.OCRText = str()
+		case "due_at":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, str())
+			note.DueAt = &t
+		case "notified":
+			note.Notified, err = strconv.ParseBool(raw)
+		case "region":
+			note.Region = str()
+		case "read":
+			note.Read, err = strconv.ParseBool(raw)
+		case "search_boost":
+			note.SearchBoost, err = strconv.Atoi(raw)
+		}
+		if err != nil {
+			return Note{}, fmt.Errorf("bad %s: %v", key, err)
+		}
+	}
+	return note, nil
+}
+
+// hasTitle reports whether title names a scroll, failing the command when it
+// is blank; validateNote would reject such a scroll on the next load.
+func (app *NotesApp) hasTitle(title string) bool {
+	if strings.TrimSpace(title) == "" {
+		app.fail(ExitInvalid, "A scroll needs a title.\n")
+		return false
+	}
+	return true
+}
+
+func (app *NotesApp) CreateTextNote(title, content string, tags []string) {
+	app.createTextNoteAt(title, content, tags, time.Now())
+}
+
+// createTextNoteAt creates a text scroll dated as first written at created.
+func (app *NotesApp) createTextNoteAt(title, content string, tags []string, created time.Time) {
+	if !app.hasTitle(title) {
+		return
+	}
+	
+	note := Note{
+		ID:        app.NextID,
+		Title:     title,
+		Content:   content,
+		Tags:      tags,
+		CreatedAt: created,
+		UpdatedAt: time.Now(),
+		Type:      "text",
+	}
+	
+	app.Notes = append(app.Notes, note)
+	app.NextID++
+	app.SaveNotes()
+	
+	fmt.Printf("Created scroll #%d: %s\n", note.ID, note.Title)
+}
+
+// maxImportTitle is the longest first line import-txt takes as a title;
+// longer ones read as prose and stay in the content.
+const maxImportTitle = 100
+
+// parseTextImport splits a text file into a scroll's title, content, tags
+// and the date it was first written. The first non-empty line is the title,
+// with any markdown heading marks removed. A header of "Tags:" and
+// "Created:" or "Date:" lines, just before or just after the title,
+// supplies runes and the date, left unparsed; such lines further down are
+// content. The file's stem is the title when no line fits.
+func parseTextImport(filename, text string) (string, string, []string, string) {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	
+	var tags []string
+	created := ""
+	pos := 0
+	// header reads the Tags:, Created: and Date: lines starting at pos,
+	// stopping at the first blank or other line.
+	header := func() {
+		for ; pos < len(lines); pos++ {
+			parts := strings.SplitN(strings.TrimSpace(lines[pos]), ":", 2)
+			if len(parts) != 2 {
+				return
+			}
+			key, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+			switch {
+			case key == "tags" && tags == nil && value != "":
+				tags = parseTags(value)
+			case (key == "created" || key == "date") && created == "" && value != "":
+				created = value
+			default:
+				return
+			}
+		}
+	}
+	
+	// The header may come before the title or straight after it.
+	header()
+	title := ""
+	for i := pos; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(lines[i]), "#"))
+		if trimmed == "" {
+			continue
+		}
+		if len([]rune(trimmed)) <= maxImportTitle {
+			title = trimmed
+			pos = i + 1
+			header()
+		}
+		break
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	
+	content := strings.Trim(strings.Join(lines[pos:], "\n"), "\n")
+	return title, strings.TrimRight(content, " \t\n"), tags, created
+}
+
+// parseImportDate reads the date an imported scroll was first written,
+// accepting RFC 3339, "2006-01-02 15:04" and "2006-01-02" in local time.
+// Dates in the future are refused.
+func parseImportDate(input string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		input = t.Local().Format("2006-01-02 15:04:05")
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		t, err := time.ParseInLocation(layout, input, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.After(now) {
+			return time.Time{}, fmt.Errorf("%s lies in the future", input)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q (try 2019-05-04 or 2019-05-04 18:30)", input)
+}
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to their runes; the
+// other bytes mean the same as in Latin-1. Unused bytes keep their value.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeText transcodes imported text to UTF-8 and names the encoding it
+// found. UTF-16 is recognised by its byte order mark; text that is not valid
+// UTF-8 is read as Windows-1252, which covers Latin-1.
+//
+// This is what golang.org/x/net/html/charset concludes for plain text, which
+// has no <meta> tag to go by: a byte order mark, else UTF-8 if it is valid,
+// else Windows-1252. The archives build from this one file with the
+// standard library alone, so the few lines are kept here instead.
+func decodeText(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "UTF-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		littleEndian := data[0] == 0xFF
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			if littleEndian {
+				units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+			} else {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			}
+		}
+		if littleEndian {
+			return string(utf16.Decode(units)), "UTF-16LE"
+		}
+		return string(utf16.Decode(units)), "UTF-16BE"
+	case utf8.Valid(data):
+		return string(data), "UTF-8"
+	}
+	
+	var b strings.Builder
+	for _, c := range data {
+		if c >= 0x80 && c <= 0x9F {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String(), "Windows-1252"
+}
+
+// ImportText creates a text scroll from a plain text file.
+func (app *NotesApp) ImportText(path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		app.fail(ExitIOError, "Error reading file: %v\n", err)
+		return
+	}
+	
+	text, encoding := decodeText(data)
+	if encoding != "UTF-8" {
+		fmt.Printf("Read %s as %s.\n", path, encoding)
+	}
+	title, content, tags, createdInput := parseTextImport(path, text)
+	
+	created := time.Now()
+	if createdInput != "" {
+		if t, err := parseImportDate(createdInput, created); err == nil {
+			created = t
+		} else {
+			fmt.Printf("Warning: %v; the scroll is dated today.\n", err)
+		}
+	}
+	app.createTextNoteAt(title, content, tags, created)
+}
+
+func (app *NotesApp) TakeScreenshot(title string, tags []string) {
+	if !app.hasTitle(title) {
+		return
+	}
+	
+	timestamp := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("scroll_capture_%s_%d.png", timestamp, app.NextID)
+	screenshotPath := filepath.Join(app.imagesDir(), filename)
+	
+	cmd, region, err := app.captureCommand(screenshotPath, "")
+	if err != nil {
+		app.fail(ExitIOError, "Knowledge capture cancelled: %v\n", err)
+		return
+	}
+	if cmd == nil {
+		app.fail(ExitIOError, "Screenshot feature not supported on this platform\n")
+		return
+	}
+	
+	fmt.Println("Capturing ancient knowledge... (follow system prompts)")
+	if err := cmd.Run(); err != nil {
+		app.fail(ExitIOError, "Error taking screenshot: %v\n", err)
+		return
+	}
+	
+	// Check if screenshot file was created
+	if _, err := os.Stat(screenshotPath); os.IsNotExist(err) {
+		app.fail(ExitIOError, "Knowledge capture cancelled or failed\n")
+		return
+	}
+	
+	note := Note{
+		ID:         app.NextID,
+		Title:      title,
+		Tags:       tags,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Type:       "screenshot",
+		FilePath:   screenshotPath,
+		Screenshot: filename,
+		Region:     region,
+	}
+	
+	app.Notes = append(app.Notes, note)
+	app.NextID++
+	app.SaveNotes()
+	
+	fmt.Printf("Scroll captured and saved as scroll #%d: %s\n", note.ID, note.Title)
+}
+
+// screenshotTools lists the capture tools the seeker may choose from.
+func screenshotTools() []string {
+	return []string{"default", "gnome-screenshot", "spectacle", "scrot", "maim", "screencapture", "powershell"}
+}
+
+// platformScreenshotTools lists the capture tools tried, in order of
+// preference, when none is chosen.
+func platformScreenshotTools() []string {
+	switch runtime.GOOS {
+	case "darwin": // macOS
+		return []string{"screencapture"}
+	case "linux":
+		return []string{"gnome-screenshot", "spectacle", "scrot", "maim"}
+	case "windows":
+		return []string{"powershell"}
+	}
+	return nil
+}
+
+// lookPath finds executables; it is a variable so detection can be stubbed.
+var lookPath = exec.LookPath
+
+// detectScreenshotTool finds the capture tool to use: the chosen one if it is
+// installed, otherwise the first installed platform tool. The result is
+// cached on the app.
+func (app *NotesApp) detectScreenshotTool() string {
+	candidates := platformScreenshotTools()
+	if app.Settings.ScreenshotTool != "" {
+		candidates = []string{app.Settings.ScreenshotTool}
+	}
+	
+	app.screenshotTool = ""
+	for _, tool := range candidates {
+		if _, err := lookPath(tool); err == nil {
+			app.screenshotTool = tool
+			break
+		}
+	}
+	return app.screenshotTool
+}
+
+// currentScreenshotTool returns the cached capture tool, detecting it again
+// only when none was found or the cached one has since disappeared.
+func (app *NotesApp) currentScreenshotTool() string {
+	if app.screenshotTool != "" {
+		if _, err := lookPath(app.screenshotTool); err == nil {
+			return app.screenshotTool
+		}
+	}
+	return app.detectScreenshotTool()
+}
+
+// screenshotCommand builds the capture command for the detected tool, or
+// returns nil when no tool is available on this platform.
+func (app *NotesApp) screenshotCommand(screenshotPath string) *exec.Cmd {
+	tool := app.currentScreenshotTool()
+	
+	switch tool {
+	case "screencapture":
+		return exec.Command("screencapture", "-i", screenshotPath)
+	case "gnome-screenshot":
+		return exec.Command("gnome-screenshot", "-a", "-f", screenshotPath)
+	case "spectacle":
+		return exec.Command("spectacle", "-r", "-b", "-n", "-o", screenshotPath)
+	case "scrot":
+		return exec.Command("scrot", "-s", screenshotPath)
+	case "maim":
+		return exec.Command("maim", "-s", screenshotPath)
+	case "powershell":
+		// For Windows, we'll use a PowerShell command
+		psScript := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; $Screen = [System.Windows.Forms.SystemInformation]::VirtualScreen; $Width = $Screen.Width; $Height = $Screen.Height; $Left = $Screen.Left; $Top = $Screen.Top; $bitmap = New-Object System.Drawing.Bitmap $Width, $Height; $graphic = [System.Drawing.Graphics]::FromImage($bitmap); $graphic.CopyFromScreen($Left, $Top, 0, 0, $bitmap.Size); $bitmap.Save('%s'); $graphic.Dispose(); $bitmap.Dispose()`, screenshotPath)
+		return exec.Command("powershell", "-Command", psScript)
+	}
+	return nil
+}
+
+// screenRegion matches a capture geometry in X11 form, WxH+X+Y.
+var screenRegion = regexp.MustCompile(`^(\d+)x(\d+)\+(-?\d+)\+(-?\d+)$`)
+
+// regionCommand builds a command that captures exactly region with tool, or
+// returns nil when the tool cannot be given a region.
+func regionCommand(tool, screenshotPath, region string) *exec.Cmd {
+	m := screenRegion.FindStringSubmatch(region)
+	if m == nil {
+		return nil
+	}
+	xywh := strings.Join([]string{m[3], m[4], m[1], m[2]}, ",")
+	
+	switch tool {
+	case "maim":
+		return exec.Command("maim", "-g", region, screenshotPath)
+	case "scrot":
+		return exec.Command("scrot", "-a", xywh, screenshotPath)
+	case "screencapture":
+		return exec.Command("screencapture", "-R", xywh, screenshotPath)
+	}
+	return nil
+}
+
+// selectRegion lets the seeker select an area of the screen with slop and
+// returns its geometry, or "" when slop is not installed. It is a variable
+// so that selection can be stubbed.
+var selectRegion = func() (string, error) {
+	if _, err := lookPath("slop"); err != nil {
+		return "", nil
+	}
+	out, err := exec.Command("slop", "-f", "%g").Output()
+	if err != nil {
+		return "", errors.New("no area was selected")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// captureCommand builds the command for a capture into screenshotPath. A
+// known region is captured again as it was, when the tool can be given one;
+// otherwise the seeker selects the area, through slop for the tools that
+// can be handed its selection. It returns the region captured, or "" when
+// the tool does not say.
+func (app *NotesApp) captureCommand(screenshotPath, region string) (*exec.Cmd, string, error) {
+	tool := app.currentScreenshotTool()
+	if region != "" {
+		if cmd := regionCommand(tool, screenshotPath, region); cmd != nil {
+			return cmd, region, nil
+		}
+		if tool != "" {
+			fmt.Printf("Note: %s cannot capture a given area; select it again.\n", tool)
+		}
+	}
+	
+	if tool == "maim" || tool == "scrot" {
+		selected, err := selectRegion()
+		if err != nil {
+			return nil, "", err
+		}
+		if cmd := regionCommand(tool, screenshotPath, selected); cmd != nil {
+			return cmd, selected, nil
+		}
+	}
+	return app.screenshotCommand(screenshotPath), "", nil
+}
+
+// errNoClipboardImage is reported when the clipboard holds no image to paste.
+var errNoClipboardImage = errors.New("the clipboard holds no image")
+
+// clipboardImageCommand builds the command that reads a PNG image from the
+// clipboard on goos. Commands that cannot write a file themselves print the
+// image on stdout instead, which toStdout reports.
+func clipboardImageCommand(goos, imagePath string) (cmd *exec.Cmd, toStdout bool) {
+	switch goos {
+	case "darwin": // macOS
+		return exec.Command("pngpaste", imagePath), false
+	case "linux":
+		return exec.Command("wl-paste", "--no-newline", "--type", "image/png"), true
+	case "windows":
+		psScript := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; $img = Get-Clipboard -Format Image; if ($img -eq $null) { exit 1 }; $img.Save('%s', [System.Drawing.Imaging.ImageFormat]::Png)`, imagePath)
+		return exec.Command("powershell", "-Command", psScript), false
+	}
+	return nil, false
+}
+
+// pasteClipboardImage writes the clipboard's image to imagePath.
+func pasteClipboardImage(goos, imagePath string) error {
+	cmd, toStdout := clipboardImageCommand(goos, imagePath)
+	if cmd == nil {
+		return fmt.Errorf("pasting images is not supported on %s", goos)
+	}
+	
+	if toStdout {
+		data, err := cmd.Output()
+		if errors.Is(err, exec.ErrNotFound) {
+			return err
+		}
+		if err != nil || !bytes.HasPrefix(data, []byte("\x89PNG")) {
+			return errNoClipboardImage
+		}
+		return ioutil.WriteFile(imagePath, data, 0644)
+	}
+	
+	if err := cmd.Run(); err != nil {
+		os.Remove(imagePath)
+		if errors.Is(err, exec.ErrNotFound) {
+			return err
+		}
+		return errNoClipboardImage
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		return errNoClipboardImage
+	}
+	return nil
+}
+
+// PasteImage stores the image on the clipboard as a new image scroll.
+func (app *NotesApp) PasteImage(title string, tags []string) {
+	if !app.hasTitle(title) {
+		return
+	}
+	
+	timestamp := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("scroll_paste_%s_%d.png", timestamp, app.NextID)
+	imagePath := filepath.Join(app.imagesDir(), filename)
+	
+	if err := pasteClipboardImage(runtime.GOOS, imagePath); err != nil {
+		if err == errNoClipboardImage {
+			app.fail(ExitInvalid, "Error pasting image: %v\n", err)
+		} else {
+			app.fail(ExitIOError, "Error pasting image: %v\n", err)
+		}
+		return
+	}
+	
+	note := Note{
+		ID:         app.NextID,
+		Title:      title,
+		Tags:       tags,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Type:       "screenshot",
+		FilePath:   imagePath,
+		Screenshot: filename,
+	}
+	
+	app.Notes = append(app.Notes, note)
+	app.NextID++
+	app.SaveNotes()
+	
+	fmt.Printf("Pasted image saved as scroll #%d: %s\n", note.ID, note.Title)
+}
+
+// AddImageFile creates an image scroll from an image file already on disk,
+// copying it into the archives.
+func (app *NotesApp) AddImageFile(path, title string, tags []string) {
+	if !app.hasTitle(title) {
+		return
+	}
+	
+	timestamp := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("scroll_file_%s_%d%s", timestamp, app.NextID, strings.ToLower(filepath.Ext(path)))
+	imagePath := filepath.Join(app.imagesDir(), filename)
+	
+	if err := copyFile(path, imagePath); err != nil {
+		app.fail(ExitIOError, "Error adding image: %v\n", err)
+		return
+	}
+	
+	note := Note{
+		ID:         app.NextID,
+		Title:      title,
+		Tags:       tags,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Type:       "screenshot",
+		FilePath:   imagePath,
+		Screenshot: filename,
+	}
+	
+	app.Notes = append(app.Notes, note)
+	app.NextID++
+	app.SaveNotes()
+	
+	fmt.Printf("Image saved as scroll #%d: %s\n", note.ID, note.Title)
+}
+
+// offerCaptureAlternatives is shown in place of a capture when no capture
+// tool is installed, as on a headless machine. It offers to inscribe a text
+// scroll or to add an image file instead.
+func (app *NotesApp) offerCaptureAlternatives(reader *bufio.Reader) {
+	tried := platformScreenshotTools()
+	if app.Settings.ScreenshotTool != "" {
+		tried = []string{app.Settings.ScreenshotTool}
+	}
+	if len(tried) == 0 {
+		fmt.Printf("Capturing images is not supported on %s.\n", runtime.GOOS)
+	} else {
+		fmt.Printf("No capture tool is installed (looked for %s).\n", strings.Join(tried, ", "))
+	}
+	fmt.Println("1. Inscribe a text scroll instead")
+	fmt.Println("2. Add an image file you already have")
+	fmt.Println("3. Never mind")
+	fmt.Print("Choose your path: ")
+	choice, _ := reader.ReadString('\n')
+	
+	switch strings.TrimSpace(choice) {
+	case "1":
+		app.Execute(reader, []string{"1"})
+	case "2":
+		fmt.Print("Enter the path of the image file: ")
+		path, _ := reader.ReadString('\n')
+		path = strings.TrimSpace(path)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			app.fail(ExitNotFound, "No image file at %s.\n", path)
+			return
+		}
+		
+		fmt.Print("Enter the title for your image: ")
+		title, _ := reader.ReadString('\n')
+		title = strings.TrimSpace(title)
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		
+		fmt.Printf("Mark with ancient runes (tags, %s, optional): ", app.tagInputHint())
+		tagsInput, _ := reader.ReadString('\n')
+		
+		app.AddImageFile(path, title, app.parseTagInput(strings.TrimSpace(tagsInput)))
+	default:
+		fmt.Println("No scroll was made.")
+	}
+}
+
+// Orders for list.
+const (
+	SortCreated  = "created"
+	SortManual   = "manual"
+	SortPriority = "priority"
+)
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe
+// or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalHeight guesses the terminal's height from $LINES.
+func terminalHeight() int {
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		return lines
+	}
+	return 24
+}
+
+// pagerCommand returns the pager to show long output through, or nil when
+// paging is off. The pager setting wins over $PAGER; less is the fallback.
+func (app *NotesApp) pagerCommand() *exec.Cmd {
+	pager := app.Settings.Pager
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = "less -FRX"
+	}
+	fields := strings.Fields(pager)
+	if len(fields) == 0 || fields[0] == "none" {
+		return nil
+	}
+	if _, err := lookPath(fields[0]); err != nil {
+		return nil
+	}
+	return exec.Command(fields[0], fields[1:]...)
+}
+
+// page prints text, through the pager when it is too long for the terminal.
+// Output that is piped or redirected is never paged.
+func (app *NotesApp) page(text string) {
+	if !isTerminal(os.Stdout) || strings.Count(text, "\n") < terminalHeight()-1 {
+		fmt.Print(text)
+		return
+	}
+	
+	cmd := app.pagerCommand()
+	if cmd == nil {
+		fmt.Print(text)
+		return
+	}
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Print(text)
+	}
+}
+
+// ListOptions controls how list and search present their scrolls.
+type ListOptions struct {
+	Format string // one of the Output* formats
+	Clip   bool   // copy the rendered scrolls to the clipboard instead of printing
+	Sort   string // SortCreated (newest first, the default), SortManual or SortPriority
+	
+	WithPreview bool // show the first lines of each scroll's content in the table
+	Unread      bool // list only the scrolls not yet read
+}
+
+// sortPriority puts the scrolls in order of priority, highest first, then
+// newest first. Scrolls without a priority come last.
+func (app *NotesApp) sortPriority() {
+	sort.SliceStable(app.Notes, func(i, j int) bool {
+		a, b := app.Notes[i], app.Notes[j]
+		if (a.Priority == nil) != (b.Priority == nil) {
+			return b.Priority == nil
+		}
+		if a.Priority != nil && *a.Priority != *b.Priority {
+			return *a.Priority > *b.Priority
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+}
+
+// SetPriority gives a scroll a priority, or clears it when priority is nil.
+func (app *NotesApp) SetPriority(id int, priority *int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	app.Notes[i].Priority = priority
+	app.SaveNotes()
+	
+	if priority == nil {
+		fmt.Printf("Scroll #%d no longer has a priority.\n", id)
+	} else {
+		fmt.Printf("Scroll #%d now has priority %d.\n", id, *priority)
+	}
+}
+
+// parseDue reads when a scroll falls due: a date ("2026-10-20", at
+// midnight), a date and time ("2026-10-20 15:30"), or a span from now such
+// as "2h" or "3d".
+func parseDue(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, input, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if span, err := parseSpan(input); err == nil {
+		return now.Add(span), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q (try 2026-10-20 15:30, 2026-10-20 or 2h)", input)
+}
+
+// SetDue sets or clears when a scroll falls due, rearming its reminder.
+func (app *NotesApp) SetDue(id int, due *time.Time) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	app.Notes[i].DueAt = due
+	app.Notes[i].Notified = false
+	app.SaveNotes()
+	
+	if due == nil {
+		fmt.Printf("Scroll #%d is no longer due.\n", id)
+	} else {
+		fmt.Printf("Scroll #%d falls due %s.\n", id, due.Format(app.Settings.DateFormat))
+	}
+}
+
+// notifyCommand builds the command that shows a desktop notification on
+// goos.
+func notifyCommand(goos, title, body string) *exec.Cmd {
+	switch goos {
+	case "darwin": // macOS
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))
+		return exec.Command("osascript", "-e", script)
+	case "linux":
+		return exec.Command("notify-send", title, body)
+	case "windows":
+		quote := func(s string) string { return "'" + strings.Replace(s, "'", "''", -1) + "'" }
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			"$n.ShowBalloonTip(10000, " + quote(title) + ", " + quote(body) + ", 'Info'); Start-Sleep -Seconds 10"
+		return exec.Command("powershell", "-NoProfile", "-Command", script)
+	}
+	return nil
+}
+
+// notify shows a desktop notification. It is a variable so that reminders
+// can be sent elsewhere.
+var notify = func(title, body string) error {
+	cmd := notifyCommand(runtime.GOOS, title, body)
+	if cmd == nil {
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
+
+// dueNotes returns the indexes of the scrolls due by now whose reminder has
+// not yet fired.
+func (app *NotesApp) dueNotes(now time.Time) []int {
+	var due []int
+	for i, note := range app.Notes {
+		if note.DueAt != nil && !note.Notified && !note.DueAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	return due
+}
+
+// CheckReminders fires a notification for each scroll that has come due and
+// marks it notified, so that it fires only once.
+func (app *NotesApp) CheckReminders() {
+	fired := 0
+	for _, i := range app.dueNotes(time.Now()) {
+		note := app.Notes[i]
+		body := app.preview(note.Content, 100)
+		if err := notify(fmt.Sprintf("Scroll #%d is due: %s", note.ID, note.Title), body); err != nil {
+			fmt.Printf("Warning: Could not send the reminder for scroll #%d: %v\n", note.ID, err)
+			continue
+		}
+		app.Notes[i].Notified = true
+		fired++
+		fmt.Printf("[%s] Reminded of scroll #%d: %s\n", time.Now().Format(app.Settings.DateFormat), note.ID, note.Title)
+	}
+	if fired > 0 {
+		app.SaveNotes()
+	}
+}
+
+// WatchReminders checks for due scrolls every interval until interrupted,
+// reloading the archives each time to see scrolls changed elsewhere.
+func (app *NotesApp) WatchReminders(interval time.Duration) {
+	fmt.Printf("Watching for due scrolls every %s. Press Ctrl+C to stop.\n", interval)
+	for {
+		app.CheckReminders()
+		time.Sleep(interval)
+		app.LoadNotes()
+	}
+}
+
+// sortManual puts the scrolls in their manual order. Scrolls never placed
+// follow the placed ones, oldest first.
+func (app *NotesApp) sortManual() {
+	sort.SliceStable(app.Notes, func(i, j int) bool {
+		a, b := app.Notes[i], app.Notes[j]
+		if (a.Order == 0) != (b.Order == 0) {
+			return b.Order == 0
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// MoveScroll moves a scroll one place up (delta -1) or down (delta 1) in the
+// manual order, swapping it with its neighbour.
+func (app *NotesApp) MoveScroll(id, delta int) {
+	if app.noteIndex(id) < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	app.sortManual()
+	for i := range app.Notes {
+		app.Notes[i].Order = i + 1
+	}
+	
+	i := app.noteIndex(id)
+	j := i + delta
+	if j < 0 || j >= len(app.Notes) {
+		end := "top"
+		if delta > 0 {
+			end = "bottom"
+		}
+		app.SaveNotes()
+		fmt.Printf("Scroll #%d is already at the %s.\n", id, end)
+		return
+	}
+	app.Notes[i].Order, app.Notes[j].Order = app.Notes[j].Order, app.Notes[i].Order
+	app.SaveNotes()
+	
+	fmt.Printf("Scroll #%d now rests at place %d, swapped with scroll #%d.\n", id, app.Notes[i].Order, app.Notes[j].ID)
+}
+
+// Pin overflow policies.
+const (
+	PinRefuse = "refuse"
+	PinEvict  = "evict"
+)
+
+// pinnedIndexes returns the indexes of the pinned scrolls, oldest pin first.
+func (app *NotesApp) pinnedIndexes() []int {
+	var pinned []int
+	for i, note := range app.Notes {
+		if note.PinnedAt != nil {
+			pinned = append(pinned, i)
+		}
+	}
+	sort.Slice(pinned, func(a, b int) bool {
+		return app.Notes[pinned[a]].PinnedAt.Before(*app.Notes[pinned[b]].PinnedAt)
+	})
+	return pinned
+}
+
+// PinNote pins a scroll to the top of the list. At the max_pinned limit it
+// refuses, or with pin_overflow set to evict, offers to unpin the oldest pin.
+func (app *NotesApp) PinNote(reader *bufio.Reader, id int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	if app.Notes[i].PinnedAt != nil {
+		fmt.Printf("Scroll #%d is already pinned.\n", id)
+		return
+	}
+	
+	pinned := app.pinnedIndexes()
+	if limit := app.Settings.MaxPinned; limit > 0 && len(pinned) >= limit {
+		if app.Settings.PinOverflow != PinEvict {
+			app.fail(ExitInvalid, "%d scrolls are pinned, the most allowed. Unpin one first.\n", len(pinned))
+			return
+		}
+		
+		oldest := app.Notes[pinned[0]]
+		fmt.Printf("%d scrolls are pinned, the most allowed. Unpin the oldest, #%d %s? (y/n): ", len(pinned), oldest.ID, oldest.Title)
+		confirm, _ := reader.ReadString('\n')
+		confirm = strings.TrimSpace(strings.ToLower(confirm))
+		if confirm != "y" && confirm != "yes" {
+			fmt.Println("The pins remain as they were.")
+			return
+		}
+		app.Notes[pinned[0]].PinnedAt = nil
+		fmt.Printf("Scroll #%d has been unpinned.\n", oldest.ID)
+	}
+	
+	now := time.Now()
+	app.Notes[i].PinnedAt = &now
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d has been pinned.\n", id)
+}
+
+// UnpinNote removes a scroll's pin.
+func (app *NotesApp) UnpinNote(id int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	if app.Notes[i].PinnedAt == nil {
+		fmt.Printf("Scroll #%d is not pinned.\n", id)
+		return
+	}
+	app.Notes[i].PinnedAt = nil
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d has been unpinned.\n", id)
+}
+
+func (app *NotesApp) ListNotes(opts ListOptions) {
+	switch opts.Sort {
+	case SortManual:
+		app.sortManual()
+	case SortPriority:
+		app.sortPriority()
+	default:
+		// Sort notes by creation time (newest first)
+		sort.Slice(app.Notes, func(i, j int) bool {
+			return app.Notes[i].CreatedAt.After(app.Notes[j].CreatedAt)
+		})
+	}
+	
+	if opts.WithPreview {
+		app.previewLines = listPreviewLines
+		defer func() { app.previewLines = 0 }()
+	}
+	
+	notes := app.Notes
+	if opts.Unread {
+		notes = nil
+		for _, note := range app.Notes {
+			if !note.Read {
+				notes = append(notes, note)
+			}
+		}
+	}
+	
+	if opts.Clip {
+		app.clipRendered(notes, opts.Format)
+		return
+	}
+	
+	format := opts.Format
+	if format != OutputTable {
+		app.printRendered(notes, format)
+		return
+	}
+	
+	if len(notes) == 0 {
+		if opts.Unread {
+			fmt.Println("Every scroll in the archives has been read.")
+		} else {
+			fmt.Println("No scrolls found in the archives.")
+		}
+		return
+	}
+	
+	var pinned, rest []Note
+	for _, note := range notes {
+		if note.PinnedAt != nil {
+			pinned = append(pinned, note)
+		} else {
+			rest = append(rest, note)
+		}
+	}
+	
+	var b strings.Builder
+	if len(pinned) > 0 {
+		out, _ := app.render(pinned, format)
+		b.WriteString("\n=== Pinned Scrolls ===\n" + out)
+	}
+	if len(rest) > 0 {
+		out, _ := app.render(rest, format)
+		b.WriteString("\n=== The Ancient Scrolls ===\n" + out)
+	}
+	app.page(b.String())
+}
+
+// Output formats understood by render.
+const (
+	OutputTable   = "table"
+	OutputJSON    = "json"
+	OutputJSONL   = "jsonl"
+	OutputCSV     = "csv"
+	OutputIDs     = "ids"
+	OutputOneline = "oneline"
+)
+
+func validOutputFormat(format string) bool {
+	switch format {
+	case OutputTable, OutputJSON, OutputJSONL, OutputCSV, OutputIDs, OutputOneline:
+		return true
+	}
+	return false
+}
+
+// onelineSummary formats a scroll as "#<id> <title> [tags]" for fast scanning.
+func onelineSummary(note Note) string {
+	line := fmt.Sprintf("#%d %s", note.ID, note.Title)
+	if len(note.Tags) > 0 {
+		line += " [" + strings.Join(note.Tags, ", ") + "]"
+	}
+	return line
+}
+
+// render formats notes for display. The table format is meant for people;
+// the others are meant to be parsed by other programs.
+func (app *NotesApp) render(notes []Note, format string) (string, error) {
+	var b strings.Builder
+	
+	switch format {
+	case OutputTable:
+		for _, note := range notes {
+			b.WriteString(app.scrollSummary(note))
+		}
+	case OutputJSON:
+		if notes == nil {
+			notes = []Note{}
+		}
+		data, err := json.MarshalIndent(notes, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	case OutputJSONL:
+		for _, note := range notes {
+			data, err := json.Marshal(note)
+			if err != nil {
+				return "", err
+			}
+			b.Write(data)
+			b.WriteString("\n")
+		}
+	case OutputIDs:
+		for _, note := range notes {
+			fmt.Fprintf(&b, "%d\n", note.ID)
+		}
+	case OutputOneline:
+		for _, note := range notes {
+			b.WriteString(onelineSummary(note))
+			b.WriteString("\n")
+		}
+	case OutputCSV:
+		w := csv.NewWriter(&b)
+		w.Write([]string{"id", "title", "type", "tags", "created_at", "updated_at", "content", "screenshot"})
+		for _, note := range notes {
+			w.Write([]string{
+				strconv.Itoa(note.ID),
+				note.Title,
+				note.Type,
+				strings.Join(note.Tags, ";"),
+				note.CreatedAt.Format(time.RFC3339),
+				note.UpdatedAt.Format(time.RFC3339),
+				note.Content,
+				note.Screenshot,
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unknown output format %q (use table, json, csv, jsonl, ids or oneline)", format)
+	}
+	
+	return b.String(), nil
+}
+
+func (app *NotesApp) printRendered(notes []Note, format string) {
+	out, err := app.render(notes, format)
+	if err != nil {
+		app.fail(ExitIOError, "Error rendering scrolls: %v\n", err)
+		return
+	}
+	fmt.Print(out)
+}
+
+// markdownLinePrefix matches the block markers that open a markdown line:
+// headings, quotes, list bullets, numbered items and task checkboxes.
+var markdownLinePrefix = regexp.MustCompile(`^(#{1,6}\s+|>\s*|[-*+]\s+(\[[ xX]\]\s+)?|\d+[.)]\s+)+`)
+
+// markdownEmphasis matches inline emphasis and code markers.
+var markdownEmphasis = regexp.MustCompile("\\*\\*|__|`+|~~")
+
+// normalizePreview flattens content into one clean line: markdown markers are
+// stripped and runs of whitespace, including newlines, become single spaces.
+func normalizePreview(content string) string {
+	var parts []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		line = markdownLinePrefix.ReplaceAllString(line, "")
+		line = markdownEmphasis.ReplaceAllString(line, "")
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// listPreviewLines is how many lines of content list --with-preview shows.
+const listPreviewLines = 2
+
+// previewContentLines returns the first n non-blank lines of content, each
+// cleaned of markdown as preview does and cut to width characters.
+func (app *NotesApp) previewContentLines(content string, n, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if len(lines) == n {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if !app.Settings.RawPreviews {
+			line = normalizePreview(line)
+		}
+		if line != "" {
+			lines = append(lines, truncateRunes(line, width))
+		}
+	}
+	return lines
+}
+
+// truncateRunes shortens text to at most limit characters, marking the cut.
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "..."
+}
+
+// preview returns the listing preview for a scroll's content. Stored content
+// is never changed; only the preview is normalized unless raw previews are
+// configured.
+func (app *NotesApp) preview(content string, limit int) string {
+	if !app.Settings.RawPreviews {
+		content = normalizePreview(content)
+	} else {
+		// Even raw, a preview keeps to one line.
+		content = strings.Replace(strings.Replace(content, "\r\n", " ", -1), "\n", " ", -1)
+	}
+	return truncateRunes(content, limit)
+}
+
+// clipboardWriteCommand builds the command that copies its stdin to the
+// clipboard on goos.
+func clipboardWriteCommand(goos string) *exec.Cmd {
+	switch goos {
+	case "darwin": // macOS
+		return exec.Command("pbcopy")
+	case "linux":
+		if _, err := lookPath("wl-copy"); err == nil {
+			return exec.Command("wl-copy")
+		}
+		return exec.Command("xclip", "-selection", "clipboard")
+	case "windows":
+		return exec.Command("clip")
+	}
+	return nil
+}
+
+func copyToClipboard(text string) error {
+	cmd := clipboardWriteCommand(runtime.GOOS)
+	if cmd == nil {
+		return fmt.Errorf("the clipboard is not supported on %s", runtime.GOOS)
+	}
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// clipRendered copies the rendered scrolls to the clipboard.
+func (app *NotesApp) clipRendered(notes []Note, format string) {
+	colors := app.colors
+	app.colors = false
+	out, err := app.render(notes, format)
+	app.colors = colors
+	if err != nil {
+		app.fail(ExitIOError, "Error rendering scrolls: %v\n", err)
+		return
+	}
+	if err := copyToClipboard(out); err != nil {
+		app.fail(ExitIOError, "Error copying to the clipboard: %v\n", err)
+		return
+	}
+	fmt.Printf("Copied %d scrolls to the clipboard.\n", len(notes))
+}
+
+// ansiColors maps the color names tag_styles understands to terminal codes.
+var ansiColors = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+}
+
+// tagStyle returns the color code and icon of the first tag_styles entry
+// matching one of the scroll's runes.
+func (app *NotesApp) tagStyle(note Note) (string, string) {
+	for _, entry := range app.Settings.TagStyles {
+		eq := strings.Index(entry, "=")
+		if eq < 0 || !app.containsTag(note.Tags, strings.TrimSpace(entry[:eq]), TagMatchExact) {
+			continue
+		}
+		
+		var color, icon string
+		for _, part := range strings.Fields(entry[eq+1:]) {
+			if code, ok := ansiColors[strings.ToLower(part)]; ok && color == "" {
+				color = code
+			} else if icon == "" {
+				icon = part
+			}
+		}
+		return color, icon
+	}
+	return "", ""
+}
+
+// decoratedTitle cuts a scroll's title to the list_title_width setting, then
+// colors it and adds its icon when listings go to a terminal.
+func (app *NotesApp) decoratedTitle(note Note) string {
+	title := note.Title
+	if app.Settings.ListTitleWidth > 0 {
+		title = truncateRunes(title, app.Settings.ListTitleWidth)
+	}
+	if !app.colors {
+		return title
+	}
+	color, icon := app.tagStyle(note)
+	if color != "" {
+		title = "\x1b[" + color + "m" + title + "\x1b[0m"
+	}
+	if icon != "" {
+		title = icon + " " + title
+	}
+	return title
+}
+
+func (app *NotesApp) scrollSummary(note Note) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n[%d] %s (%s)\n", note.ID, app.decoratedTitle(note), note.Type)
+	fmt.Fprintf(&b, "Created: %s\n", note.CreatedAt.Format(app.Settings.DateFormat))
+	if len(note.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n", strings.Join(note.Tags, ", "))
+	}
+	if note.Type == "text" && app.previewLines > 0 {
+		for _, line := range app.previewContentLines(note.Content, app.previewLines, 100) {
+			fmt.Fprintf(&b, "  | %s\n", line)
+		}
+	} else if note.Type == "text" {
+		fmt.Fprintf(&b, "Preview: %s\n", app.preview(note.Content, 100))
+	} else {
+		fmt.Fprintf(&b, "Captured Image: %s\n", note.Screenshot)
+	}
+	b.WriteString(strings.Repeat("-", 40) + "\n")
+	return b.String()
+}
+
+func (app *NotesApp) ViewNote(id int) {
+	// For scripts, the scroll is printed in the output format alone, with
+	// no prompts, and is not counted as read.
+	if app.Output != OutputTable {
+		i := app.noteIndex(id)
+		if i < 0 {
+			app.notFound(id)
+			return
+		}
+		if app.Output != OutputJSON {
+			app.printRendered([]Note{app.Notes[i]}, app.Output)
+			return
+		}
+		data, err := json.MarshalIndent(app.Notes[i], "", "  ")
+		if err != nil {
+			app.fail(ExitIOError, "Error rendering scroll: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+	
+	if app.showNote(id) {
+		app.recordView(id)
+		app.markSeen(id)
+	}
+}
+
+// markSeen marks a scroll the seeker has just been shown as read.
+func (app *NotesApp) markSeen(id int) {
+	if i := app.noteIndex(id); i >= 0 && !app.Notes[i].Read {
+		app.Notes[i].Read = true
+		app.SaveNotes()
+	}
+}
+
+// MarkRead marks a scroll as read or unread, for working through the
+// archives as an inbox.
+func (app *NotesApp) MarkRead(id int, read bool) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	app.Notes[i].Read = read
+	app.SaveNotes()
+	
+	if read {
+		fmt.Printf("Scroll #%d is marked read.\n", id)
+	} else {
+		fmt.Printf("Scroll #%d is marked unread.\n", id)
+	}
+}
+
+// scrollText formats a scroll's details and, for text scrolls, its content.
+func (app *NotesApp) scrollText(note Note) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n=== Ancient Scroll #%d ===\n", note.ID)
+	fmt.Fprintf(&b, "Title: %s\n", note.Title)
+	fmt.Fprintf(&b, "Type: %s\n", note.Type)
+	fmt.Fprintf(&b, "Created: %s\n", note.CreatedAt.Format(app.Settings.DateFormat))
+	fmt.Fprintf(&b, "Updated: %s\n", note.UpdatedAt.Format(app.Settings.DateFormat))
+	if note.DueAt != nil {
+		fmt.Fprintf(&b, "Due: %s\n", note.DueAt.Format(app.Settings.DateFormat))
+	}
+	
+	if len(note.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n", strings.Join(note.Tags, ", "))
+	}
+	
+	if note.Type == "text" {
+		fmt.Fprintf(&b, "\nContent:\n%s\n", note.Content)
+	}
+	return b.String()
+}
+
+// previewScroll shows everything about a scroll without asking to open
+// anything, so the seeker sees what an erase would destroy.
+func (app *NotesApp) previewScroll(id int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		return
+	}
+	note := app.Notes[i]
+	
+	fmt.Print(app.scrollText(note))
+	if note.Type != "text" {
+		fmt.Printf("\nCaptured Image: %s\n", note.Screenshot)
+		fmt.Printf("File path: %s\n", note.FilePath)
+	}
+	if len(note.Attachments) > 0 {
+		app.listAttachments(note)
+	}
+	fmt.Println()
+}
+
+// showNote prints a scroll and reports whether it was found.
+func (app *NotesApp) showNote(id int) bool {
+	for _, note := range app.Notes {
+		if note.ID == id {
+			app.page(app.scrollText(note))
+			
+			if note.Type != "text" {
+				fmt.Printf("\nCaptured Image: %s\n", note.Screenshot)
+				fmt.Printf("File path: %s\n", note.FilePath)
+				
+				// Try to open the screenshot
+				fmt.Print("Would you like to reveal this captured image? (y/n): ")
+				reader := stdin
+				response, _ := reader.ReadString('\n')
+				response = strings.TrimSpace(strings.ToLower(response))
+				
+				if response == "y" || response == "yes" {
+					if err := app.openFile(note.FilePath); err != nil {
+						app.fail(ExitIOError, "Error opening file: %v\n", err)
+					}
+				}
+			}
+			
+			if len(note.Attachments) > 0 {
+				app.listAttachments(note)
+				fmt.Print("Open an attachment? (number, or Enter to skip): ")
+				response, _ := stdin.ReadString('\n')
+				response = strings.TrimSpace(response)
+				
+				if n, err := strconv.Atoi(response); err == nil && n >= 1 && n <= len(note.Attachments) {
+					if err := app.openFile(app.attachmentPath(note.Attachments[n-1])); err != nil {
+						app.fail(ExitIOError, "Error opening attachment: %v\n", err)
+					}
+				} else if response != "" {
+					fmt.Printf("No attachment %s on this scroll.\n", response)
+				}
+			}
+			return true
+		}
+	}
+	app.notFound(id)
+	return false
+}
+
+// maxRecentViews bounds the recently viewed history kept in recent.json.
+const maxRecentViews = 20
+
+func (app *NotesApp) recentFile() string {
+	return filepath.Join(app.NotesDir, "recent.json")
+}
+
+func (app *NotesApp) loadRecent() {
+	data, err := ioutil.ReadFile(app.recentFile())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &app.Recent); err != nil {
+		app.fail(ExitIOError, "Error parsing recent scrolls: %v\n", err)
+	}
+}
+
+// recordView moves id to the top of the recently viewed stack and persists it.
+func (app *NotesApp) recordView(id int) {
+	recent := []int{id}
+	for _, prev := range app.Recent {
+		if prev != id && len(recent) < maxRecentViews {
+			recent = append(recent, prev)
+		}
+	}
+	app.Recent = recent
+	app.recentPos = 0
+	
+	data, err := json.Marshal(app.Recent)
+	if err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(app.recentFile(), data, 0644); err != nil {
+		app.fail(ExitIOError, "Error saving recent scrolls: %v\n", err)
+	}
+}
+
+// ViewLast reopens the most recently viewed scroll.
+func (app *NotesApp) ViewLast() {
+	if len(app.Recent) == 0 {
+		fmt.Println("No scrolls have been revealed yet.")
+		return
+	}
+	app.recentPos = 0
+	if app.showNote(app.Recent[0]) {
+		app.markSeen(app.Recent[0])
+	}
+}
+
+// ViewBack steps one scroll further back through the viewing history without
+// reordering it.
+func (app *NotesApp) ViewBack() {
+	if app.recentPos+1 >= len(app.Recent) {
+		fmt.Println("No earlier scrolls in your viewing history.")
+		return
+	}
+	app.recentPos++
+	if app.showNote(app.Recent[app.recentPos]) {
+		app.markSeen(app.Recent[app.recentPos])
+	}
+}
+
+// detectMIMEType names a file's type from its extension, falling back to
+// sniffing its first bytes.
+func detectMIMEType(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	
+	f, err := openForRead(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// attachmentPath resolves where an attachment's file is kept.
+func (app *NotesApp) attachmentPath(a Attachment) string {
+	return filepath.Join(app.NotesDir, a.File)
+}
+
+// AttachFile copies a file into the archives under its original name and
+// records it on the scroll.
+func (app *NotesApp) AttachFile(id int, src string) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	info, err := os.Stat(src)
+	if err != nil {
+		app.fail(ExitIOError, "Error reading file: %v\n", err)
+		return
+	}
+	if info.IsDir() {
+		app.fail(ExitInvalid, "%s is a directory; only files can be attached.\n", src)
+		return
+	}
+	
+	name := filepath.Base(src)
+	rel := filepath.Join("attachments", strconv.Itoa(id), name)
+	dst, err := safeJoin(app.NotesDir, rel)
+	if err != nil {
+		app.fail(ExitInvalid, "Error: %v\n", err)
+		return
+	}
+	for _, a := range app.Notes[i].Attachments {
+		if a.Name == name {
+			app.fail(ExitInvalid, "Scroll #%d already has an attachment named %s.\n", id, name)
+			return
+		}
+	}
+	
+	data, err := ioutil.ReadFile(src)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(dst), 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(dst, data, 0644)
+	}
+	if err != nil {
+		app.fail(ExitIOError, "Error attaching file: %v\n", err)
+		return
+	}
+	
+	app.Notes[i].Attachments = append(app.Notes[i].Attachments, Attachment{
+		Name:     name,
+		File:     rel,
+		MIMEType: detectMIMEType(src),
+		Size:     info.Size(),
+		AddedAt:  time.Now(),
+	})
+	app.Notes[i].UpdatedAt = time.Now()
+	app.SaveNotes()
+	
+	fmt.Printf("%s has been attached to scroll #%d.\n", name, id)
+}
+
+// listAttachments prints a scroll's attachments, numbered from 1.
+func (app *NotesApp) listAttachments(note Note) {
+	fmt.Println("\nAttachments:")
+	for n, a := range note.Attachments {
+		fmt.Printf("  %d) %s (%s, %d bytes)\n", n+1, a.Name, a.MIMEType, a.Size)
+	}
+}
+
+// removeAttachments deletes the files attached to a scroll, shredding them
+// first when asked.
+func (app *NotesApp) removeAttachments(note Note, shred bool) {
+	for _, a := range note.Attachments {
+		path := app.attachmentPath(a)
+		var err error
+		if shred {
+			err = shredFile(path)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Could not destroy attachment %s: %v\n", a.Name, err)
+		}
+	}
+	if len(note.Attachments) > 0 {
+		os.Remove(filepath.Dir(app.attachmentPath(note.Attachments[0])))
+	}
+}
+
+// openCommand builds the command that opens filePath in its default
+// application on goos.
+func openCommand(goos, filePath string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.Command("open", filePath)
+	case "linux":
+		return exec.Command("xdg-open", filePath)
+	case "windows":
+		return exec.Command("cmd", "/c", "start", filePath)
+	}
+	return nil
+}
+
+// openFile opens filePath in its default application.
+func (app *NotesApp) openFile(filePath string) error {
+	cmd := openCommand(runtime.GOOS, filePath)
+	if cmd == nil {
+		return fmt.Errorf("opening files is not supported on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
+
+// SearchScope names the parts of a scroll that seek looks in.
+type SearchScope struct {
+	Title   bool
+	Content bool
+	Tags    bool
+}
+
+// parseSearchScope reads a list such as "title,tags".
+func parseSearchScope(input string) (SearchScope, error) {
+	var scope SearchScope
+	for _, part := range strings.Split(input, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "title":
+			scope.Title = true
+		case "content":
+			scope.Content = true
+		case "tags", "runes":
+			scope.Tags = true
+		default:
+			return SearchScope{}, fmt.Errorf("unknown part %q (use title, content or tags)", part)
+		}
+	}
+	return scope, nil
+}
+
+// foldCase folds text for case-insensitive matching, much as cases.Fold
+// from golang.org/x/text would, without leaving the standard library. Each
+// letter folds with every letter sharing its case, so that "ſ" and the
+// Kelvin sign match "s" and "k"; ß and the Latin ligatures fold to their
+// letters, so that "STRASSE" finds "straße". Unlike cases.Fold, the Turkish
+// İ and ı fold to a plain i in every language, and other letters that fold
+// to several (such as ŉ or the Armenian ligatures) are left alone.
+func foldCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case 'ß', 'ẞ':
+			b.WriteString("ss")
+		case 'İ', 'ı':
+			b.WriteByte('i')
+		case 'ﬀ':
+			b.WriteString("ff")
+		case 'ﬁ':
+			b.WriteString("fi")
+		case 'ﬂ':
+			b.WriteString("fl")
+		case 'ﬃ':
+			b.WriteString("ffi")
+		case 'ﬄ':
+			b.WriteString("ffl")
+		case 'ﬅ', 'ﬆ':
+			b.WriteString("st")
+		default:
+			b.WriteRune(foldRune(r))
+		}
+	}
+	return b.String()
+}
+
+// foldRune picks one letter to stand for all of those sharing r's case: the
+// lowercase of the lowest among them.
+func foldRune(r rune) rune {
+	lowest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < lowest {
+			lowest = f
+		}
+	}
+	return unicode.ToLower(lowest)
+}
+
+// Search modes for seek.
+const (
+	SearchPlain         = "plain" // substring, ignoring case
+	SearchCaseSensitive = "case"  // substring, minding case
+	SearchRegex         = "regex" // regular expression
+)
+
+// searchMatcher looks for a term in a part of a scroll the way the search
+// mode asks: folding case, minding it, or matching a regular expression,
+// which stands in for the term.
+type searchMatcher struct {
+	fold bool
+	re   *regexp.Regexp
+}
+
+func (m searchMatcher) contains(text, term string) bool {
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(text)
+	case m.fold:
+		return strings.Contains(foldCase(text), term)
+	}
+	return strings.Contains(text, term)
+}
+
+func (m searchMatcher) count(text, term string) int {
+	switch {
+	case m.re != nil:
+		return len(m.re.FindAllStringIndex(text, -1))
+	case term == "":
+		return 0
+	case m.fold:
+		return strings.Count(foldCase(text), term)
+	}
+	return strings.Count(text, term)
+}
+
+// compileSearch parses a seek query for the search mode. A regular
+// expression is taken whole, without AND, OR or NOT.
+func compileSearch(query, mode string) (*queryExpr, searchMatcher, error) {
+	switch mode {
+	case SearchRegex:
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, searchMatcher{}, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return &queryExpr{Op: "term", Term: query}, searchMatcher{re: re}, nil
+	case SearchCaseSensitive:
+		expr, err := parseQueryCase(query, true)
+		return expr, searchMatcher{}, err
+	}
+	expr, err := parseQuery(query)
+	return expr, searchMatcher{fold: true}, err
+}
+
+// countOccurrences counts how often a query term appears in the parts of
+// a scroll within scope.
+func countOccurrences(note Note, query string, scope SearchScope, m searchMatcher) int {
+	count := 0
+	if scope.Title {
+		count += m.count(note.Title, query)
+	}
+	if scope.Content {
+		count += m.count(note.Content+"\n"+note.OCRText, query)
+	}
+	if scope.Tags {
+		for _, tag := range note.Tags {
+			count += m.count(tag, query)
+		}
+	}
+	return count
+}
+
+// matchedParts names the parts of a scroll within scope in which a term
+// was found, to show why the scroll matched.
+func matchedParts(note Note, terms []string, scope SearchScope, m searchMatcher) []string {
+	var parts []string
+	inTitle, inContent, inTags := false, false, false
+	for _, term := range terms {
+		inTitle = inTitle || scope.Title && m.contains(note.Title, term)
+		inContent = inContent || scope.Content && m.contains(note.Content+"\n"+note.OCRText, term)
+		for _, tag := range note.Tags {
+			inTags = inTags || scope.Tags && m.contains(tag, term)
+		}
+	}
+	if inTitle {
+		parts = append(parts, "title")
+	}
+	if inContent {
+		parts = append(parts, "content")
+	}
+	if inTags {
+		parts = append(parts, "runes")
+	}
+	return parts
+}
+
+// queryExpr is a parsed seek query: a term, or AND, OR or NOT over others.
+type queryExpr struct {
+	Op   string // "term", "AND", "OR" or "NOT"
+	Term string // lowercase, for terms
+	Args []*queryExpr
+}
+
+// queryParser reads queries such as `dragon AND (gold OR "red scales") NOT
+// ice`. Operators must be written in capitals; words between them form a
+// phrase, so a query without operators is searched as written.
+type queryParser struct {
+	tokens []string
+	pos    int
+	
+	// caseSensitive keeps the terms as written instead of folding them.
+	caseSensitive bool
+}
+
+// tokenizeQuery splits a query into words, double-quoted phrases and
+// parentheses.
+func tokenizeQuery(query string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			// A quoted phrase keeps its opening quote, so that a quoted
+			// "AND" is a word rather than an operator.
+			flush()
+			quoted = !quoted
+			if quoted {
+				word.WriteRune('"')
+			}
+		case quoted:
+			word.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func isQueryOperator(token string) bool {
+	return token == "AND" || token == "OR" || token == "NOT" || token == "(" || token == ")"
+}
+
+// parseQuery parses a seek query. OR binds loosest, then AND, then NOT; a
+// NOT with nothing before it joins the previous part with AND.
+func parseQuery(query string) (*queryExpr, error) {
+	return parseQueryCase(query, false)
+}
+
+// parseQueryCase parses a seek query, folding the terms' case unless
+// caseSensitive is set.
+func parseQueryCase(query string, caseSensitive bool) (*queryExpr, error) {
+	p := &queryParser{tokens: tokenizeQuery(query), caseSensitive: caseSensitive}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("the query is empty")
+	}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) or() (*queryExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryExpr{Op: "OR", Args: []*queryExpr{left, right}}
+	}
+	return left, nil
+}
+
+func (p *queryParser) and() (*queryExpr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "AND" || p.peek() == "NOT" {
+		if p.peek() == "AND" {
+			p.pos++
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryExpr{Op: "AND", Args: []*queryExpr{left, right}}
+	}
+	return left, nil
+}
+
+func (p *queryParser) not() (*queryExpr, error) {
+	switch p.peek() {
+	case "NOT":
+		p.pos++
+		arg, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &queryExpr{Op: "NOT", Args: []*queryExpr{arg}}, nil
+	case "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+	
+	var words []string
+	for p.pos < len(p.tokens) && !isQueryOperator(p.tokens[p.pos]) {
+		words = append(words, strings.Trim(p.tokens[p.pos], "\""))
+		p.pos++
+	}
+	if len(words) == 0 {
+		if p.peek() == "" {
+			return nil, errors.New("the query ends too soon")
+		}
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	term := strings.Join(words, " ")
+	if !p.caseSensitive {
+		term = foldCase(term)
+	}
+	return &queryExpr{Op: "term", Term: term}, nil
+}
+
+// String writes the expression out in full, with every operator bracketed,
+// so that queries meaning the same thing read the same.
+func (e *queryExpr) String() string {
+	switch e.Op {
+	case "AND", "OR":
+		return "(" + e.Args[0].String() + " " + e.Op + " " + e.Args[1].String() + ")"
+	case "NOT":
+		return "NOT " + e.Args[0].String()
+	}
+	return strconv.Quote(e.Term)
+}
+
+// match reports whether has, which tests for a single term, satisfies the
+// expression.
+func (e *queryExpr) match(has func(term string) bool) bool {
+	switch e.Op {
+	case "AND":
+		return e.Args[0].match(has) && e.Args[1].match(has)
+	case "OR":
+		return e.Args[0].match(has) || e.Args[1].match(has)
+	case "NOT":
+		return !e.Args[0].match(has)
+	}
+	return has(e.Term)
+}
+
+// terms returns the terms the expression looks for, leaving out those under
+// NOT.
+func (e *queryExpr) terms() []string {
+	switch e.Op {
+	case "term":
+		return []string{e.Term}
+	case "NOT":
+		return nil
+	}
+	return append(e.Args[0].terms(), e.Args[1].terms()...)
+}
+
+// Weights of the places a search term is found, for ranking results.
+const (
+	rankTitle   = 10
+	rankTag     = 5
+	rankContent = 1 // for each mention
+)
+
+// searchRank scores how well a scroll matches the search terms within
+// scope, adding the scroll's own search boost.
+func searchRank(note Note, terms []string, scope SearchScope, m searchMatcher) int {
+	rank := note.SearchBoost
+	for _, term := range terms {
+		if scope.Title && m.contains(note.Title, term) {
+			rank += rankTitle
+		}
+		if scope.Tags {
+			for _, tag := range note.Tags {
+				if m.contains(tag, term) {
+					rank += rankTag
+					break
+				}
+			}
+		}
+		if scope.Content {
+			rank += rankContent * m.count(note.Content+"\n"+note.OCRText, term)
+		}
+	}
+	return rank
+}
+
+// SetSearchBoost sets how far a scroll rises in search results.
+func (app *NotesApp) SetSearchBoost(id, boost int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	app.Notes[i].SearchBoost = boost
+	app.SaveNotes()
+	
+	if boost == 0 {
+		fmt.Printf("Scroll #%d ranks in searches by its words alone.\n", id)
+	} else {
+		fmt.Printf("Scroll #%d now has a search boost of %d.\n", id, boost)
+	}
+}
+
+// searchIndex maps each word in the archives to the scrolls holding it, so
+// that seek need only read the scrolls that can match. Digest identifies
+// the save the index was built from.
+type searchIndex struct {
+	Digest string           `json:"digest"`
+	Words  map[string][]int `json:"words"`
+}
+
+// searchIndexVersion changes whenever words are folded differently, so that
+// an index built the old way is rebuilt.
+const searchIndexVersion = "2"
+
+// indexDigest identifies a save and the way its words were indexed.
+func indexDigest(saved []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(append([]byte(searchIndexVersion+"\n"), saved...)))
+}
+
+func buildSearchIndex(notes []Note, saved []byte) searchIndex {
+	index := searchIndex{
+		Digest: indexDigest(saved),
+		Words:  make(map[string][]int),
+	}
+	for _, note := range notes {
+		seen := make(map[string]bool)
+		text := note.Title + "\n" + note.Content + "\n" + note.OCRText + "\n" + strings.Join(note.Tags, "\n")
+		for _, word := range foldedWords(text) {
+			if !seen[word] {
+				seen[word] = true
+				index.Words[word] = append(index.Words[word], note.ID)
+			}
+		}
+	}
+	return index
+}
+
+func (app *NotesApp) searchIndexPath() string {
+	return filepath.Join(app.NotesDir, "search-index.json")
+}
+
+func (app *NotesApp) writeSearchIndex(index searchIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(app.searchIndexPath(), data, 0644)
+}
+
+// loadSearchIndex reads the search index, rebuilding it when it is missing
+// or was built from other scrolls than those loaded, as after a hand edit.
+func (app *NotesApp) loadSearchIndex() searchIndex {
+	var index searchIndex
+	data, err := ioutil.ReadFile(app.searchIndexPath())
+	if err == nil && json.Unmarshal(data, &index) == nil &&
+		index.Digest == indexDigest(app.saved) {
+		return index
+	}
+	
+	index = buildSearchIndex(app.Notes, app.saved)
+	if err := app.writeSearchIndex(index); err != nil {
+		fmt.Printf("Warning: Could not update the search index: %v\n", err)
+	}
+	return index
+}
+
+// candidates returns the IDs of the scrolls that may match expr: every
+// scroll holding a term as a substring has, for each word of the term, an
+// indexed word containing it. It reports false when the index cannot narrow
+// the search, as under NOT or for a term without letters or digits.
+func (index searchIndex) candidates(expr *queryExpr) (map[int]bool, bool) {
+	switch expr.Op {
+	case "AND", "OR":
+		left, ok := index.candidates(expr.Args[0])
+		if !ok {
+			return nil, false
+		}
+		right, ok := index.candidates(expr.Args[1])
+		if !ok {
+			return nil, false
+		}
+		ids := make(map[int]bool)
+		for id := range left {
+			if expr.Op == "OR" || right[id] {
+				ids[id] = true
+			}
+		}
+		if expr.Op == "OR" {
+			for id := range right {
+				ids[id] = true
+			}
+		}
+		return ids, true
+	case "NOT":
+		return nil, false
+	}
+	
+	words := foldedWords(expr.Term)
+	if len(words) == 0 {
+		return nil, false
+	}
+	var ids map[int]bool
+	for _, part := range words {
+		found := make(map[int]bool)
+		for word, holders := range index.Words {
+			if strings.Contains(word, part) {
+				for _, id := range holders {
+					if ids == nil || ids[id] {
+						found[id] = true
+					}
+				}
+			}
+		}
+		ids = found
+	}
+	return ids, true
+}
+
+// SearchOptions controls what Search looks at and how.
+type SearchOptions struct {
+	Scope SearchScope
+	Mode  string // SearchPlain, SearchCaseSensitive or SearchRegex; empty is plain
+}
+
+// searchCacheSize is how many recent searches keep their results.
+const searchCacheSize = 8
+
+type searchCacheEntry struct {
+	key        string
+	generation int
+	notes      []Note
+}
+
+// copyNotes copies scrolls down to their runes, attachments and the values
+// they point at, so that neither the copies nor the originals change with
+// the other; saving sorts runes in place.
+func copyNotes(notes []Note) []Note {
+	copies := make([]Note, len(notes))
+	for i, note := range notes {
+		if note.Tags != nil {
+			note.Tags = append([]string{}, note.Tags...)
+		}
+		if note.Attachments != nil {
+			note.Attachments = append([]Attachment{}, note.Attachments...)
+		}
+		if note.PinnedAt != nil {
+			at := *note.PinnedAt
+			note.PinnedAt = &at
+		}
+		if note.Priority != nil {
+			priority := *note.Priority
+			note.Priority = &priority
+		}
+		if note.DueAt != nil {
+			at := *note.DueAt
+			note.DueAt = &at
+		}
+		copies[i] = note
+	}
+	return copies
+}
+
+// Search returns the scrolls matching query, best first, without printing
+// anything. The results of recent searches are kept until a scroll changes.
+func (app *NotesApp) Search(query string, opts SearchOptions) ([]Note, error) {
+	if opts.Mode == "" {
+		opts.Mode = SearchPlain
+	}
+	expr, m, err := compileSearch(query, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s in %+v as %s", expr, opts.Scope, opts.Mode)
+	if len(app.searchCache) > 0 && app.searchCache[0].generation != app.generation {
+		app.searchCache = nil
+	}
+	for i, entry := range app.searchCache {
+		if entry.key == key {
+			copy(app.searchCache[1:i+1], app.searchCache[:i])
+			app.searchCache[0] = entry
+			return copyNotes(entry.notes), nil
+		}
+	}
+	
+	scope := opts.Scope
+	var matches []Note
+	
+	// The index narrows the scrolls to read; each is still checked in full,
+	// so the results are those of reading every scroll. A regular
+	// expression is matched against every scroll.
+	candidates, indexed := map[int]bool(nil), false
+	if m.re == nil {
+		candidates, indexed = app.loadSearchIndex().candidates(expr)
+	}
+	for _, note := range app.Notes {
+		if indexed && !candidates[note.ID] {
+			continue
+		}
+		// Search in title, content, and tags, as far as the scope allows
+		has := func(term string) bool {
+			if scope.Title && m.contains(note.Title, term) ||
+				scope.Content && m.contains(note.Content+"\n"+note.OCRText, term) {
+				return true
+			}
+			for _, tag := range note.Tags {
+				if scope.Tags && m.contains(tag, term) {
+					return true
+				}
+			}
+			return false
+		}
+		if expr.match(has) {
+			matches = append(matches, note)
+		}
+	}
+	
+	matches = copyNotes(matches)
+	terms := expr.terms()
+	sort.SliceStable(matches, func(i, j int) bool {
+		return searchRank(matches[i], terms, scope, m) > searchRank(matches[j], terms, scope, m)
+	})
+	
+	app.searchCache = append([]searchCacheEntry{{key, app.generation, copyNotes(matches)}}, app.searchCache...)
+	if len(app.searchCache) > searchCacheSize {
+		app.searchCache = app.searchCache[:searchCacheSize]
+	}
+	return matches, nil
+}
+
+func (app *NotesApp) SearchNotes(query string, search SearchOptions, opts ListOptions) {
+	matches, err := app.Search(query, search)
+	if err != nil {
+		app.fail(ExitInvalid, "Error in query: %v\n", err)
+		return
+	}
+	expr, m, _ := compileSearch(query, search.Mode)
+	scope := search.Scope
+	
+	app.LastResults = nil
+	for _, note := range matches {
+		app.LastResults = append(app.LastResults, note.ID)
+	}
+	
+	if opts.Clip {
+		app.clipRendered(matches, opts.Format)
+		return
+	}
+	
+	format := opts.Format
+	if format != OutputTable {
+		app.printRendered(matches, format)
+		return
+	}
+	
+	if len(matches) == 0 {
+		fmt.Printf("No scrolls found containing '%s' in the archives\n", query)
+		return
+	}
+	
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n=== Ancient Knowledge Found: '%s' ===\n", query)
+	for i, note := range matches {
+		fmt.Fprintf(&b, "\n%d) [%d] %s (%s)\n", i+1, note.ID, note.Title, note.Type)
+		if parts := matchedParts(note, expr.terms(), scope, m); len(parts) > 0 {
+			fmt.Fprintf(&b, "Matched in: %s\n", strings.Join(parts, ", "))
+		}
+		fmt.Fprintf(&b, "Created: %s\n", note.CreatedAt.Format(app.Settings.DateFormat))
+		if len(note.Tags) > 0 {
+			fmt.Fprintf(&b, "Tags: %s\n", strings.Join(note.Tags, ", "))
+		}
+		if note.Type == "text" {
+			fmt.Fprintf(&b, "Preview: %s\n", app.preview(note.Content, 100))
+		}
+		b.WriteString(strings.Repeat("-", 40) + "\n")
+	}
+	
+	total := 0
+	for _, note := range matches {
+		for _, term := range expr.terms() {
+			total += countOccurrences(note, term, scope, m)
+		}
+	}
+	fmt.Fprintf(&b, "'%s' appears %d times across %d scrolls.\n", query, total, len(matches))
+	b.WriteString("Speak 'view-result <n>' to reveal the nth scroll found.\n")
+	app.page(b.String())
+}
+
+// ResultID maps a 1-based position in the last search results to its scroll ID.
+func (app *NotesApp) ResultID(n int) (int, bool) {
+	if n < 1 || n > len(app.LastResults) {
+		return 0, false
+	}
+	return app.LastResults[n-1], true
+}
+
+// parseTags splits comma-separated runes, trimming each, dropping empty ones
+// and duplicates (ignoring case, keeping the first spelling), and sorting the
+// result.
+func parseTags(input string) []string {
+	return normalizeTags(strings.Split(input, ","))
+}
+
+// Tag delimiters for typed runes, chosen with the tag_delimiter setting.
+const (
+	TagDelimiterComma     = "comma"
+	TagDelimiterSpace     = "space"
+	TagDelimiterSemicolon = "semicolon"
+)
+
+// splitTags splits typed runes on the given delimiter. With spaces, a
+// leading # is dropped so that "#work #urgent" reads as work and urgent.
+func splitTags(input, delimiter string) []string {
+	switch delimiter {
+	case TagDelimiterSpace:
+		fields := strings.Fields(input)
+		for i, field := range fields {
+			fields[i] = strings.TrimPrefix(field, "#")
+		}
+		return normalizeTags(fields)
+	case TagDelimiterSemicolon:
+		return normalizeTags(strings.Split(input, ";"))
+	}
+	return parseTags(input)
+}
+
+// parseTagInput splits runes typed by the seeker on the configured delimiter.
+func (app *NotesApp) parseTagInput(input string) []string {
+	return splitTags(input, app.Settings.TagDelimiter)
+}
+
+// tagInputHint describes how to separate runes, for prompts and flag help.
+func (app *NotesApp) tagInputHint() string {
+	switch app.Settings.TagDelimiter {
+	case TagDelimiterSpace:
+		return "space-separated"
+	case TagDelimiterSemicolon:
+		return "semicolon-separated"
+	}
+	return "comma-separated"
+}
+
+// normalizeTags trims, de-duplicates and sorts runes as parseTags does.
+func normalizeTags(input []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range input {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+	
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
+	})
+	return tags
+}
+
+// Tag matching modes understood by containsTag. General searches match runes
+// by substring, while rune-specific commands default to exact matches so that
+// "go" does not also find "golang" or "ago". An exact match also takes in the
+// rune's descendants, so "work" finds "work/project/ui".
+const (
+	TagMatchExact     = "exact"
+	TagMatchPrefix    = "prefix"
+	TagMatchSubstring = "substring"
+)
+
+func (app *NotesApp) containsTag(tags []string, query string, mode string) bool {
+	query = foldCase(query)
+	for _, tag := range tags {
+		tag = foldCase(tag)
+		switch mode {
+		case TagMatchExact:
+			if tag == query || strings.HasPrefix(tag, query+"/") {
+				return true
+			}
+		case TagMatchPrefix:
+			if strings.HasPrefix(tag, query) {
+				return true
+			}
+		default:
+			if strings.Contains(tag, query) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagNode is a level of the rune hierarchy formed by "/"-delimited runes.
+// Count is the number of scrolls bearing the rune or any descendant.
+type tagNode struct {
+	Name     string
+	Count    int
+	Children map[string]*tagNode
+}
+
+// buildTagTree arranges the scrolls' runes into a hierarchy, ignoring case.
+func buildTagTree(notes []Note) *tagNode {
+	root := &tagNode{Children: make(map[string]*tagNode)}
+	for _, note := range notes {
+		counted := make(map[*tagNode]bool)
+		for _, tag := range note.Tags {
+			node := root
+			for _, part := range strings.Split(strings.ToLower(tag), "/") {
+				if part = strings.TrimSpace(part); part == "" {
+					continue
+				}
+				child, ok := node.Children[part]
+				if !ok {
+					child = &tagNode{Name: part, Children: make(map[string]*tagNode)}
+					node.Children[part] = child
+				}
+				if !counted[child] {
+					child.Count++
+					counted[child] = true
+				}
+				node = child
+			}
+		}
+	}
+	return root
+}
+
+// writeTagTree draws a node's children beneath it, sorted by name.
+func writeTagTree(b *strings.Builder, node *tagNode, indent string) {
+	names := make([]string, 0, len(node.Children))
+	for name := range node.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	for i, name := range names {
+		child := node.Children[name]
+		branch, next := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintf(b, "%s%s%s (%d)\n", indent, branch, child.Name, child.Count)
+		writeTagTree(b, child, indent+next)
+	}
+}
+
+// ShowTagTree prints the rune hierarchy with the number of scrolls at each
+// level.
+func (app *NotesApp) ShowTagTree() {
+	root := buildTagTree(app.Notes)
+	if len(root.Children) == 0 {
+		fmt.Println("No scrolls bear any runes.")
+		return
+	}
+	
+	var b strings.Builder
+	b.WriteString("\n=== The Tree of Runes ===\n")
+	writeTagTree(&b, root, "")
+	app.page(b.String())
+}
+
+func (app *NotesApp) FilterByTag(tag string, mode string) {
+	var matches []Note
+	for _, note := range app.Notes {
+		if app.containsTag(note.Tags, tag, mode) {
+			matches = append(matches, note)
+		}
+	}
+	
+	if len(matches) == 0 {
+		fmt.Printf("No scrolls bear the rune '%s' (%s match)\n", tag, mode)
+		return
+	}
+	
+	fmt.Printf("\n=== Scrolls Bearing the Rune '%s' (%s match) ===\n", tag, mode)
+	app.printRendered(matches, OutputTable)
+}
+
+func (app *NotesApp) EditScroll(id int) {
+	if app.isLocked(id) {
+		return
+	}
+	
+	for i, note := range app.Notes {
+		if note.ID == id {
+			reader := stdin
+			
+			fmt.Printf("\n=== Modifying Ancient Scroll #%d ===\n", note.ID)
+			fmt.Printf("Current Title: %s\n", note.Title)
+			fmt.Printf("Type: %s\n", note.Type)
+			
+			if note.Type == "text" {
+				// Edit text scroll
+				fmt.Print("Enter new title (press Enter to keep current): ")
+				newTitle, _ := reader.ReadString('\n')
+				newTitle = strings.TrimSpace(newTitle)
+				if newTitle != "" {
+					app.Notes[i].Title = newTitle
+				}
+				
+				fmt.Printf("Current content:\n%s\n\n", note.Content)
+				fmt.Println("Enter new content, ending with a line holding only '.' or Ctrl+D (press Enter to keep current):")
+				newContent := readMultiline(reader)
+				if newContent != "" {
+					app.Notes[i].Content = newContent
+				}
+			} else {
+				// Edit image scroll title only
+				fmt.Print("Enter new title (press Enter to keep current): ")
+				newTitle, _ := reader.ReadString('\n')
+				newTitle = strings.TrimSpace(newTitle)
+				if newTitle != "" {
+					app.Notes[i].Title = newTitle
+				}
+			}
+			
+			// Edit tags for both types
+			if len(note.Tags) > 0 {
+				fmt.Printf("Current runes (tags): %s\n", strings.Join(note.Tags, ", "))
+			} else {
+				fmt.Println("Current runes (tags): none")
+			}
+			fmt.Printf("Enter new runes (%s, press Enter to keep current): ", app.tagInputHint())
+			newTagsInput, _ := reader.ReadString('\n')
+			newTagsInput = strings.TrimSpace(newTagsInput)
+			
+			if newTagsInput != "" {
+				app.Notes[i].Tags = app.parseTagInput(newTagsInput)
+			}
+			
+			app.Notes[i].UpdatedAt = time.Now()
+			app.SaveNotes()
+			fmt.Printf("Scroll #%d has been modified in the archives.\n", id)
+			return
+		}
+	}
+	app.notFound(id)
+}
+
+// noteUpdate holds the fields to change on a scroll; nil fields are left as
+// they are.
+type noteUpdate struct {
+	Title   *string
+	Tags    *[]string
+	Content *string
+	Append  bool // append Content to the existing content instead of replacing it
+	Literal bool // append Content as it is, without filling in {{date}} and {{time}}
+}
+
+// editorResult carries back the content of a scroll edited in the editor.
+type editorResult struct {
+	ID      int
+	Content string
+	Err     error
+}
+
+// isLocked reports, and refuses, an edit of a scroll that is still open in
+// the editor. Locks last only for the session.
+func (app *NotesApp) isLocked(id int) bool {
+	app.collectEditorResults()
+	if holder, ok := app.locks[id]; ok {
+		app.fail(ExitInvalid, "Scroll #%d is being edited (%s); finish that edit first.\n", id, holder)
+		return true
+	}
+	return false
+}
+
+// EditInEditor opens a text scroll's content in the preferred editor. In the
+// background the menu stays usable, and the scroll stays locked against other
+// edits until the editor closes.
+func (app *NotesApp) EditInEditor(id int, background bool) {
+	if app.isLocked(id) {
+		return
+	}
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	if app.Notes[i].Type != "text" {
+		app.fail(ExitInvalid, "Scroll #%d is not a text scroll. Cannot open it in an editor.\n", id)
+		return
+	}
+	
+	editor := strings.Fields(app.Settings.Editor)
+	if len(editor) == 0 {
+		app.fail(ExitInvalid, "No editor is configured; run with --reconfigure to choose one.\n")
+		return
+	}
+	
+	tmp, err := ioutil.TempFile("", fmt.Sprintf("scroll-%d-*.md", id))
+	if err != nil {
+		app.fail(ExitIOError, "Error preparing scroll for editing: %v\n", err)
+		return
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.WriteString(app.Notes[i].Content)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		app.fail(ExitIOError, "Error preparing scroll for editing: %v\n", err)
+		return
+	}
+	
+	cmd := exec.Command(editor[0], append(editor[1:], tmpPath)...)
+	wait := func() editorResult {
+		defer os.Remove(tmpPath)
+		if err := cmd.Wait(); err != nil {
+			return editorResult{ID: id, Err: err}
+		}
+		data, err := ioutil.ReadFile(tmpPath)
+		return editorResult{ID: id, Content: string(data), Err: err}
+	}
+	
+	if !background {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	}
+	if err := cmd.Start(); err != nil {
+		os.Remove(tmpPath)
+		app.fail(ExitIOError, "Error starting editor: %v\n", err)
+		return
+	}
+	
+	app.locks[id] = "open in " + editor[0]
+	if !background {
+		app.applyEditorResult(wait())
+		return
+	}
+	
+	go func() { app.editorDone <- wait() }()
+	fmt.Printf("Scroll #%d is open in %s; other edits to it are refused until the editor closes.\n", id, editor[0])
+}
+
+// applyEditorResult stores an edited scroll's content and releases its lock.
+func (app *NotesApp) applyEditorResult(r editorResult) {
+	delete(app.locks, r.ID)
+	if r.Err != nil {
+		app.fail(ExitIOError, "Error editing scroll #%d: %v\n", r.ID, r.Err)
+		return
+	}
+	
+	i := app.noteIndex(r.ID)
+	if i < 0 {
+		app.fail(ExitNotFound, "Scroll #%d vanished while it was being edited; the edit was not saved.\n", r.ID)
+		return
+	}
+	
+	content := strings.TrimRight(r.Content, "\n")
+	if content == app.Notes[i].Content {
+		fmt.Printf("Scroll #%d was closed unchanged.\n", r.ID)
+		return
+	}
+	app.Notes[i].Content = content
+	app.Notes[i].UpdatedAt = time.Now()
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d has been rewritten from the editor.\n", r.ID)
+}
+
+// collectEditorResults applies the edits of any editors that have closed.
+func (app *NotesApp) collectEditorResults() {
+	for {
+		select {
+		case r := <-app.editorDone:
+			app.applyEditorResult(r)
+		default:
+			return
+		}
+	}
+}
+
+// waitForEditors blocks until every editor opened in the background closes.
+func (app *NotesApp) waitForEditors() {
+	app.collectEditorResults()
+	if len(app.locks) > 0 {
+		fmt.Printf("Waiting for %d open editors to close...\n", len(app.locks))
+	}
+	for len(app.locks) > 0 {
+		app.applyEditorResult(<-app.editorDone)
+	}
+}
+
+// expandEntry fills in the {{date}} and {{time}} placeholders of text being
+// appended to a scroll, for timestamped entries.
+func expandEntry(text string, now time.Time) (string, error) {
+	tmpl, err := template.New("entry").Funcs(template.FuncMap{
+		"date": func() string { return now.Format("2006-01-02") },
+		"time": func() string { return now.Format("15:04") },
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// UpdateNote applies only the supplied fields to a scroll in a single save.
+func (app *NotesApp) UpdateNote(id int, update noteUpdate) {
+	if app.isLocked(id) {
+		return
+	}
+	
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	if update.Title == nil && update.Tags == nil && update.Content == nil {
+		app.fail(ExitInvalid, "Nothing to update: give --title, --tags, --content or --content-file.\n")
+		return
+	}
+	if update.Content != nil && app.Notes[i].Type != "text" {
+		app.fail(ExitInvalid, "Scroll #%d is not a text scroll. Cannot change its content.\n", id)
+		return
+	}
+	if update.Title != nil && strings.TrimSpace(*update.Title) == "" {
+		app.fail(ExitInvalid, "A scroll's title cannot be empty.\n")
+		return
+	}
+	if update.Content != nil && update.Append && !update.Literal {
+		text, err := expandEntry(*update.Content, time.Now())
+		if err != nil {
+			app.fail(ExitInvalid, "Error in the appended text: %v\n", err)
+			return
+		}
+		update.Content = &text
+	}
+	
+	note := &app.Notes[i]
+	if update.Title != nil {
+		note.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.Tags != nil {
+		note.Tags = *update.Tags
+	}
+	if update.Content != nil {
+		if update.Append && note.Content != "" {
+			note.Content += "\n" + *update.Content
+		} else {
+			note.Content = *update.Content
+		}
+	}
+	note.UpdatedAt = time.Now()
+	
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d has been updated in the archives.\n", id)
+}
+
+// missingImages returns the indexes of image scrolls whose file is gone.
+func (app *NotesApp) missingImages() []int {
+	var missing []int
+	for i, note := range app.Notes {
+		if note.Type != "screenshot" || note.FilePath == "" {
+			continue
+		}
+		if _, err := os.Stat(note.FilePath); os.IsNotExist(err) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// ShowMissingImages lists the image scrolls whose captured image is gone.
+// With fix set to "convert" they become text scrolls; with "clear" they keep
+// their type but forget the file.
+func (app *NotesApp) ShowMissingImages(fix string) {
+	missing := app.missingImages()
+	if len(missing) == 0 {
+		fmt.Println("Every captured image rests where its scroll expects it.")
+		return
+	}
+	
+	fmt.Printf("\n=== Scrolls Missing Their Images ===\n")
+	for _, i := range missing {
+		note := app.Notes[i]
+		fmt.Printf("[%d] %s: %s\n", note.ID, note.Title, note.FilePath)
+		
+		switch fix {
+		case "convert":
+			app.Notes[i].Type = "text"
+			if app.Notes[i].Content == "" {
+				app.Notes[i].Content = fmt.Sprintf("(The captured image %s was lost.)", note.Screenshot)
+			}
+			fallthrough
+		case "clear":
+			app.Notes[i].FilePath = ""
+			app.Notes[i].Screenshot = ""
+			app.Notes[i].UpdatedAt = time.Now()
+		}
+	}
+	
+	switch fix {
+	case "convert":
+		app.SaveNotes()
+		fmt.Printf("%d scrolls have become text scrolls.\n", len(missing))
+	case "clear":
+		app.SaveNotes()
+		fmt.Printf("%d scrolls no longer point at their lost images.\n", len(missing))
+	default:
+		fmt.Printf("%d scrolls are missing their images. Use --convert or --clear to mend them.\n", len(missing))
+	}
+}
+
+// readImageText runs OCR over an image with tesseract. It is a variable so
+// that another engine can stand in for it.
+var readImageText = func(path string) (string, error) {
+	out, err := exec.Command("tesseract", path, "stdout").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// BackfillOCR reads the text of every captured image that has none yet,
+// saving after each so that an interruption loses little.
+func (app *NotesApp) BackfillOCR() {
+	var pending []int
+	skipped := 0
+	for i, note := range app.Notes {
+		if note.Type != "screenshot" || note.FilePath == "" {
+			continue
+		}
+		if note.OCRText != "" {
+			skipped++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	
+	if len(pending) == 0 {
+		fmt.Printf("No captured images await reading (%d already read).\n", skipped)
+		return
+	}
+	
+	read := 0
+	for n, i := range pending {
+		note := app.Notes[i]
+		fmt.Printf("[%d/%d] #%d %s... ", n+1, len(pending), note.ID, note.Title)
+		text, err := readImageText(note.FilePath)
+		if errors.Is(err, exec.ErrNotFound) {
+			fmt.Println()
+			app.fail(ExitIOError, "Error reading images: tesseract is not installed.\n")
+			return
+		}
+		if err != nil {
+			fmt.Printf("failed: %v\n", err)
+			continue
+		}
+		if text == "" {
+			fmt.Println("no text found")
+			continue
+		}
+		
+		app.Notes[i].OCRText = text
+		app.SaveNotes()
+		read++
+		fmt.Printf("%d characters\n", len([]rune(text)))
+	}
+	fmt.Printf("Read the text of %d captured images; %d had been read before.\n", read, skipped)
+}
+
+// RenameScreenshot gives a captured image a file name made from its scroll's
+// title, numbering it when another image already has that name.
+func (app *NotesApp) RenameScreenshot(id int) {
+	if app.isLocked(id) {
+		return
+	}
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	note := app.Notes[i]
+	if note.Type != "screenshot" || note.FilePath == "" {
+		app.fail(ExitInvalid, "Scroll #%d has no captured image to rename.\n", id)
+		return
+	}
+	
+	dir := filepath.Dir(note.FilePath)
+	ext := filepath.Ext(note.Screenshot)
+	slug := slugify(note.Title)
+	name := slug + ext
+	named := regexp.MustCompile(`^` + regexp.QuoteMeta(slug) + `(-\d+)?` + regexp.QuoteMeta(ext) + `$`)
+	if named.MatchString(note.Screenshot) {
+		fmt.Printf("The captured image of scroll #%d is already named %s.\n", id, note.Screenshot)
+		return
+	}
+	
+	var newPath string
+	for n := 2; ; n++ {
+		path, err := safeJoin(dir, name)
+		if err != nil {
+			app.fail(ExitInvalid, "Error: %v\n", err)
+			return
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			newPath = path
+			break
+		}
+		name = fmt.Sprintf("%s-%d%s", slug, n, ext)
+	}
+	
+	if err := os.Rename(note.FilePath, newPath); err != nil {
+		app.fail(ExitIOError, "Error renaming captured image: %v\n", err)
+		return
+	}
+	app.Notes[i].Screenshot = name
+	app.Notes[i].FilePath = newPath
+	app.SaveNotes()
+	
+	fmt.Printf("The captured image of scroll #%d is now named %s.\n", id, name)
+}
+
+// TouchNote marks a scroll as updated now without changing anything else.
+func (app *NotesApp) TouchNote(id int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	app.Notes[i].UpdatedAt = time.Now()
+	app.SaveNotes()
+	fmt.Printf("Scroll #%d has been touched.\n", id)
+}
+
+// parsePatch reads a JSON patch holding any of "title", "content" and
+// "tags", rejecting unknown fields and values of the wrong type.
+func parsePatch(data []byte) (noteUpdate, error) {
+	var update noteUpdate
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return update, fmt.Errorf("patch is not a JSON object: %v", err)
+	}
+	
+	for name, raw := range fields {
+		switch name {
+		case "title":
+			var title string
+			if err := json.Unmarshal(raw, &title); err != nil {
+				return update, fmt.Errorf("title must be a string")
+			}
+			update.Title = &title
+		case "content":
+			var content string
+			if err := json.Unmarshal(raw, &content); err != nil {
+				return update, fmt.Errorf("content must be a string")
+			}
+			update.Content = &content
+		case "tags":
+			var tags []string
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return update, fmt.Errorf("tags must be a list of strings")
+			}
+			tags = normalizeTags(tags)
+			update.Tags = &tags
+		default:
+			return update, fmt.Errorf("unknown field %q (allowed: title, content, tags)", name)
+		}
+	}
+	return update, nil
+}
+
+func (app *NotesApp) RetitleScroll(id int) {
+	if app.isLocked(id) {
+		return
+	}
+	
+	for i, note := range app.Notes {
+		if note.ID == id {
+			reader := stdin
+			
+			fmt.Printf("Current title: %s\n", note.Title)
+			fmt.Print("Enter new title: ")
+			newTitle, _ := reader.ReadString('\n')
+			newTitle = strings.TrimSpace(newTitle)
+			
+			if newTitle != "" {
+				app.Notes[i].Title = newTitle
+				app.Notes[i].UpdatedAt = time.Now()
+				app.SaveNotes()
+				fmt.Printf("Scroll #%d has been retitled to: %s\n", id, newTitle)
+			} else {
+				fmt.Println("Title unchanged.")
+			}
+			return
+		}
+	}
+	app.notFound(id)
+}
+
+func (app *NotesApp) RetagScroll(id int) {
+	if app.isLocked(id) {
+		return
+	}
+	
+	for i, note := range app.Notes {
+		if note.ID == id {
+			reader := stdin
+			
+			if len(note.Tags) > 0 {
+				fmt.Printf("Current runes (tags): %s\n", strings.Join(note.Tags, ", "))
+			} else {
+				fmt.Println("Current runes (tags): none")
+			}
+			
+			fmt.Printf("Enter new runes (%s, leave empty to remove all): ", app.tagInputHint())
+			newTagsInput, _ := reader.ReadString('\n')
+			newTagsInput = strings.TrimSpace(newTagsInput)
+			
+			newTags := app.parseTagInput(newTagsInput)
+			
+			app.Notes[i].Tags = newTags
+			app.Notes[i].UpdatedAt = time.Now()
+			app.SaveNotes()
+			
+			if len(newTags) > 0 {
+				fmt.Printf("Scroll #%d runes updated to: %s\n", id, strings.Join(newTags, ", "))
+			} else {
+				fmt.Printf("All runes removed from scroll #%d\n", id)
+			}
+			return
+		}
+	}
+	app.notFound(id)
+}
+
+// slugify turns a scroll title into a safe file name stem: letters and digits
+// (including non-ASCII ones) are kept, every other character, including path
+// separators, dots and control characters, becomes a single hyphen.
+func slugify(title string) string {
+	var b strings.Builder
+	lastHyphen := true
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			b.WriteRune(r)
+			lastHyphen = false
+		} else if !lastHyphen {
+			b.WriteRune('-')
+			lastHyphen = true
+		}
+	}
+	
+	slug := strings.Trim(b.String(), "-")
+	if runes := []rune(slug); len(runes) > 80 {
+		slug = strings.Trim(string(runes[:80]), "-")
+	}
+	if slug == "" {
+		slug = "scroll"
+	}
+	return slug
+}
+
+// safeJoin joins name onto dir, refusing any result that would land outside dir.
+func safeJoin(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("file name %q escapes %s", name, dir)
+	}
+	return path, nil
+}
+
+// noteIndex returns the position of the scroll with the given ID in
+// app.Notes, or -1 if no such scroll exists.
+func (app *NotesApp) noteIndex(id int) int {
+	for i, note := range app.Notes {
+		if note.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// diffLines compares two texts line by line using their longest common
+// subsequence and returns the lines of b's changes against a, each prefixed
+// with "-" (removed), "+" (added) or " " (unchanged).
+func diffLines(a, b string) []string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+	
+	// lcs[i][j] is the length of the common subsequence of aLines[i:] and bLines[j:]
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	
+	var out []string
+	i, j := 0, 0
+	for i < len(aLines) && j < len(bLines) {
+		switch {
+		case aLines[i] == bLines[j]:
+			out = append(out, " "+aLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+aLines[i])
+			i++
+		default:
+			out = append(out, "+"+bLines[j])
+			j++
+		}
+	}
+	for ; i < len(aLines); i++ {
+		out = append(out, "-"+aLines[i])
+	}
+	for ; j < len(bLines); j++ {
+		out = append(out, "+"+bLines[j])
+	}
+	return out
+}
+
+func (app *NotesApp) DiffScrolls(idA, idB int) {
+	a := app.noteIndex(idA)
+	if a < 0 {
+		app.notFound(idA)
+		return
+	}
+	b := app.noteIndex(idB)
+	if b < 0 {
+		app.notFound(idB)
+		return
+	}
+	
+	fmt.Printf("--- #%d %s\n", idA, app.Notes[a].Title)
+	fmt.Printf("+++ #%d %s\n", idB, app.Notes[b].Title)
+	for _, line := range diffLines(app.Notes[a].Content, app.Notes[b].Content) {
+		fmt.Println(line)
+	}
+}
+
+// termFrequencies counts the words of a scroll's title, content and
+// recognised image text, folded for case.
+func termFrequencies(note Note) map[string]float64 {
+	terms := make(map[string]float64)
+	for _, word := range foldedWords(note.Title + "\n" + note.Content + "\n" + note.OCRText) {
+		terms[word]++
+	}
+	return terms
+}
+
+// foldedWords splits text into case-folded runs of letters and digits.
+func foldedWords(text string) []string {
+	return strings.FieldsFunc(foldCase(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// cosineSimilarity compares two term-frequency vectors, from 0 (no words in
+// common) to 1 (the same words in the same proportions).
+func cosineSimilarity(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, n := range a {
+		dot += n * b[term]
+		normA += n * n
+	}
+	for _, n := range b {
+		normB += n * n
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// ShowSimilar lists the scrolls whose words most resemble those of a given
+// scroll, so that a thought already noted can be found before it is noted
+// again.
+func (app *NotesApp) ShowSimilar(id, top int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	target := termFrequencies(app.Notes[i])
+	
+	type match struct {
+		note  Note
+		score float64
+	}
+	var matches []match
+	for _, note := range app.Notes {
+		if note.ID == id {
+			continue
+		}
+		if score := cosineSimilarity(target, termFrequencies(note)); score > 0 {
+			matches = append(matches, match{note, score})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+	if len(matches) > top {
+		matches = matches[:top]
+	}
+	
+	if len(matches) == 0 {
+		fmt.Printf("No scroll shares any words with scroll #%d.\n", id)
+		return
+	}
+	fmt.Printf("\n=== Scrolls Resembling #%d: %s ===\n", id, app.Notes[i].Title)
+	app.LastResults = nil
+	for n, m := range matches {
+		fmt.Printf("%d) [%d] %s (%.0f%% alike)\n", n+1, m.note.ID, m.note.Title, m.score*100)
+		app.LastResults = append(app.LastResults, m.note.ID)
+	}
+}
+
+// formatSize writes a size in bytes the way people read it.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
+
+// ShowBiggest lists the n largest scrolls: text scrolls by the length of
+// their content and image scrolls by the size of their image file.
+func (app *NotesApp) ShowBiggest(n int) {
+	type sized struct {
+		note    Note
+		size    int64
+		missing bool
+	}
+	var scrolls []sized
+	for _, note := range app.Notes {
+		entry := sized{note: note, size: int64(len(note.Content))}
+		if note.Type == "screenshot" {
+			if info, err := os.Stat(note.FilePath); err == nil {
+				entry.size = info.Size()
+			} else {
+				entry.missing = true
+			}
+		}
+		scrolls = append(scrolls, entry)
+	}
+	if len(scrolls) == 0 {
+		fmt.Println("No scrolls found in the archives.")
+		return
+	}
+	
+	sort.SliceStable(scrolls, func(i, j int) bool { return scrolls[i].size > scrolls[j].size })
+	if len(scrolls) > n {
+		scrolls = scrolls[:n]
+	}
+	
+	fmt.Println("\n=== The Weightiest Scrolls ===")
+	app.LastResults = nil
+	for i, s := range scrolls {
+		size := formatSize(s.size)
+		if s.missing {
+			size = "image missing"
+		}
+		fmt.Printf("%d) [%d] %s (%s, %s)\n", i+1, s.note.ID, s.note.Title, s.note.Type, size)
+		app.LastResults = append(app.LastResults, s.note.ID)
+	}
+}
+
+// scrollStats summarises a set of scrolls.
+type scrollStats struct {
+	Count        int
+	Text         int
+	Screenshots  int
+	FirstCreated time.Time
+	LastCreated  time.Time
+	LastUpdated  time.Time
+	AvgLength    float64 // average content length of text scrolls, in characters
+}
+
+func computeStats(notes []Note) scrollStats {
+	var stats scrollStats
+	totalLength := 0
+	
+	for _, note := range notes {
+		stats.Count++
+		if note.Type == "text" {
+			stats.Text++
+			totalLength += len([]rune(note.Content))
+		} else {
+			stats.Screenshots++
+		}
+		
+		if stats.FirstCreated.IsZero() || note.CreatedAt.Before(stats.FirstCreated) {
+			stats.FirstCreated = note.CreatedAt
+		}
+		if note.CreatedAt.After(stats.LastCreated) {
+			stats.LastCreated = note.CreatedAt
+		}
+		if note.UpdatedAt.After(stats.LastUpdated) {
+			stats.LastUpdated = note.UpdatedAt
+		}
+	}
+	
+	if stats.Text > 0 {
+		stats.AvgLength = float64(totalLength) / float64(stats.Text)
+	}
+	return stats
+}
+
+// StatsOptions narrows the scrolls that stats measures. Since and Until bound
+// the creation time; Until is exclusive and either may be zero.
+type StatsOptions struct {
+	Tag   string
+	Since time.Time
+	Until time.Time
+}
+
+// ShowStats prints metrics for the whole archive, or only for the scrolls
+// that opts selects.
+func (app *NotesApp) ShowStats(opts StatsOptions) {
+	tag := opts.Tag
+	var notes []Note
+	for _, note := range app.Notes {
+		if tag != "" && !app.containsTag(note.Tags, tag, TagMatchExact) {
+			continue
+		}
+		if !opts.Since.IsZero() && note.CreatedAt.Before(opts.Since) {
+			continue
+		}
+		if !opts.Until.IsZero() && !note.CreatedAt.Before(opts.Until) {
+			continue
+		}
+		notes = append(notes, note)
+	}
+	
+	heading := "=== Measures of the Archives ==="
+	if tag != "" {
+		heading = fmt.Sprintf("=== Measures of the Rune '%s' ===", tag)
+	}
+	
+	stats := computeStats(notes)
+	fmt.Printf("\n%s\n", heading)
+	scoped := !opts.Since.IsZero() || !opts.Until.IsZero()
+	if scoped {
+		from, to := "the beginning", "now"
+		if !opts.Since.IsZero() {
+			from = opts.Since.Format(app.Settings.DateFormat)
+		}
+		if !opts.Until.IsZero() {
+			to = opts.Until.Format(app.Settings.DateFormat)
+		}
+		fmt.Printf("Inscribed from %s until %s\n", from, to)
+	}
+	fmt.Printf("Scrolls: %d (%d text, %d captured images)\n", stats.Count, stats.Text, stats.Screenshots)
+	if stats.Count == 0 {
+		return
+	}
+	fmt.Printf("First inscribed: %s\n", stats.FirstCreated.Format(app.Settings.DateFormat))
+	fmt.Printf("Last inscribed: %s\n", stats.LastCreated.Format(app.Settings.DateFormat))
+	fmt.Printf("Last updated: %s\n", stats.LastUpdated.Format(app.Settings.DateFormat))
+	fmt.Printf("Average length: %.1f characters\n", stats.AvgLength)
+	if tag == "" && !scoped {
+		app.showWordGoal(time.Now())
+	}
+}
+
+// wordsWrittenOn counts the words in text scrolls created on the same
+// calendar day as day, in day's location.
+func wordsWrittenOn(notes []Note, day time.Time) int {
+	y, m, d := day.Date()
+	words := 0
+	for _, note := range notes {
+		cy, cm, cd := note.CreatedAt.In(day.Location()).Date()
+		if note.Type == "text" && cy == y && cm == m && cd == d {
+			words += len(strings.Fields(note.Content))
+		}
+	}
+	return words
+}
+
+// progressBar draws done out of goal as a bar of the given width.
+func progressBar(done, goal, width int) string {
+	if goal <= 0 {
+		return ""
+	}
+	filled := done * width / goal
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("[%s%s] %d%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), done*100/goal)
+}
+
+func (app *NotesApp) showWordGoal(now time.Time) {
+	words := wordsWrittenOn(app.Notes, now)
+	if app.Settings.DailyWordGoal <= 0 {
+		fmt.Printf("Words written today: %d (set a daily goal with 'goal <words>')\n", words)
+		return
+	}
+	fmt.Printf("Words written today: %d / %d %s\n", words, app.Settings.DailyWordGoal, progressBar(words, app.Settings.DailyWordGoal, 20))
+}
+
+// ShowToday lists the scrolls inscribed today and progress toward the daily goal.
+func (app *NotesApp) ShowToday() {
+	now := time.Now()
+	y, m, d := now.Date()
+	
+	fmt.Println("\n=== Today's Inscriptions ===")
+	for _, note := range app.Notes {
+		cy, cm, cd := note.CreatedAt.In(now.Location()).Date()
+		if cy == y && cm == m && cd == d {
+			fmt.Printf("[%d] %s (%s)\n", note.ID, note.Title, note.Type)
+		}
+	}
+	app.showWordGoal(now)
+}
+
+// SetWordGoal records the daily word-count goal in the settings file.
+func (app *NotesApp) SetWordGoal(words int) {
+	app.Settings.DailyWordGoal = words
+	
+	// Save over the file's own settings so that environment and flag
+	// overrides are not written into it.
+	fileSettings, _ := LoadSettings(settingsPath())
+	fileSettings.DailyWordGoal = words
+	if err := SaveSettings(settingsPath(), fileSettings); err != nil {
+		app.fail(ExitIOError, "Error saving settings: %v\n", err)
+		return
+	}
+	if words > 0 {
+		fmt.Printf("Your daily goal is now %d words.\n", words)
+	} else {
+		fmt.Println("Your daily word goal has been cleared.")
+	}
+}
+
+// nearestIDs returns up to n existing scroll IDs closest to id.
+func (app *NotesApp) nearestIDs(id, n int) []int {
+	var ids []int
+	for _, note := range app.Notes {
+		ids = append(ids, note.ID)
+	}
+	
+	distance := func(other int) int {
+		if other > id {
+			return other - id
+		}
+		return id - other
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if distance(ids[i]) != distance(ids[j]) {
+			return distance(ids[i]) < distance(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// suggestScrolls prints the given scrolls as "did you mean" suggestions.
+func (app *NotesApp) suggestScrolls(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	var suggestions []string
+	for _, id := range ids {
+		if i := app.noteIndex(id); i >= 0 {
+			suggestions = append(suggestions, fmt.Sprintf("#%d %s", id, app.Notes[i].Title))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Did you mean: %s?\n", strings.Join(suggestions, ", "))
+}
+
+// notFound reports a missing scroll ID along with the nearest existing ones.
+func (app *NotesApp) notFound(id int) {
+	app.fail(ExitNotFound, "Scroll with ID %d not found in the archives.\n", id)
+	app.suggestScrolls(app.nearestIDs(id, 3))
+}
+
+// invalidID reports input that is not a scroll ID, suggesting scrolls whose
+// titles match it in case a title was typed by mistake.
+func (app *NotesApp) invalidID(input string) {
+	app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+	
+	query := strings.ToLower(strings.TrimSpace(input))
+	if query == "" {
+		return
+	}
+	var ids []int
+	for _, note := range app.Notes {
+		if strings.Contains(strings.ToLower(note.Title), query) && len(ids) < 5 {
+			ids = append(ids, note.ID)
+		}
+	}
+	app.suggestScrolls(ids)
+}
+
+// dateLayouts are the layouts accepted when a date is typed on the command line.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	time.RFC3339,
+	"01/02/2006",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+}
+
+// parseDate reads a date in local time, trying the settings' date format
+// before the common layouts.
+func (app *NotesApp) parseDate(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	layouts := append([]string{app.Settings.DateFormat}, dateLayouts...)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, input, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q (try YYYY-MM-DD)", input)
+}
+
+// changesSince groups the scrolls created or updated after since by the day
+// of their latest change, oldest day first.
+func changesSince(notes []Note, since time.Time) ([]string, map[string][]Note) {
+	groups := make(map[string][]Note)
+	var days []string
+	
+	var changed []Note
+	for _, note := range notes {
+		if note.CreatedAt.After(since) || note.UpdatedAt.After(since) {
+			changed = append(changed, note)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].UpdatedAt.Before(changed[j].UpdatedAt)
+	})
+	
+	for _, note := range changed {
+		day := note.UpdatedAt.Local().Format("2006-01-02")
+		if _, ok := groups[day]; !ok {
+			days = append(days, day)
+		}
+		groups[day] = append(groups[day], note)
+	}
+	return days, groups
+}
+
+// ShowSince prints a day-by-day digest of what changed after since.
+func (app *NotesApp) ShowSince(since time.Time) {
+	days, groups := changesSince(app.Notes, since)
+	if len(days) == 0 {
+		fmt.Printf("Nothing has changed in the archives since %s.\n", since.Format(app.Settings.DateFormat))
+		return
+	}
+	
+	fmt.Printf("\n=== Changes Since %s ===\n", since.Format(app.Settings.DateFormat))
+	for _, day := range days {
+		fmt.Printf("\n%s\n", day)
+		for _, note := range groups[day] {
+			change := "updated"
+			if note.CreatedAt.After(since) {
+				change = "new"
+			}
+			fmt.Printf("  [%d] %s (%s, %s)\n", note.ID, note.Title, note.Type, change)
+		}
+	}
+}
+
+// parseSpan reads a span of time such as "7d", "2w" or "36h".
+func parseSpan(input string) (time.Duration, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(input, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(input, suffix))
+			if err != nil || n <= 0 {
+				break
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	if d, err := time.ParseDuration(input); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("unrecognised span %q (try 7d, 2w or 36h)", input)
+}
+
+// digestTopTags is how many of the most used runes a digest names.
+const digestTopTags = 5
+
+// digest writes a plain-text summary of the scrolls created and updated
+// between since and until, for pasting into an email.
+func (app *NotesApp) digest(since, until time.Time) string {
+	var created, updated []Note
+	tagCounts := make(map[string]int)
+	for _, note := range app.Notes {
+		switch {
+		case !note.CreatedAt.Before(since) && note.CreatedAt.Before(until):
+			created = append(created, note)
+			for _, tag := range note.Tags {
+				tagCounts[tag]++
+			}
+		case !note.UpdatedAt.Before(since) && note.UpdatedAt.Before(until):
+			updated = append(updated, note)
+		}
+	}
+	sort.Slice(created, func(i, j int) bool { return created[i].CreatedAt.Before(created[j].CreatedAt) })
+	sort.Slice(updated, func(i, j int) bool { return updated[i].UpdatedAt.Before(updated[j].UpdatedAt) })
+	
+	var b strings.Builder
+	layout := "2006-01-02"
+	fmt.Fprintf(&b, "Digest of the Ancient Scrolls, %s to %s\n\n", since.Format(layout), until.Format(layout))
+	fmt.Fprintf(&b, "%d new scrolls, %d updated, %d in the archives.\n", len(created), len(updated), len(app.Notes))
+	
+	if len(created) > 0 {
+		b.WriteString("\nNew scrolls:\n")
+		for _, note := range created {
+			line := fmt.Sprintf("- #%d %s", note.ID, note.Title)
+			if note.Type == "text" {
+				if p := app.preview(note.Content, 60); p != "" {
+					line += ": " + p
+				}
+			} else {
+				line += " (captured image)"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	
+	if len(updated) > 0 {
+		b.WriteString("\nUpdated scrolls:\n")
+		for _, note := range updated {
+			fmt.Fprintf(&b, "- #%d %s\n", note.ID, note.Title)
+		}
+	}
+	
+	if len(tagCounts) > 0 {
+		tags := make([]string, 0, len(tagCounts))
+		for tag := range tagCounts {
+			tags = append(tags, tag)
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			if tagCounts[tags[i]] != tagCounts[tags[j]] {
+				return tagCounts[tags[i]] > tagCounts[tags[j]]
+			}
+			return tags[i] < tags[j]
+		})
+		if len(tags) > digestTopTags {
+			tags = tags[:digestTopTags]
+		}
+		
+		b.WriteString("\nMost used runes:\n")
+		for _, tag := range tags {
+			fmt.Fprintf(&b, "- %s (%d)\n", tag, tagCounts[tag])
+		}
+	}
+	return b.String()
+}
+
+// ShowDigest prints, or copies to the clipboard, the digest of a span of
+// time ending now.
+func (app *NotesApp) ShowDigest(span time.Duration, clip bool) {
+	now := time.Now()
+	text := app.digest(now.Add(-span), now)
+	if !clip {
+		fmt.Print("\n" + text)
+		return
+	}
+	if err := copyToClipboard(text); err != nil {
+		app.fail(ExitIOError, "Error copying to the clipboard: %v\n", err)
+		return
+	}
+	fmt.Println("Copied the digest to the clipboard.")
+}
+
+// scrollLink matches a reference from one scroll to another, written [[#12]].
+var scrollLink = regexp.MustCompile(`\[\[#(\d+)\]\]`)
+
+// screenshotIDSuffix matches the scroll ID at the end of a capture's file name.
+var screenshotIDSuffix = regexp.MustCompile(`_(\d+)\.png$`)
+
+// Reindex renumbers the scrolls 1, 2, 3... in order of creation, rewriting
+// [[#id]] links, the viewing history, captured image names and attachment
+// folders to match. The next ID stays above the IDs held by the trash and
+// the tombstones, so that they are never handed out again.
+func (app *NotesApp) Reindex() {
+	sort.SliceStable(app.Notes, func(i, j int) bool {
+		if !app.Notes[i].CreatedAt.Equal(app.Notes[j].CreatedAt) {
+			return app.Notes[i].CreatedAt.Before(app.Notes[j].CreatedAt)
+		}
+		return app.Notes[i].ID < app.Notes[j].ID
+	})
+	
+	mapping := make(map[int]int)
+	for i, note := range app.Notes {
+		mapping[note.ID] = i + 1
+	}
+	
+	// Attachment folders are set aside first, so that one scroll's new ID
+	// never lands on a folder another has yet to leave.
+	attachments := filepath.Join(app.NotesDir, "attachments")
+	staged := make(map[int]string)
+	for _, note := range app.Notes {
+		if len(note.Attachments) == 0 || mapping[note.ID] == note.ID {
+			continue
+		}
+		tmp := filepath.Join(attachments, fmt.Sprintf(".reindex-%d", note.ID))
+		if err := os.Rename(filepath.Join(attachments, strconv.Itoa(note.ID)), tmp); err == nil {
+			staged[note.ID] = tmp
+		} else if !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not move the attachments of scroll #%d: %v\n", note.ID, err)
+		}
+	}
+	
+	renumbered := 0
+	for i := range app.Notes {
+		note := &app.Notes[i]
+		if mapping[note.ID] != note.ID {
+			renumbered++
+		}
+		note.Content = scrollLink.ReplaceAllStringFunc(note.Content, func(link string) string {
+			old, _ := strconv.Atoi(scrollLink.FindStringSubmatch(link)[1])
+			if id, ok := mapping[old]; ok {
+				return fmt.Sprintf("[[#%d]]", id)
+			}
+			return link
+		})
+		
+		newID := mapping[note.ID]
+		if note.Type == "screenshot" && note.FilePath != "" && screenshotIDSuffix.MatchString(note.Screenshot) && newID != note.ID {
+			filename := screenshotIDSuffix.ReplaceAllString(note.Screenshot, fmt.Sprintf("_%d.png", newID))
+			newPath := filepath.Join(filepath.Dir(note.FilePath), filename)
+			if _, err := os.Stat(newPath); os.IsNotExist(err) {
+				if err := os.Rename(note.FilePath, newPath); err == nil {
+					note.FilePath = newPath
+					note.Screenshot = filename
+				} else {
+					fmt.Printf("Warning: Could not rename captured image: %v\n", err)
+				}
+			}
+		}
+		
+		if tmp, ok := staged[note.ID]; ok {
+			// A folder left by a trashed scroll keeps its place; the
+			// attachments then stay where they were set aside.
+			dir := tmp
+			if err := os.Rename(tmp, filepath.Join(attachments, strconv.Itoa(newID))); err == nil {
+				dir = filepath.Join(attachments, strconv.Itoa(newID))
+			} else {
+				fmt.Printf("Warning: Could not move the attachments of scroll #%d: %v\n", note.ID, err)
+			}
+			rel, _ := filepath.Rel(app.NotesDir, dir)
+			for j := range note.Attachments {
+				note.Attachments[j].File = filepath.Join(rel, filepath.Base(note.Attachments[j].File))
+			}
+		}
+		note.ID = newID
+	}
+	
+	app.NextID = len(app.Notes) + 1
+	var held []int
+	if trash, err := app.loadTrash(); err == nil {
+		for _, t := range trash {
+			held = append(held, t.Note.ID)
+		}
+	}
+	if tombstones, err := app.loadTombstones(); err == nil {
+		for _, t := range tombstones {
+			held = append(held, t.ID)
+		}
+	}
+	for _, id := range held {
+		if id >= app.NextID {
+			app.NextID = id + 1
+		}
+	}
+	
+	var recent []int
+	for _, id := range app.Recent {
+		if newID, ok := mapping[id]; ok {
+			recent = append(recent, newID)
+		}
+	}
+	app.Recent = nil
+	for i := len(recent) - 1; i >= 0; i-- {
+		app.recordView(recent[i])
+	}
+	app.LastResults = nil
+	
+	app.SaveNotes()
+	fmt.Printf("Reindexed the archives: %d scrolls renumbered, next ID is %d.\n", renumbered, app.NextID)
+}
+
+// plainTextSeparator divides scrolls in the plain-text export.
+var plainTextSeparator = strings.Repeat("=", 40)
+
+// plainTextExport renders every scroll as plain text with no markup, for
+// screen readers and other plain-text tools.
+func (app *NotesApp) plainTextExport(notes []Note) string {
+	sorted := append([]Note(nil), notes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	
+	var b strings.Builder
+	app.writePlainTextHeader(&b, len(sorted))
+	for _, note := range sorted {
+		app.writePlainTextScroll(&b, note)
+	}
+	writePlainTextFooter(&b, len(sorted))
+	return b.String()
+}
+
+func (app *NotesApp) writePlainTextHeader(w io.Writer, count int) {
+	fmt.Fprintf(w, "The Ancient Scrolls\n")
+	fmt.Fprintf(w, "Exported %s, %d scrolls\n", time.Now().Format(app.Settings.DateFormat), count)
+}
+
+func (app *NotesApp) writePlainTextScroll(w io.Writer, note Note) {
+	fmt.Fprintf(w, "\n%s\n", plainTextSeparator)
+	fmt.Fprintf(w, "Scroll %d: %s\n", note.ID, note.Title)
+	fmt.Fprintf(w, "Type: %s\n", note.Type)
+	fmt.Fprintf(w, "Created: %s\n", note.CreatedAt.Format(app.Settings.DateFormat))
+	fmt.Fprintf(w, "Updated: %s\n", note.UpdatedAt.Format(app.Settings.DateFormat))
+	if len(note.Tags) > 0 {
+		fmt.Fprintf(w, "Tags: %s\n", strings.Join(note.Tags, ", "))
+	} else {
+		fmt.Fprintf(w, "Tags: none\n")
+	}
+	fmt.Fprintf(w, "\n")
+	if note.Type == "text" {
+		fmt.Fprintf(w, "%s\n", note.Content)
+	} else {
+		fmt.Fprintf(w, "Captured image: %s\n", note.FilePath)
+	}
+}
+
+func writePlainTextFooter(w io.Writer, count int) {
+	if count > 0 {
+		fmt.Fprintf(w, "\n%s\n", plainTextSeparator)
+	}
+}
+
+// StreamPlainText writes the plain-text export straight from the store, one
+// scroll at a time, for archives too large to render in memory. The store
+// is read twice: once to count the scrolls for the header, once to write.
+func (app *NotesApp) StreamPlainText(path string) {
+	count := 0
+	lastID := 0
+	ordered := true
+	err := app.store.Each(func(note Note) error {
+		count++
+		if note.ID < lastID {
+			ordered = false
+		}
+		lastID = note.ID
+		return nil
+	})
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the archives: %v\n", err)
+		return
+	}
+	if !ordered {
+		fmt.Printf("Warning: The archives are not stored in order of ID; scrolls are written as stored until the next save.\n")
+	}
+	
+	f, err := os.Create(path)
+	if err != nil {
+		app.fail(ExitIOError, "Error exporting scrolls: %v\n", err)
+		return
+	}
+	w := bufio.NewWriter(f)
+	app.writePlainTextHeader(w, count)
+	err = app.store.Each(func(note Note) error {
+		app.writePlainTextScroll(w, note)
+		return w.Flush()
+	})
+	if err == nil {
+		writePlainTextFooter(w, count)
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		app.fail(ExitIOError, "Error exporting scrolls: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported %d scrolls as plain text to %s\n", count, path)
+}
+
+func (app *NotesApp) ExportPlainText(path string) {
+	if err := ioutil.WriteFile(path, []byte(app.plainTextExport(app.Notes)), 0644); err != nil {
+		app.fail(ExitIOError, "Error exporting scrolls: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported %d scrolls as plain text to %s\n", len(app.Notes), path)
+}
+
+// backlinks returns the scrolls that link to a scroll with [[#id]], in
+// order of ID.
+func (app *NotesApp) backlinks(id int) []Note {
+	var linking []Note
+	for _, note := range app.Notes {
+		if note.ID == id {
+			continue
+		}
+		for _, m := range scrollLink.FindAllStringSubmatch(note.Content, -1) {
+			if m[1] == strconv.Itoa(id) {
+				linking = append(linking, note)
+				break
+			}
+		}
+	}
+	sort.Slice(linking, func(i, j int) bool { return linking[i].ID < linking[j].ID })
+	return linking
+}
+
+// ExportScroll writes a single scroll as Markdown to path, or to stdout when
+// path is empty. With backlinks, a "Referenced by" section lists the scrolls
+// that link to it.
+func (app *NotesApp) ExportScroll(id int, path string, backlinks bool) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	text := mirrorMarkdown(app.Notes[i])
+	if backlinks {
+		if linking := app.backlinks(id); len(linking) > 0 {
+			text += "\n## Referenced by\n\n"
+			for _, note := range linking {
+				text += fmt.Sprintf("- [[#%d]] %s\n", note.ID, note.Title)
+			}
+		}
+	}
+	
+	if path == "" {
+		fmt.Print(text)
+		return
+	}
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		app.fail(ExitIOError, "Error exporting scroll: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported scroll #%d to %s\n", id, path)
+}
+
+// exportMarkdownBody renders a scroll's dates, runes and content for a
+// Markdown export, embedding image for a captured image.
+func (app *NotesApp) exportMarkdownBody(note Note, image string) string {
+	// The details form one paragraph, broken into lines by trailing spaces.
+	details := []string{
+		"Created: " + note.CreatedAt.Format(app.Settings.DateFormat),
+		"Updated: " + note.UpdatedAt.Format(app.Settings.DateFormat),
+	}
+	if len(note.Tags) > 0 {
+		details = append(details, "Tags: "+strings.Join(note.Tags, ", "))
+	}
+	
+	var b strings.Builder
+	b.WriteString(strings.Join(details, "  \n") + "\n")
+	if note.Type == "text" && strings.TrimSpace(note.Content) != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(note.Content, "\n"))
+	} else if note.Type == "screenshot" && image != "" {
+		fmt.Fprintf(&b, "\n![%s](%s)\n", note.Title, filepath.ToSlash(image))
+	}
+	return b.String()
+}
+
+// ExportMarkdown writes a scroll to <id>-<slug>.md in destDir, with its
+// dates and runes. A captured image is copied beside it, so the folder can
+// be shared as it is.
+func (app *NotesApp) ExportMarkdown(id int, destDir string) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	note := app.Notes[i]
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		app.fail(ExitIOError, "Error exporting scroll: %v\n", err)
+		return
+	}
+	
+	image := ""
+	if note.Type == "screenshot" && note.FilePath != "" {
+		image = filepath.Base(note.FilePath)
+		if err := copyFile(note.FilePath, filepath.Join(destDir, image)); err != nil {
+			fmt.Printf("Warning: Could not copy the captured image of scroll #%d: %v\n", id, err)
+		}
+	}
+	
+	path := filepath.Join(destDir, fmt.Sprintf("%d-%s.md", note.ID, slugify(note.Title)))
+	text := "# " + note.Title + "\n\n" + app.exportMarkdownBody(note, image)
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		app.fail(ExitIOError, "Error exporting scroll: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported scroll #%d to %s\n", id, path)
+}
+
+// ExportAllMarkdown writes every scroll, newest first, into one Markdown
+// document at destPath, opening with a table of contents that links to
+// each scroll's anchor. Captured images are copied into an images folder
+// beside the document.
+func (app *NotesApp) ExportAllMarkdown(destPath string) {
+	notes := append([]Note(nil), app.Notes...)
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].CreatedAt.After(notes[j].CreatedAt) })
+	
+	imagesDir := filepath.Join(filepath.Dir(destPath), "images")
+	anchor := func(note Note) string { return fmt.Sprintf("scroll-%d", note.ID) }
+	
+	var b strings.Builder
+	fmt.Fprintf(&b, "# The Ancient Scrolls\n\n")
+	fmt.Fprintf(&b, "Exported %s, %d scrolls.\n\n", time.Now().Format(app.Settings.DateFormat), len(notes))
+	fmt.Fprintf(&b, "## Contents\n\n")
+	for _, note := range notes {
+		fmt.Fprintf(&b, "- [#%d %s](#%s)\n", note.ID, note.Title, anchor(note))
+	}
+	
+	copied := 0
+	for _, note := range notes {
+		image := ""
+		if note.Type == "screenshot" && note.FilePath != "" {
+			image = filepath.Join("images", filepath.Base(note.FilePath))
+			if err := copyFile(note.FilePath, filepath.Join(imagesDir, filepath.Base(note.FilePath))); err != nil {
+				fmt.Printf("Warning: Could not copy the captured image of scroll #%d: %v\n", note.ID, err)
+			} else {
+				copied++
+			}
+		}
+		fmt.Fprintf(&b, "\n---\n\n<a id=\"%s\"></a>\n\n## #%d %s\n\n", anchor(note), note.ID, note.Title)
+		b.WriteString(app.exportMarkdownBody(note, image))
+	}
+	
+	if err := ioutil.WriteFile(destPath, []byte(b.String()), 0644); err != nil {
+		app.fail(ExitIOError, "Error exporting scrolls: %v\n", err)
+		return
+	}
+	fmt.Printf("Exported %d scrolls as Markdown to %s", len(notes), destPath)
+	if copied > 0 {
+		fmt.Printf(", with %d images in %s", copied, imagesDir)
+	}
+	fmt.Println()
+}
+
+// tagRename maps one rune (tag) to its new name.
+type tagRename struct {
+	From string
+	To   string
+}
+
+// parseTagRenames reads old=new lines from a mapping file. Blank lines and
+// lines starting with # are ignored; malformed lines are reported and skipped.
+func parseTagRenames(data string) ([]tagRename, []string) {
+	var renames []tagRename
+	var problems []string
+	
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			problems = append(problems, fmt.Sprintf("line %d: expected old=new, got %q", i+1, line))
+			continue
+		}
+		renames = append(renames, tagRename{From: strings.TrimSpace(parts[0]), To: strings.TrimSpace(parts[1])})
+	}
+	return renames, problems
+}
+
+// RenameTags applies every rename across all scrolls in a single pass and
+// saves once. Renames are not chained: a rune renamed to "b" is not renamed
+// again by a later "b=c" line.
+func (app *NotesApp) RenameTags(renames []tagRename) {
+	mapping := make(map[string]string)
+	for _, r := range renames {
+		mapping[strings.ToLower(r.From)] = r.To
+	}
+	
+	counts := make(map[string]int)
+	changedScrolls := 0
+	now := time.Now()
+	for i, note := range app.Notes {
+		changed := false
+		var newTags []string
+		seen := make(map[string]bool)
+		for _, tag := range note.Tags {
+			if to, ok := mapping[strings.ToLower(tag)]; ok {
+				counts[strings.ToLower(tag)]++
+				tag = to
+				changed = true
+			}
+			if seen[strings.ToLower(tag)] {
+				continue
+			}
+			seen[strings.ToLower(tag)] = true
+			newTags = append(newTags, tag)
+		}
+		
+		if changed {
+			app.Notes[i].Tags = newTags
+			app.Notes[i].UpdatedAt = now
+			changedScrolls++
+		}
+	}
+	
+	for _, r := range renames {
+		fmt.Printf("  %s -> %s: %d scrolls\n", r.From, r.To, counts[strings.ToLower(r.From)])
+	}
+	
+	if changedScrolls > 0 {
+		app.SaveNotes()
+	}
+	fmt.Printf("Renamed runes on %d scrolls.\n", changedScrolls)
+}
+
+func (app *NotesApp) RecaptureImage(id int) {
+	for i, note := range app.Notes {
+		if note.ID == id {
+			if note.Type != "screenshot" {
+				app.fail(ExitInvalid, "Scroll #%d is not a captured image. Cannot recapture.\n", id)
+				return
+			}
+			
+			reader := stdin
+			
+			// Ask if they want to delete the old image
+			fmt.Printf("Delete the old captured image '%s'? (y/n): ", note.Screenshot)
+			response, _ := reader.ReadString('\n')
+			response = strings.TrimSpace(strings.ToLower(response))
+			
+			deleteOld := response == "y" || response == "yes"
+			oldFilePath := note.FilePath
+			
+			// Create new screenshot
+			timestamp := time.Now().Format("20060102_150405")
+			filename := fmt.Sprintf("scroll_capture_%s_%d.png", timestamp, note.ID)
+			screenshotPath := filepath.Join(app.imagesDir(), filename)
+			
+			cmd, region, err := app.captureCommand(screenshotPath, note.Region)
+			if err != nil {
+				app.fail(ExitIOError, "Knowledge recapture cancelled: %v\n", err)
+				return
+			}
+			if cmd == nil {
+				app.fail(ExitIOError, "Image recapture not supported on this platform\n")
+				return
+			}
+			
+			fmt.Println("Recapturing ancient knowledge... (follow system prompts)")
+			if err := cmd.Run(); err != nil {
+				app.fail(ExitIOError, "Error recapturing image: %v\n", err)
+				return
+			}
+			
+			// Check if new screenshot file was created
+			if _, err := os.Stat(screenshotPath); os.IsNotExist(err) {
+				app.fail(ExitIOError, "Knowledge recapture cancelled or failed\n")
+				return
+			}
+			
+			// A byte-identical recapture only wastes space
+			if sameFileContents(oldFilePath, screenshotPath) {
+				fmt.Println("The new capture is identical to the old image.")
+				fmt.Print("Keep the old image and discard the duplicate? (y/n): ")
+				response, _ := reader.ReadString('\n')
+				response = strings.TrimSpace(strings.ToLower(response))
+				
+				if response == "y" || response == "yes" {
+					if err := os.Remove(screenshotPath); err != nil {
+						fmt.Printf("Warning: Could not discard duplicate image: %v\n", err)
+					}
+					fmt.Printf("Scroll #%d keeps its image: %s\n", id, note.Screenshot)
+					return
+				}
+			}
+			
+			// Update the note with new image info
+			app.Notes[i].FilePath = screenshotPath
+			app.Notes[i].Screenshot = filename
+			app.Notes[i].UpdatedAt = time.Now()
+			if region != "" {
+				app.Notes[i].Region = region
+			}
+			
+			// Delete old image if requested
+			if deleteOld && oldFilePath != "" {
+				if err := os.Remove(oldFilePath); err != nil {
+					fmt.Printf("Warning: Could not delete old image: %v\n", err)
+				}
+			}
+			
+			app.SaveNotes()
+			fmt.Printf("Scroll #%d image has been recaptured: %s\n", id, filename)
+			return
+		}
+	}
+	app.notFound(id)
+}
+
+// fileHash returns the SHA-256 digest of a file's contents.
+func fileHash(path string) ([]byte, error) {
+	f, err := openForRead(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// sameFileContents reports whether two files exist and hold identical bytes.
+func sameFileContents(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	hashA, err := fileHash(a)
+	if err != nil {
+		return false
+	}
+	hashB, err := fileHash(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(hashA, hashB)
+}
+
+// DeleteOptions controls how a scroll is erased.
+type DeleteOptions struct {
+	Shred bool // overwrite the captured image with random data before removing it
+}
+
+// shredFile overwrites a file with random bytes, flushes it to disk and then
+// removes it. On journaling or copy-on-write filesystems and on SSDs the old
+// blocks may survive elsewhere, so this is best-effort only.
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.CopyN(f, rand.Reader, info.Size()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// confirmErase asks whether a scroll should really be erased. With
+// strict_delete_confirm set, the seeker must retype its exact title.
+func (app *NotesApp) confirmErase(reader *bufio.Reader, id int) bool {
+	i := app.noteIndex(id)
+	if !app.Settings.StrictDeleteConfirm || i < 0 {
+		fmt.Printf("Are you certain you wish to erase scroll #%d from the archives? (y/n): ", id)
+		confirm, _ := reader.ReadString('\n')
+		confirm = strings.TrimSpace(strings.ToLower(confirm))
+		return confirm == "y" || confirm == "yes"
+	}
+	
+	fmt.Printf("To erase scroll #%d, type its title exactly (%s): ", id, app.Notes[i].Title)
+	typed, _ := reader.ReadString('\n')
+	if strings.TrimRight(typed, "\r\n") != app.Notes[i].Title {
+		fmt.Println("The title does not match.")
+		return false
+	}
+	return true
+}
+
+// shredStoredText shreds the files that hold a scroll's text on its own: its
+// file in the markdown store and its mirror. The json store keeps every
+// scroll in one file, which is rewritten without it.
+func (app *NotesApp) shredStoredText(note Note) {
+	var paths []string
+	if md, ok := app.store.(markdownStore); ok {
+		paths = append(paths, md.files(note.ID)...)
+	}
+	if note.MirrorPath != "" {
+		paths = append(paths, note.MirrorPath)
+	}
+	for _, path := range paths {
+		if err := shredFile(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not shred %s: %v\n", path, err)
+		}
+	}
+}
+
+// DeleteNote moves a scroll to the trash, keeping its captured image and
+// attachments so that recover brings them back. Erasing a scroll already
+// in the trash, or shredding one, destroys it for good.
+func (app *NotesApp) DeleteNote(id int, opts DeleteOptions) {
+	if app.isLocked(id) {
+		return
+	}
+	
+	for i, note := range app.Notes {
+		if note.ID == id {
+			if opts.Shred {
+				if note.Type == "screenshot" && note.FilePath != "" {
+					if err := shredFile(note.FilePath); err != nil {
+						fmt.Printf("Warning: Could not shred captured image: %v\n", err)
+					}
+				}
+				app.removeAttachments(note, true)
+				app.shredStoredText(note)
+				fmt.Println("Note: shredding is best-effort; journaling filesystems, SSDs and backups may still hold old copies.")
+			} else if err := app.trashScrolls(note); err != nil {
+				app.fail(ExitIOError, "Error moving scroll #%d to the trash: %v\n", id, err)
+				return
+			}
 			
-			fmt.Print("Inscribe your knowledge: ")
-			content, _ := reader.ReadString('\n')
-			content = strings.TrimSpace(content)
+			// Remove note from slice
+			app.Notes = append(app.Notes[:i], app.Notes[i+1:]...)
+			app.SaveNotes()
+			app.recordTombstones(note)
+			if opts.Shred {
+				fmt.Printf("Scroll #%d has been erased from the archives.\n", id)
+			} else {
+				fmt.Printf("Scroll #%d has been moved to the trash; 'recover %d' brings it back.\n", id, id)
+			}
+			return
+		}
+	}
+	
+	if app.purgeTrashed(id) {
+		fmt.Printf("Scroll #%d has been destroyed for good.\n", id)
+		return
+	}
+	app.notFound(id)
+}
+
+// readIDs reads whitespace-separated scroll IDs until the end of input. It
+// serves only a single command run from the shell; within the menu the
+// input holds the commands still to come.
+func (app *NotesApp) readIDs(reader *bufio.Reader) ([]int, error) {
+	if app.menu {
+		return nil, errors.New("--stdin reads IDs piped to a single command, not within the menu")
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	
+	var ids []int
+	for _, field := range strings.Fields(string(data)) {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scroll ID %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// DeleteNotes erases several scrolls without prompting and saves once. They
+// go to the trash unless deleteImages asks for them to be destroyed, with
+// their captured images and attachments; scrolls already in the trash are
+// destroyed for good.
+func (app *NotesApp) DeleteNotes(ids []int, deleteImages bool) {
+	var erasedNotes []Note
+	purged := 0
+	for _, id := range ids {
+		i := app.noteIndex(id)
+		if i < 0 {
+			if app.purgeTrashed(id) {
+				purged++
+				continue
+			}
+			app.notFound(id)
+			continue
+		}
+		
+		note := app.Notes[i]
+		if deleteImages && note.Type == "screenshot" && note.FilePath != "" {
+			if err := os.Remove(note.FilePath); err != nil {
+				fmt.Printf("Warning: Could not destroy captured image: %v\n", err)
+			}
+		}
+		if deleteImages {
+			app.removeAttachments(note, false)
+		}
+		app.Notes = append(app.Notes[:i], app.Notes[i+1:]...)
+		erasedNotes = append(erasedNotes, note)
+	}
+	
+	if len(erasedNotes) > 0 && !deleteImages {
+		if err := app.trashScrolls(erasedNotes...); err != nil {
+			app.fail(ExitIOError, "Error moving scrolls to the trash: %v\n", err)
+			app.LoadNotes()
+			return
+		}
+	}
+	if len(erasedNotes) > 0 {
+		app.SaveNotes()
+		app.recordTombstones(erasedNotes...)
+	}
+	if deleteImages {
+		fmt.Printf("%d scrolls have been erased from the archives.\n", len(erasedNotes)+purged)
+	} else {
+		fmt.Printf("%d scrolls have been moved to the trash.\n", len(erasedNotes))
+		if purged > 0 {
+			fmt.Printf("%d scrolls already in the trash have been destroyed for good.\n", purged)
+		}
+	}
+}
+
+// TrashedScroll is an erased scroll kept in trash.json until the trash is
+// emptied.
+type TrashedScroll struct {
+	Note      Note      `json:"note"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+func (app *NotesApp) trashPath() string {
+	return filepath.Join(app.NotesDir, "trash.json")
+}
+
+func (app *NotesApp) loadTrash() ([]TrashedScroll, error) {
+	data, err := ioutil.ReadFile(app.trashPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var trash []TrashedScroll
+	if err := json.Unmarshal(data, &trash); err != nil {
+		return nil, fmt.Errorf("%s: %v", app.trashPath(), err)
+	}
+	return trash, nil
+}
+
+func (app *NotesApp) saveTrash(trash []TrashedScroll) error {
+	data, err := json.MarshalIndent(trash, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(app.trashPath(), data, 0644)
+}
+
+// trashScrolls adds erased scrolls to the trash. A scroll whose ID is
+// already there is kept beside the earlier one; the time each was erased
+// tells them apart.
+func (app *NotesApp) trashScrolls(notes ...Note) error {
+	trash, err := app.loadTrash()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, note := range notes {
+		trash = append(trash, TrashedScroll{Note: note, TrashedAt: now})
+	}
+	return app.saveTrash(trash)
+}
+
+// destroyFiles removes a scroll's captured image and attachments.
+func (app *NotesApp) destroyFiles(note Note) {
+	if note.Type == "screenshot" && note.FilePath != "" {
+		if err := os.Remove(note.FilePath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not destroy captured image: %v\n", err)
+		}
+	}
+	app.removeAttachments(note, false)
+}
+
+// purgeTrashed destroys a scroll in the trash for good, with its files. It
+// reports whether the scroll was in the trash. Of several scrolls with the
+// ID, the one erased last goes.
+func (app *NotesApp) purgeTrashed(id int) bool {
+	trash, err := app.loadTrash()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the trash: %v\n", err)
+		return false
+	}
+	for i := len(trash) - 1; i >= 0; i-- {
+		if t := trash[i]; t.Note.ID == id {
+			if err := app.saveTrash(append(trash[:i], trash[i+1:]...)); err != nil {
+				app.fail(ExitIOError, "Error saving the trash: %v\n", err)
+				return false
+			}
+			app.destroyFiles(t.Note)
+			return true
+		}
+	}
+	return false
+}
+
+// ListTrash shows the scrolls waiting in the trash, most recently erased
+// first.
+func (app *NotesApp) ListTrash() {
+	trash, err := app.loadTrash()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the trash: %v\n", err)
+		return
+	}
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty.")
+		return
+	}
+	
+	fmt.Println("\n=== Scrolls in the Trash ===")
+	for i := len(trash) - 1; i >= 0; i-- {
+		t := trash[i]
+		fmt.Printf("#%d %s (%s, erased %s)\n", t.Note.ID, t.Note.Title, t.Note.Type, t.TrashedAt.Format(app.Settings.DateFormat))
+	}
+	fmt.Println("Speak 'recover <id>' to bring a scroll back, or 'empty-trash' to destroy them all.")
+}
+
+// chooseTrashed returns the index in trash of the scroll to recover with the
+// given ID, asking through reader when several were erased under it. It
+// returns -1 when there is none or no choice is made.
+func (app *NotesApp) chooseTrashed(reader *bufio.Reader, trash []TrashedScroll, id int) int {
+	var found []int
+	for i, t := range trash {
+		if t.Note.ID == id {
+			found = append(found, i)
+		}
+	}
+	if len(found) == 0 {
+		app.fail(ExitNotFound, "Scroll with ID %d not found in the trash.\n", id)
+		return -1
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	
+	fmt.Printf("The trash holds %d scrolls erased as #%d:\n", len(found), id)
+	for n, i := range found {
+		t := trash[i]
+		fmt.Printf("  %d) %s (%s, erased %s)\n", n+1, t.Note.Title, t.Note.Type, t.TrashedAt.Format(app.Settings.DateFormat))
+	}
+	fmt.Print("Which shall be recovered? ")
+	answer, _ := reader.ReadString('\n')
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || n < 1 || n > len(found) {
+		app.fail(ExitInvalid, "No scroll was recovered.\n")
+		return -1
+	}
+	return found[n-1]
+}
+
+// RestoreNote brings a scroll back from the trash, with its captured image
+// and attachments. A scroll whose ID has since been taken gets a new one.
+func (app *NotesApp) RestoreNote(reader *bufio.Reader, id int) {
+	trash, err := app.loadTrash()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the trash: %v\n", err)
+		return
+	}
+	
+	i := app.chooseTrashed(reader, trash, id)
+	if i < 0 {
+		return
+	}
+	note := trash[i].Note
+	if app.noteIndex(note.ID) >= 0 {
+		note.ID = app.NextID
+	}
+	if note.ID >= app.NextID {
+		app.NextID = note.ID + 1
+	}
+	
+	if err := app.saveTrash(append(trash[:i], trash[i+1:]...)); err != nil {
+		app.fail(ExitIOError, "Error saving the trash: %v\n", err)
+		return
+	}
+	app.Notes = append(app.Notes, note)
+	app.SaveNotes()
+	app.forgetTombstone(id)
+	
+	if note.ID != id {
+		fmt.Printf("Scroll #%d has been restored as #%d, since its ID has been taken.\n", id, note.ID)
+	} else {
+		fmt.Printf("Scroll #%d has been restored to the archives.\n", id)
+	}
+}
+
+// EmptyTrash destroys every scroll in the trash, with their files, after
+// showing what will be lost and reading an explicit "yes".
+func (app *NotesApp) EmptyTrash(reader *bufio.Reader) {
+	trash, err := app.loadTrash()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the trash: %v\n", err)
+		return
+	}
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty.")
+		return
+	}
+	
+	var imageBytes int64
+	images := 0
+	for _, t := range trash {
+		if t.Note.Type != "screenshot" || t.Note.FilePath == "" {
+			continue
+		}
+		if info, err := os.Stat(t.Note.FilePath); err == nil {
+			imageBytes += info.Size()
+			images++
+		}
+	}
+	fmt.Printf("The trash holds %d scrolls and %d captured images (%s).\n", len(trash), images, formatSize(imageBytes))
+	fmt.Print("Type 'yes' to destroy them all for good: ")
+	answer, _ := reader.ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		fmt.Println("The trash is left as it was.")
+		return
+	}
+	
+	for _, t := range trash {
+		app.destroyFiles(t.Note)
+	}
+	if err := os.Remove(app.trashPath()); err != nil {
+		app.fail(ExitIOError, "Error emptying the trash: %v\n", err)
+		return
+	}
+	fmt.Printf("%d scrolls have been destroyed for good.\n", len(trash))
+}
+
+// Tombstone records the erasure of a scroll.
+type Tombstone struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (app *NotesApp) tombstonesPath() string {
+	return filepath.Join(app.NotesDir, "tombstones.json")
+}
+
+func (app *NotesApp) loadTombstones() ([]Tombstone, error) {
+	data, err := ioutil.ReadFile(app.tombstonesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var tombstones []Tombstone
+	if err := json.Unmarshal(data, &tombstones); err != nil {
+		return nil, fmt.Errorf("%s: %v", app.tombstonesPath(), err)
+	}
+	return tombstones, nil
+}
+
+func (app *NotesApp) saveTombstones(tombstones []Tombstone) error {
+	data, err := json.MarshalIndent(tombstones, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(app.tombstonesPath(), data, 0644)
+}
+
+// recordTombstones adds tombstones for erased scrolls when the settings ask
+// for them.
+func (app *NotesApp) recordTombstones(notes ...Note) {
+	if !app.Settings.KeepTombstones {
+		return
+	}
+	
+	tombstones, err := app.loadTombstones()
+	if err == nil {
+		now := time.Now()
+		for _, note := range notes {
+			tombstones = append(tombstones, Tombstone{ID: note.ID, Title: note.Title, DeletedAt: now})
+		}
+		err = app.saveTombstones(tombstones)
+	}
+	if err != nil {
+		fmt.Printf("Warning: Could not record the erasure: %v\n", err)
+	}
+}
+
+// forgetTombstone drops the tombstone of a restored scroll, so that a sync
+// does not erase it again.
+func (app *NotesApp) forgetTombstone(id int) {
+	tombstones, err := app.loadTombstones()
+	if err != nil || len(tombstones) == 0 {
+		return
+	}
+	var kept []Tombstone
+	for _, t := range tombstones {
+		if t.ID != id {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) < len(tombstones) {
+		if err := app.saveTombstones(kept); err != nil {
+			fmt.Printf("Warning: Could not update tombstones: %v\n", err)
+		}
+	}
+}
+
+// ShowTombstones lists the recorded erasures, oldest first.
+func (app *NotesApp) ShowTombstones() {
+	tombstones, err := app.loadTombstones()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading tombstones: %v\n", err)
+		return
+	}
+	if len(tombstones) == 0 {
+		if app.Settings.KeepTombstones {
+			fmt.Println("No erasures have been recorded.")
+		} else {
+			fmt.Println("No erasures have been recorded. Set \"keep_tombstones\": true in the settings file to record them.")
+		}
+		return
+	}
+	
+	fmt.Println("\n=== Erased Scrolls ===")
+	for _, t := range tombstones {
+		fmt.Printf("#%d %s (erased %s)\n", t.ID, t.Title, t.DeletedAt.Format(app.Settings.DateFormat))
+	}
+}
+
+// PurgeTombstones forgets the erasures recorded more than age ago, or all of
+// them when age is zero.
+func (app *NotesApp) PurgeTombstones(age time.Duration) {
+	tombstones, err := app.loadTombstones()
+	if err != nil {
+		app.fail(ExitIOError, "Error reading tombstones: %v\n", err)
+		return
+	}
+	
+	cutoff := time.Now().Add(-age)
+	var kept []Tombstone
+	for _, t := range tombstones {
+		if age > 0 && t.DeletedAt.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(tombstones) {
+		fmt.Println("No tombstones to purge.")
+		return
+	}
+	if err := app.saveTombstones(kept); err != nil {
+		app.fail(ExitIOError, "Error saving tombstones: %v\n", err)
+		return
+	}
+	fmt.Printf("Purged %d tombstones; %d remain.\n", len(tombstones)-len(kept), len(kept))
+}
+
+func (app *NotesApp) syncStatePath() string {
+	return filepath.Join(app.NotesDir, "sync.json")
+}
+
+// lastSyncs reads when the notebook was last synced with each remote
+// directory.
+func (app *NotesApp) lastSyncs() map[string]time.Time {
+	syncs := make(map[string]time.Time)
+	if data, err := ioutil.ReadFile(app.syncStatePath()); err == nil {
+		json.Unmarshal(data, &syncs)
+	}
+	return syncs
+}
+
+// sendScroll copies a scroll, with its captured image and attachments, from
+// one notebook into another, replacing the scroll of the same ID there.
+func sendScroll(note Note, from, to *NotesApp) {
+	if note.Type == "screenshot" && note.FilePath != "" {
+		newPath := filepath.Join(to.imagesDir(), note.Screenshot)
+		if err := copyFile(note.FilePath, newPath); err != nil {
+			fmt.Printf("Warning: Could not copy the captured image of scroll #%d: %v\n", note.ID, err)
+		}
+		note.FilePath = newPath
+	}
+	for _, a := range note.Attachments {
+		if err := copyFile(from.attachmentPath(a), to.attachmentPath(a)); err != nil {
+			fmt.Printf("Warning: Could not copy attachment %s of scroll #%d: %v\n", a.Name, note.ID, err)
+		}
+	}
+	
+	// A mirror path belongs to the machine that set it.
+	if i := to.noteIndex(note.ID); i >= 0 {
+		note.MirrorPath = to.Notes[i].MirrorPath
+		to.Notes[i] = note
+		return
+	}
+	note.MirrorPath = ""
+	to.Notes = append(to.Notes, note)
+}
+
+// mergeScrolls joins two versions of a scroll, keeping every line of both
+// contents in the order their diff gives and the runes of both. The title
+// and the rest come from the newer version.
+func mergeScrolls(a, b Note) Note {
+	merged := a
+	if b.UpdatedAt.After(a.UpdatedAt) {
+		merged = b
+	}
+	
+	var lines []string
+	for _, line := range diffLines(a.Content, b.Content) {
+		lines = append(lines, line[1:])
+	}
+	merged.Content = strings.Join(lines, "\n")
+	
+	merged.Tags = nil
+	seen := make(map[string]bool)
+	for _, tag := range append(append([]string(nil), a.Tags...), b.Tags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	return merged
+}
+
+// resolveConflict shows how a scroll differs between this notebook and the
+// remote copy and asks which version to keep, or whether to merge them.
+// An empty answer, or input running out, keeps the newer. It returns the
+// scroll to keep, whether its files and paths are the remote copy's, and
+// how it was chosen.
+func (app *NotesApp) resolveConflict(reader *bufio.Reader, local, other Note, remoteDir string) (Note, bool, string) {
+	fmt.Printf("\n=== Conflict on Scroll #%d ===\n", local.ID)
+	fmt.Printf("--- this notebook (updated %s)\n", local.UpdatedAt.Format(app.Settings.DateFormat))
+	fmt.Printf("+++ %s (updated %s)\n", remoteDir, other.UpdatedAt.Format(app.Settings.DateFormat))
+	if local.Title != other.Title {
+		fmt.Printf("-Title: %s\n+Title: %s\n", local.Title, other.Title)
+	}
+	if strings.Join(local.Tags, ",") != strings.Join(other.Tags, ",") {
+		fmt.Printf("-Tags: %s\n+Tags: %s\n", strings.Join(local.Tags, ", "), strings.Join(other.Tags, ", "))
+	}
+	for _, line := range diffLines(local.Content, other.Content) {
+		fmt.Println(line)
+	}
+	
+	newer := "l"
+	if other.UpdatedAt.After(local.UpdatedAt) {
+		newer = "r"
+	}
+	for {
+		fmt.Printf("Keep (l)ocal, (r)emote, or (m)erge both? [%s]: ", newer)
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer == "" {
+			answer = newer
+		}
+		if err != nil && answer != "l" && answer != "r" && answer != "m" {
+			fmt.Println()
+			answer = newer
+		}
+		switch answer {
+		case "l", "local":
+			return local, false, "this notebook's version"
+		case "r", "remote":
+			return other, true, "the version from " + remoteDir
+		case "m", "merge":
+			// The merge takes all but content and runes from the newer.
+			return mergeScrolls(local, other), newer == "r", "a merge of both"
+		}
+		fmt.Println("Answer l, r or m.")
+	}
+}
+
+// Sync merges the notebook with a copy kept in another directory, such as a
+// shared folder. Scrolls are matched by ID and the more recently updated
+// side wins; a scroll changed on both sides since the last sync is reported
+// as a conflict, or, given a reader, resolved by asking which version to
+// keep. Tombstones on either side erase the scroll on the other, unless it
+// was updated after the erasure.
+func (app *NotesApp) Sync(reader *bufio.Reader, remoteDir string) {
+	remoteDir, err := filepath.Abs(remoteDir)
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(remoteDir); err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", remoteDir)
+		}
+	}
+	if err != nil {
+		app.fail(ExitNotFound, "Error: %v\n", err)
+		return
+	}
+	if localDir, _ := filepath.Abs(app.NotesDir); localDir == remoteDir {
+		app.fail(ExitInvalid, "Error: %s is this notebook's own directory.\n", remoteDir)
+		return
+	}
+	
+	remoteSettings := app.Settings
+	remoteSettings.NotesDir = remoteDir
+	remoteSettings.ImagesDir = ""
+	remote := NewNotesApp(remoteSettings, DefaultNotebook)
+	if remote.ExitCode != ExitOK {
+		app.ExitCode = remote.ExitCode
+		return
+	}
+	
+	localTombs, err := app.loadTombstones()
+	var remoteTombs []Tombstone
+	if err == nil {
+		remoteTombs, err = remote.loadTombstones()
+	}
+	if err != nil {
+		app.fail(ExitIOError, "Error reading tombstones: %v\n", err)
+		return
+	}
+	
+	// Merge the tombstones, keeping the latest erasure of each ID.
+	latest := make(map[int]Tombstone)
+	erasedAt := make(map[int]time.Time)
+	for _, t := range append(localTombs, remoteTombs...) {
+		if at, ok := erasedAt[t.ID]; !ok || t.DeletedAt.After(at) {
+			latest[t.ID] = t
+			erasedAt[t.ID] = t.DeletedAt
+		}
+	}
+	var tombstones []Tombstone
+	for _, t := range latest {
+		tombstones = append(tombstones, t)
+	}
+	sort.Slice(tombstones, func(i, j int) bool {
+		return tombstones[i].DeletedAt.Before(tombstones[j].DeletedAt)
+	})
+	
+	syncs := app.lastSyncs()
+	lastSync := syncs[remoteDir]
+	
+	ids := make(map[int]bool)
+	for _, note := range app.Notes {
+		ids[note.ID] = true
+	}
+	for _, note := range remote.Notes {
+		ids[note.ID] = true
+	}
+	var order []int
+	for id := range ids {
+		order = append(order, id)
+	}
+	sort.Ints(order)
+	
+	sent, received, erased := 0, 0, 0
+	var conflicts []string
+	erase := func(side *NotesApp, id int) {
+		if i := side.noteIndex(id); i >= 0 {
+			side.Notes = append(side.Notes[:i], side.Notes[i+1:]...)
+			erased++
+		}
+	}
+	for _, id := range order {
+		li, ri := app.noteIndex(id), remote.noteIndex(id)
+		switch {
+		case li >= 0 && ri >= 0:
+			local, other := app.Notes[li], remote.Notes[ri]
+			if local.UpdatedAt.Equal(other.UpdatedAt) {
+				continue
+			}
+			if local.UpdatedAt.After(lastSync) && other.UpdatedAt.After(lastSync) {
+				if reader != nil {
+					kept, fromRemote, choice := app.resolveConflict(reader, local, other, remoteDir)
+					kept.UpdatedAt = time.Now()
+					if fromRemote {
+						// Bring the remote image and attachments over
+						// first, so the scroll points at local copies.
+						sendScroll(kept, remote, app)
+						kept = app.Notes[app.noteIndex(id)]
+					} else {
+						app.Notes[li] = kept
+					}
+					sendScroll(kept, app, remote)
+					conflicts = append(conflicts, fmt.Sprintf("#%d %s: changed on both sides; kept %s", id, kept.Title, choice))
+					continue
+				}
+				newer := "this notebook"
+				if other.UpdatedAt.After(local.UpdatedAt) {
+					newer = remoteDir
+				}
+				conflicts = append(conflicts, fmt.Sprintf("#%d %s: changed on both sides; kept the newer from %s", id, local.Title, newer))
+			}
+			if local.UpdatedAt.After(other.UpdatedAt) {
+				sendScroll(local, app, remote)
+				sent++
+			} else {
+				sendScroll(other, remote, app)
+				received++
+			}
 			
-			fmt.Print("Mark with ancient runes (tags, comma-separated, optional): ")
-			tagsInput, _ := reader.ReadString('\n')
-			tagsInput = strings.TrimSpace(tagsInput)
+		case li >= 0:
+			local := app.Notes[li]
+			if at, ok := erasedAt[id]; ok && at.After(local.UpdatedAt) {
+				erase(app, id)
+				continue
+			}
+			sendScroll(local, app, remote)
+			sent++
 			
-			var tags []string
-			if tagsInput != "" {
-				tags = strings.Split(tagsInput, ",")
-				for i, tag := range tags {
-					tags[i] = strings.TrimSpace(tag)
-				}
+		case ri >= 0:
+			other := remote.Notes[ri]
+			if at, ok := erasedAt[id]; ok && at.After(other.UpdatedAt) {
+				erase(remote, id)
+				continue
+			}
+			sendScroll(other, remote, app)
+			received++
+		}
+	}
+	
+	if remote.NextID > app.NextID {
+		app.NextID = remote.NextID
+	}
+	remote.NextID = app.NextID
+	app.SaveNotes()
+	remote.SaveNotes()
+	
+	if len(tombstones) > 0 {
+		for _, side := range []*NotesApp{app, remote} {
+			if err := side.saveTombstones(tombstones); err != nil {
+				fmt.Printf("Warning: Could not save tombstones: %v\n", err)
+			}
+		}
+	}
+	
+	syncs[remoteDir] = time.Now()
+	if data, err := json.MarshalIndent(syncs, "", "  "); err == nil {
+		if err := ioutil.WriteFile(app.syncStatePath(), data, 0644); err != nil {
+			fmt.Printf("Warning: Could not record the sync: %v\n", err)
+		}
+	}
+	
+	for _, conflict := range conflicts {
+		fmt.Println("Conflict: " + conflict)
+	}
+	fmt.Printf("Synced with %s: %d scrolls sent, %d received, %d erased, %d conflicts.\n", remoteDir, sent, received, erased, len(conflicts))
+}
+
+// RetagNotes sets the runes of several scrolls and saves once.
+func (app *NotesApp) RetagNotes(ids []int, tags []string) {
+	retagged := 0
+	now := time.Now()
+	for _, id := range ids {
+		i := app.noteIndex(id)
+		if i < 0 {
+			app.notFound(id)
+			continue
+		}
+		app.Notes[i].Tags = tags
+		app.Notes[i].UpdatedAt = now
+		retagged++
+	}
+	
+	if retagged > 0 {
+		app.SaveNotes()
+	}
+	fmt.Printf("%d scrolls have been retagged.\n", retagged)
+}
+
+func (app *NotesApp) ShowHelp() {
+	fmt.Println("\n=== The Ancient Scrolls - Ancient Commands ===")
+	fmt.Println("Available commands:")
+	fmt.Println("  1 or inscribe   - Inscribe a new text scroll")
+	fmt.Println("  2 or capture    - Capture an image scroll")
+	fmt.Println("  3 or archive    - View all scrolls in the archive")
+	fmt.Println("  4 or reveal     - Reveal a specific scroll")
+	fmt.Println("  5 or seek       - Seek knowledge within scrolls")
+	fmt.Println("  6 or modify     - Modify an existing scroll")
+	fmt.Println("  7 or retitle    - Change a scroll's title")
+	fmt.Println("  8 or retag      - Update a scroll's ancient runes")
+	fmt.Println("  9 or recapture  - Replace a captured image")
+	fmt.Println("  10 or erase     - Erase a scroll from existence")
+	fmt.Println("  11 or wisdom    - Show these ancient commands")
+	fmt.Println("  12 or depart    - Depart from the archives")
+	fmt.Println()
+	fmt.Println("Further incantations:")
+	fmt.Println("  paste-image <title> [--tags a,b] - Save the clipboard's image as a scroll")
+	fmt.Println("  runes <tag> [exact|prefix|substring] - Find scrolls bearing a rune")
+	fmt.Println("  tag-tree        - Show /-delimited runes as a tree, counting scrolls at each level")
+	fmt.Println("  list/seek --ids, erase/retag --stdin - Pipe scroll IDs between commands")
+	fmt.Println("  erase <id> --preview [--shred] - Show the whole scroll before confirming its erasure")
+	fmt.Println("  seek --in title,content,tags - Search only some parts of each scroll")
+	fmt.Println("  seek a AND b, a OR b, NOT c - Combine terms; use \"quotes\" and (parentheses)")
+	fmt.Println("  seek --case-sensitive - Match the terms' case exactly")
+	fmt.Println("  seek --regex <pattern> - Match a regular expression, e.g. seek --regex '^TODO'")
+	fmt.Println("  list/seek --oneline - Print one scroll per line: #<id> <title> [tags]")
+	fmt.Println("  list --with-preview - Show the first two lines of each scroll under it")
+	fmt.Println("  list --unread   - List only the scrolls not yet read")
+	fmt.Println("  mark-read/mark-unread <id> - Mark a scroll as read or unread (revealing it marks it read)")
+	fmt.Println("  list/seek --clip - Copy the listing to the clipboard instead of printing it")
+	fmt.Println("  view-result <n> - Reveal the nth scroll from the last search")
+	fmt.Println("  update <id> [--title t] [--tags a,b] [--content c | --content-file f] [--append]")
+	fmt.Println("                  - Change only the given parts of a scroll")
+	fmt.Println("                    (--append fills in {{date}} and {{time}} in the appended text)")
+	fmt.Println("  append <id> [text] - Add text to the end of a scroll, or whatever is piped in")
+	fmt.Println("  patch <id> --file changes.json - Apply title, content or tags from a JSON file")
+	fmt.Println("  explode <id> --by-heading [--delete] - Split a scroll into one per top-level heading")
+	fmt.Println("  write <id> [--background] - Edit a scroll's content in your editor")
+	fmt.Println("  attach-file <id> <path> - Attach a file of any kind to a scroll")
+	fmt.Println("  mirror <id> <path> | --off - Keep a Markdown copy of a scroll up to date at a path")
+	fmt.Println("  missing-images [--convert | --clear] - Find image scrolls whose files are gone")
+	fmt.Println("  backfill-ocr    - Read the text of captured images with tesseract, for seek")
+	fmt.Println("  relocate-images <dir> - Move every captured image under another directory")
+	fmt.Println("  rename-screenshot <id> - Name a captured image after its scroll's title")
+	fmt.Println("  boost <id> <n | none> - Raise a scroll in search results; a title match counts 10")
+	fmt.Println("  due <id> <when | none> - Set when a scroll falls due: 2026-10-20 15:30, 2026-10-20 or 2h")
+	fmt.Println("  remind [--watch] [--every 1m] - Send a desktop notification for each scroll come due")
+	fmt.Println("  priority <id> <n | none> - Rank a scroll for list --sort priority (highest first)")
+	fmt.Println("  move-up/move-down <id> - Shift a scroll in the order shown by list --sort manual")
+	fmt.Println("  touch <id>      - Mark a scroll as updated now without changing it")
+	fmt.Println("  rename-tag <old> <new> | --from-file <path> - Rename runes across all scrolls")
+	fmt.Println("  diff <idA> <idB> - Compare the contents of two scrolls")
+	fmt.Println("  biggest [n]     - List the n largest scrolls, 10 unless given, with their sizes")
+	fmt.Println("  similar <id> [--top 5] - List the scrolls whose words most resemble a scroll's")
+	fmt.Println("  last / back     - Reopen the last revealed scroll, or step back through history")
+	fmt.Println("  stats [--tag <tag>] [--since <date>] [--until <date>]")
+	fmt.Println("                  - Measure the archives, a rune's scrolls, or a span of time")
+	fmt.Println("  today           - Show today's scrolls and progress toward your word goal")
+	fmt.Println("  goal <words>    - Set a daily word-count goal")
+	fmt.Println("  since <date>    - List scrolls created or updated since a date, by day")
+	fmt.Println("  pin/unpin <id>  - Keep a scroll at the top of the list, or release it")
+	fmt.Println("  outline <id>    - Show a scroll's markdown headings as a table of contents")
+	fmt.Println("  todos [--done]  - List the - [ ] tasks written across all scrolls")
+	fmt.Println("  check/uncheck <id> <n> - Mark a scroll's nth task done or open")
+	fmt.Println("  sync <dir> [--newest] - Merge this notebook with a copy in another directory,")
+	fmt.Println("                  asking which version to keep when both changed (newest wins with --newest)")
+	fmt.Println("  trash           - List the erased scrolls waiting in the trash")
+	fmt.Println("  recover <id>    - Bring a scroll back from the trash")
+	fmt.Println("  empty-trash     - Destroy the scrolls in the trash for good, after confirming")
+	fmt.Println("  tombstones      - List the scrolls whose erasure was recorded")
+	fmt.Println("  purge-tombstones [--older-than 30d] - Forget recorded erasures")
+	fmt.Println("  restore --list | --backup <name> [--yes] - List backups, or restore one")
+	fmt.Println("  compact [--minify | --pretty] - Rewrite scrolls.json, dropping stale fields")
+	fmt.Println("  digest [--last 7d] [--clip] - Summarise recent scrolls as plain text for an email")
+	fmt.Println("  reindex [--yes] - Renumber scrolls 1, 2, 3... updating [[#id]] links")
+	fmt.Println("  import-txt <path> - Inscribe a scroll from a text file; its first line is the title,")
+	fmt.Println("                  and Tags: and Created: lines give its runes and original date")
+	fmt.Println("  export <id> [--with-backlinks] [--out path] - Write a scroll as Markdown, with the")
+	fmt.Println("                  scrolls linking to it")
+	fmt.Println("  export <id> --dir <folder> - Write a scroll as <id>-<slug>.md with its dates, runes")
+	fmt.Println("                  and a copy of its captured image")
+	fmt.Println("  export-md <path> - Write every scroll, newest first, to one Markdown document with")
+	fmt.Println("                  a table of contents; images are copied into images/ beside it")
+	fmt.Println("  export-txt <path> - Write every scroll to one plain-text document")
+	fmt.Println("  export-txt --stream <path> - The same, read and written one scroll at a time")
+	fmt.Println("  where           - Show where the archives live and which tools are used")
+	fmt.Println("  validate-config - Check the settings file for unknown keys and bad values")
+	fmt.Println("  version         - Show which build of the archives you are running")
+	fmt.Println("  notebooks       - List notebooks (open one with --notebook <name>)")
+	fmt.Println("  move <id> --to <notebook> - Move a scroll into another notebook")
+	fmt.Println("  rename-notebook <old> <new> - Give a notebook a new name")
+	fmt.Println()
+}
+
+// markdownHeading matches an ATX heading such as "## Rituals ##".
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+
+// Heading is a markdown heading found on a line of a scroll, counted from 1.
+type Heading struct {
+	Level int
+	Text  string
+	Line  int
+}
+
+// extractHeadings finds the markdown headings in content, skipping fenced
+// code blocks where # starts comments rather than headings.
+func extractHeadings(content string) []Heading {
+	var headings []Heading
+	fenced := false
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			headings = append(headings, Heading{Level: len(m[1]), Text: m[2], Line: i + 1})
+		}
+	}
+	return headings
+}
+
+// ShowOutline prints a scroll's headings as an indented table of contents.
+func (app *NotesApp) ShowOutline(id int) {
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	note := app.Notes[i]
+	
+	headings := extractHeadings(note.Content)
+	fmt.Printf("\n=== Outline of Scroll #%d: %s ===\n", note.ID, note.Title)
+	if len(headings) == 0 {
+		fmt.Println("This scroll bears no headings.")
+		return
+	}
+	for _, h := range headings {
+		fmt.Printf("%4d  %s%s\n", h.Line, strings.Repeat("  ", h.Level-1), h.Text)
+	}
+}
+
+// splitByHeading cuts content at its top-level headings, those of the
+// highest level it uses, and returns the headings and the text under each.
+// Text before the first heading is returned as preamble.
+func splitByHeading(content string) (preamble string, titles, sections []string) {
+	headings := extractHeadings(content)
+	top := 7
+	for _, h := range headings {
+		if h.Level < top {
+			top = h.Level
+		}
+	}
+	
+	lines := strings.Split(content, "\n")
+	start := len(lines)
+	for _, h := range headings {
+		if h.Level != top {
+			continue
+		}
+		if titles == nil {
+			preamble = strings.TrimSpace(strings.Join(lines[:h.Line-1], "\n"))
+		} else {
+			sections = append(sections, strings.TrimSpace(strings.Join(lines[start:h.Line-1], "\n")))
+		}
+		titles = append(titles, h.Text)
+		start = h.Line
+	}
+	if titles != nil {
+		sections = append(sections, strings.TrimSpace(strings.Join(lines[start:], "\n")))
+	}
+	return preamble, titles, sections
+}
+
+// ExplodeNote splits a scroll at its top-level headings into new scrolls
+// titled by heading, each bearing the original's runes. Any text before the
+// first heading keeps the original title. The original is erased when
+// eraseOriginal is set.
+func (app *NotesApp) ExplodeNote(id int, eraseOriginal bool) {
+	if app.isLocked(id) {
+		return
+	}
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	original := app.Notes[i]
+	if original.Type != "text" {
+		app.fail(ExitInvalid, "Scroll #%d is not a text scroll.\n", id)
+		return
+	}
+	
+	preamble, titles, sections := splitByHeading(original.Content)
+	if len(titles) == 0 {
+		fmt.Println("This scroll bears no headings to split it by.")
+		return
+	}
+	if preamble != "" {
+		titles = append([]string{original.Title}, titles...)
+		sections = append([]string{preamble}, sections...)
+	}
+	
+	now := time.Now()
+	for n, title := range titles {
+		note := Note{
+			ID:        app.NextID,
+			Title:     title,
+			Content:   sections[n],
+			Tags:      append([]string(nil), original.Tags...),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Type:      "text",
+		}
+		app.Notes = append(app.Notes, note)
+		app.NextID++
+		fmt.Printf("Created scroll #%d: %s\n", note.ID, note.Title)
+	}
+	
+	if eraseOriginal {
+		app.Notes = append(app.Notes[:i], app.Notes[i+1:]...)
+	}
+	app.SaveNotes()
+	
+	if eraseOriginal {
+		app.recordTombstones(original)
+		fmt.Printf("Scroll #%d has been split into %d scrolls and erased.\n", id, len(titles))
+	} else {
+		fmt.Printf("Scroll #%d has been split into %d scrolls.\n", id, len(titles))
+	}
+}
+
+// taskItem matches a markdown checkbox such as "- [ ] feed the owls".
+var taskItem = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s*(.*)$`)
+
+// Task is a markdown checkbox in a scroll's content. N counts the scroll's
+// checkboxes from 1, in the order they are written.
+type Task struct {
+	N    int
+	Text string
+	Done bool
+}
+
+// extractTasks finds the markdown checkboxes in content.
+func extractTasks(content string) []Task {
+	var tasks []Task
+	for _, line := range strings.Split(content, "\n") {
+		m := taskItem.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		tasks = append(tasks, Task{N: len(tasks) + 1, Text: m[2], Done: m[1] != " "})
+	}
+	return tasks
+}
+
+// setTask marks the nth checkbox in content done or open, leaving the rest of
+// the content untouched.
+func setTask(content string, n int, done bool) (string, error) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	for i, line := range lines {
+		m := taskItem.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		count++
+		if count != n {
+			continue
+		}
+		mark := " "
+		if done {
+			mark = "x"
+		}
+		lines[i] = line[:m[2]] + mark + line[m[3]:]
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", fmt.Errorf("there is no task %d (the scroll has %d)", n, count)
+}
+
+// SetTaskDone checks or unchecks the nth task of a scroll.
+func (app *NotesApp) SetTaskDone(id, n int, done bool) {
+	if app.isLocked(id) {
+		return
+	}
+	i := app.noteIndex(id)
+	if i < 0 {
+		app.notFound(id)
+		return
+	}
+	
+	content, err := setTask(app.Notes[i].Content, n, done)
+	if err != nil {
+		app.fail(ExitInvalid, "Error: %v\n", err)
+		return
+	}
+	if content == app.Notes[i].Content {
+		fmt.Printf("Task %d of scroll #%d is already so marked.\n", n, id)
+		return
+	}
+	app.Notes[i].Content = content
+	app.Notes[i].UpdatedAt = time.Now()
+	app.SaveNotes()
+	
+	if done {
+		fmt.Printf("Task %d of scroll #%d is fulfilled.\n", n, id)
+	} else {
+		fmt.Printf("Task %d of scroll #%d awaits once more.\n", n, id)
+	}
+}
+
+// ShowTodos lists the open checkboxes across all text scrolls, or the
+// completed ones when done is set.
+func (app *NotesApp) ShowTodos(done bool) {
+	notes := make([]Note, len(app.Notes))
+	copy(notes, app.Notes)
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+	
+	heading, mark := "=== Tasks Awaiting ===", "[ ]"
+	if done {
+		heading, mark = "=== Tasks Fulfilled ===", "[x]"
+	}
+	fmt.Printf("\n%s\n", heading)
+	
+	total := 0
+	for _, note := range notes {
+		if note.Type != "text" {
+			continue
+		}
+		var shown []Task
+		for _, task := range extractTasks(note.Content) {
+			if task.Done == done {
+				shown = append(shown, task)
+			}
+		}
+		if len(shown) == 0 {
+			continue
+		}
+		
+		fmt.Printf("\n#%d %s\n", note.ID, note.Title)
+		for _, task := range shown {
+			fmt.Printf("  %d) %s %s\n", task.N, mark, task.Text)
+		}
+		total += len(shown)
+	}
+	
+	if total == 0 {
+		if done {
+			fmt.Println("No tasks have been fulfilled yet.")
+		} else {
+			fmt.Println("No tasks await you. Rest well.")
+		}
+		return
+	}
+	fmt.Printf("\n%d tasks.\n", total)
+}
+
+// DefaultBackupsToKeep is how many backups are kept when the settings do
+// not say.
+const DefaultBackupsToKeep = 5
+
+// backupsDir holds a notebook's timestamped backups.
+func (app *NotesApp) backupsDir() string {
+	return filepath.Join(app.NotesDir, "backups")
+}
+
+// copyTree copies the files under src into dst, leaving out the top-level
+// entries named in skip.
+func copyTree(src, dst string, skip map[string]bool) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if skip[strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// Backup copies the notebook's scrolls, captured images and attachments into
+// a new timestamped folder under backups/ and returns its name. A backup made
+// in the same second as earlier ones is numbered after the highest of them,
+// never reusing a pruned name, so that it still sorts as the newest.
+func (app *NotesApp) Backup() (string, error) {
+	stamp := time.Now().Format("20060102-150405")
+	next := 1
+	for _, existing := range app.listBackups() {
+		if existing == stamp && next < 2 {
+			next = 2
+		} else if k, err := strconv.Atoi(strings.TrimPrefix(existing, stamp+"-")); err == nil && k >= next {
+			next = k + 1
+		}
+	}
+	name := stamp
+	if next > 1 {
+		name = fmt.Sprintf("%s-%d", stamp, next)
+	}
+	dst := filepath.Join(app.backupsDir(), name)
+	
+	// The default notebook's directory also holds the other notebooks.
+	skip := map[string]bool{"backups": true, "notebooks": true}
+	if err := copyTree(app.NotesDir, dst, skip); err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+	return name, nil
+}
+
+// listBackups returns the names of the notebook's backups, oldest first.
+func (app *NotesApp) listBackups() []string {
+	entries, err := ioutil.ReadDir(app.backupsDir())
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pruneBackups removes all but the newest keep backups.
+func (app *NotesApp) pruneBackups(keep int) {
+	names := app.listBackups()
+	for len(names) > keep {
+		if err := os.RemoveAll(filepath.Join(app.backupsDir(), names[0])); err != nil {
+			fmt.Printf("Warning: Could not prune backup %s: %v\n", names[0], err)
+		}
+		names = names[1:]
+	}
+}
+
+// ListBackups shows the notebook's backups, newest first.
+func (app *NotesApp) ListBackups() {
+	names := app.listBackups()
+	if len(names) == 0 {
+		fmt.Println("No backups rest in the archives.")
+		return
+	}
+	
+	fmt.Println("\n=== Backups of the Archives ===")
+	for i := len(names) - 1; i >= 0; i-- {
+		fmt.Println(names[i])
+	}
+}
+
+// RestoreBackup replaces the notebook with one of its backups. The current
+// contents are first moved aside into a backup of their own, named
+// <time>-before-restore, so the restore can itself be undone.
+func (app *NotesApp) RestoreBackup(name string) {
+	src, err := safeJoin(app.backupsDir(), name)
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(src); err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a backup", name)
+		}
+	}
+	if err != nil {
+		app.fail(ExitNotFound, "No backup named %s. Speak 'restore --list' to see them.\n", name)
+		return
+	}
+	
+	asideName := time.Now().Format("20060102-150405") + "-before-restore"
+	aside := filepath.Join(app.backupsDir(), asideName)
+	if err := os.MkdirAll(aside, 0755); err != nil {
+		app.fail(ExitIOError, "Error setting the current archives aside: %v\n", err)
+		return
+	}
+	
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	
+	entries, err := ioutil.ReadDir(app.NotesDir)
+	if err != nil {
+		app.fail(ExitIOError, "Error reading the archives: %v\n", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() == "backups" || entry.Name() == "notebooks" {
+			continue
+		}
+		if err := os.Rename(filepath.Join(app.NotesDir, entry.Name()), filepath.Join(aside, entry.Name())); err != nil {
+			app.fail(ExitIOError, "Error setting the current archives aside: %v\n", err)
+			return
+		}
+	}
+	
+	if err := copyTree(src, app.NotesDir, nil); err != nil {
+		app.fail(ExitIOError, "Error restoring backup %s: %v\nThe previous archives rest in backups/%s.\n", name, err, asideName)
+		return
+	}
+	os.MkdirAll(app.imagesDir(), 0755)
+	
+	app.Notes = []Note{}
+	app.NextID = 1
+	app.Recent = nil
+	app.recentPos = 0
+	app.LastResults = nil
+	app.LoadNotes()
+	app.loadRecent()
+	
+	fmt.Printf("Backup %s has been restored (%d scrolls).\n", name, len(app.Notes))
+	fmt.Printf("The archives as they were rest in backups/%s.\n", asideName)
+}
+
+// autoBackup backs up the notebook on opening when the settings ask for it.
+func (app *NotesApp) autoBackup() {
+	if !app.Settings.AutoBackupOnStart || len(app.Notes) == 0 {
+		return
+	}
+	
+	name, err := app.Backup()
+	if err != nil {
+		fmt.Printf("Warning: Could not back up the archives: %v\n", err)
+		return
+	}
+	keep := app.Settings.BackupsToKeep
+	if keep <= 0 {
+		keep = DefaultBackupsToKeep
+	}
+	app.pruneBackups(keep)
+	fmt.Printf("The archives have been backed up to backups/%s.\n", name)
+}
+
+func (app *NotesApp) Run() {
+	reader := stdin
+	app.menu = true
+	defer func() { app.menu = false }()
+	defer app.flush()
+	
+	fmt.Println("🏛️  Welcome to The Ancient Scrolls! 🏛️")
+	fmt.Printf("The ancient archives are stored in: %s\n", app.NotesDir)
+	if app.Notebook != DefaultNotebook {
+		fmt.Printf("Notebook: %s\n", app.Notebook)
+	}
+	if app.screenshotTool != "" {
+		fmt.Printf("Images are captured with: %s\n", app.screenshotTool)
+	} else {
+		fmt.Println("No screenshot tool was found; image capture is unavailable.")
+	}
+	app.autoBackup()
+	app.ShowHelp()
+	
+	for {
+		fmt.Print("\nSpeak your command, seeker of knowledge (or 'wisdom' for guidance): ")
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			// The input has closed, e.g. piped commands ran out.
+			fmt.Println("\nThe seeker has fallen silent. Farewell! 🏛️")
+			app.waitForEditors()
+			return
+		}
+		fields := strings.Fields(input)
+		app.collectEditorResults()
+		if !app.Execute(reader, fields) {
+			app.waitForEditors()
+			return
+		}
+	}
+}
+
+// parseCommandFlags parses flags that may appear anywhere among a command's
+// arguments and returns the remaining positional arguments in order.
+func (app *NotesApp) parseCommandFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			app.ExitCode = ExitInvalid
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// argOrPrompt returns the command's first argument, asking the seeker for it
+// when none was given.
+func argOrPrompt(reader *bufio.Reader, args []string, prompt string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	fmt.Print(prompt)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// readMultiline reads a scroll's content line by line until a line holding
+// only "." or the end of input (Ctrl+D). An empty first line ends it at
+// once, so that pressing Enter leaves the content empty.
+func readMultiline(reader *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." || len(lines) == 0 && line == "" && err == nil {
+			break
+		}
+		if line != "" || err == nil {
+			lines = append(lines, line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// ShowWhere reports where the archives and settings live and which tools are in use.
+func (app *NotesApp) ShowWhere() {
+	source := func(name string) string {
+		if src, ok := app.SettingSources[name]; ok {
+			return " (from " + src + ")"
+		}
+		return ""
+	}
+	
+	fmt.Printf("Settings: %s\n", settingsPath())
+	fmt.Printf("Archives: %s%s\n", app.NotesDir, source("notes-dir"))
+	fmt.Printf("Notebook: %s\n", app.Notebook)
+	storage := app.Settings.Storage
+	if storage == "" {
+		storage = StorageJSON
+	}
+	fmt.Printf("Store: %s (%s)%s\n", app.store.Location(), storage, source("storage"))
+	fmt.Printf("Editor: %s%s\n", app.Settings.Editor, source("editor"))
+	fmt.Printf("Date format: %s%s\n", app.Settings.DateFormat, source("date-format"))
+	
+	tool := app.currentScreenshotTool()
+	switch {
+	case tool == "" && app.Settings.ScreenshotTool != "":
+		fmt.Printf("Screenshot tool: %s (not installed)%s\n", app.Settings.ScreenshotTool, source("screenshot-tool"))
+	case tool == "":
+		fmt.Println("Screenshot tool: none found")
+	default:
+		fmt.Printf("Screenshot tool: %s%s\n", tool, source("screenshot-tool"))
+	}
+	fmt.Println("Precedence: command-line flags, then SKELOS_* environment variables, then the settings file, then defaults.")
+}
+
+// checkSettingsFile finds the problems in the text of a settings file: keys
+// the archives do not know, values of the wrong type and values outside
+// those a setting accepts. Such settings are otherwise quietly ignored or
+// lost to defaults.
+func checkSettingsFile(data []byte) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []string{fmt.Sprintf("the file is not valid JSON: %v", err)}
+	}
+	
+	fields := make(map[string]reflect.Type)
+	t := reflect.TypeOf(Settings{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = t.Field(i).Type
+	}
+	
+	var problems []string
+	var keys []string
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		typ, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting", key))
+			continue
+		}
+		if err := json.Unmarshal(raw[key], reflect.New(typ).Interface()); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: expected %s, found %s", key, typ, raw[key]))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	
+	var settings Settings
+	json.Unmarshal(data, &settings)
+	if !validStorage(settings.Storage) {
+		problems = append(problems, fmt.Sprintf("storage: %q is neither json nor markdown", settings.Storage))
+	}
+	switch settings.TagDelimiter {
+	case "", TagDelimiterComma, TagDelimiterSpace, TagDelimiterSemicolon:
+	default:
+		problems = append(problems, fmt.Sprintf("tag_delimiter: %q is not comma, space or semicolon", settings.TagDelimiter))
+	}
+	switch settings.PinOverflow {
+	case "", PinRefuse, PinEvict:
+	default:
+		problems = append(problems, fmt.Sprintf("pin_overflow: %q is neither refuse nor evict", settings.PinOverflow))
+	}
+	if tool := settings.ScreenshotTool; tool != "" {
+		known := false
+		for _, name := range screenshotTools() {
+			known = known || name == tool
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("screenshot_tool: %q is not one of %s", tool, strings.Join(screenshotTools(), ", ")))
+		}
+	}
+	for key, n := range map[string]int{
+		"daily_word_goal":  settings.DailyWordGoal,
+		"backups_to_keep":  settings.BackupsToKeep,
+		"max_pinned":       settings.MaxPinned,
+		"list_title_width": settings.ListTitleWidth,
+	} {
+		if n < 0 {
+			problems = append(problems, fmt.Sprintf("%s: %d is negative", key, n))
+		}
+	}
+	for _, entry := range settings.TagStyles {
+		if !strings.Contains(entry, "=") {
+			problems = append(problems, fmt.Sprintf("tag_styles: %q is not of the form rune=color icon", entry))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// ValidateConfig checks the settings file without changing anything and
+// shows the settings in effect, after any overrides.
+func (app *NotesApp) ValidateConfig() {
+	path := settingsPath()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("No settings file at %s; the defaults are in effect.\n", path)
+	} else if err != nil {
+		app.fail(ExitIOError, "Error reading settings: %v\n", err)
+		return
+	} else if problems := checkSettingsFile(data); len(problems) > 0 {
+		fmt.Printf("Problems in %s:\n", path)
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+		app.fail(ExitInvalid, "The settings file has %d problems; the settings in effect may not be the ones intended.\n", len(problems))
+	} else {
+		fmt.Printf("%s is valid.\n", path)
+	}
+	
+	effective, _ := json.MarshalIndent(app.Settings, "", "  ")
+	fmt.Printf("\nSettings in effect:\n%s\n", effective)
+}
+
+// Execute carries out a single command, given as the words the seeker spoke
+// (or passed on the command line). It returns false once the seeker departs.
+func (app *NotesApp) Execute(reader *bufio.Reader, fields []string) bool {
+	command := ""
+	var args []string
+	if len(fields) > 0 {
+		command = strings.ToLower(fields[0])
+		args = fields[1:]
+	}
+	
+	switch command {
+	case "1", "inscribe", "add":
+		fmt.Print("Enter the title of your scroll: ")
+		title, _ := reader.ReadString('\n')
+		title = strings.TrimSpace(title)
+		if !app.hasTitle(title) {
+			break
+		}
+		
+		fmt.Println("Inscribe your knowledge, ending with a line holding only '.' or Ctrl+D:")
+		content := readMultiline(reader)
+		
+		fmt.Printf("Mark with ancient runes (tags, %s, optional): ", app.tagInputHint())
+		tagsInput, _ := reader.ReadString('\n')
+		tagsInput = strings.TrimSpace(tagsInput)
+		
+		tags := app.parseTagInput(tagsInput)
+		
+		app.CreateTextNote(title, content, tags)
+		
+	case "2", "capture", "screenshot":
+		if app.currentScreenshotTool() == "" {
+			app.offerCaptureAlternatives(reader)
+			break
+		}
+		
+		fmt.Print("Enter the title for your captured image: ")
+		title, _ := reader.ReadString('\n')
+		title = strings.TrimSpace(title)
+		if !app.hasTitle(title) {
+			break
+		}
+		
+		fmt.Printf("Mark with ancient runes (tags, %s, optional): ", app.tagInputHint())
+		tagsInput, _ := reader.ReadString('\n')
+		tagsInput = strings.TrimSpace(tagsInput)
+		
+		tags := app.parseTagInput(tagsInput)
+		
+		app.TakeScreenshot(title, tags)
+		
+	case "paste-image", "paste":
+		fs := flag.NewFlagSet("paste-image", flag.ContinueOnError)
+		tagsInput := fs.String("tags", "", app.tagInputHint()+" runes for the scroll")
+		words, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		
+		title := strings.Join(words, " ")
+		if title == "" {
+			app.fail(ExitInvalid, "Usage: paste-image <title> [--tags a,b]\n")
+			break
+		}
+		
+		tags := app.parseTagInput(*tagsInput)
+		
+		app.PasteImage(title, tags)
+		
+	case "3", "archive", "list":
+		fs := flag.NewFlagSet("list", flag.ContinueOnError)
+		format := fs.String("output", app.Output, "output format: table, json, csv, jsonl, ids or oneline")
+		idsOnly := fs.Bool("ids", false, "print only scroll IDs, one per line")
+		oneline := fs.Bool("oneline", false, "print one scroll per line: #<id> <title> [tags]")
+		clip := fs.Bool("clip", false, "copy the output to the clipboard instead of printing it")
+		order := fs.String("sort", SortCreated, "order of the scrolls: created (newest first), manual or priority")
+		withPreview := fs.Bool("with-preview", false, "show the first two lines of each scroll's content")
+		unread := fs.Bool("unread", false, "list only the scrolls not yet read")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		if *order != SortCreated && *order != SortManual && *order != SortPriority {
+			app.fail(ExitInvalid, "Unknown sort: %s (use created, manual or priority)\n", *order)
+			break
+		}
+		if *oneline {
+			*format = OutputOneline
+		}
+		if *idsOnly {
+			*format = OutputIDs
+		}
+		if !validOutputFormat(*format) {
+			app.fail(ExitInvalid, "Unknown output format: %s (use table, json, csv, jsonl, ids or oneline)\n", *format)
+			break
+		}
+		app.ListNotes(ListOptions{Format: *format, Clip: *clip, Sort: *order, WithPreview: *withPreview, Unread: *unread})
+		
+	case "4", "reveal", "view":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to reveal: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.ViewNote(id)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "5", "seek", "search":
+		fs := flag.NewFlagSet("seek", flag.ContinueOnError)
+		format := fs.String("output", app.Output, "output format: table, json, csv, jsonl, ids or oneline")
+		idsOnly := fs.Bool("ids", false, "print only scroll IDs, one per line")
+		oneline := fs.Bool("oneline", false, "print one scroll per line: #<id> <title> [tags]")
+		clip := fs.Bool("clip", false, "copy the output to the clipboard instead of printing it")
+		in := fs.String("in", "title,content,tags", "parts of each scroll to search, comma-separated")
+		caseSensitive := fs.Bool("case-sensitive", false, "match the terms' case exactly")
+		regex := fs.Bool("regex", false, "treat the query as a regular expression")
+		words, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		scope, err := parseSearchScope(*in)
+		if err != nil {
+			app.fail(ExitInvalid, "Error: %v\n", err)
+			break
+		}
+		mode := SearchPlain
+		if *caseSensitive && *regex {
+			app.fail(ExitInvalid, "Use --case-sensitive or --regex, not both; add (?i) to a regular expression to ignore case.\n")
+			break
+		} else if *caseSensitive {
+			mode = SearchCaseSensitive
+		} else if *regex {
+			mode = SearchRegex
+		}
+		if *oneline {
+			*format = OutputOneline
+		}
+		if *idsOnly {
+			*format = OutputIDs
+		}
+		if !validOutputFormat(*format) {
+			app.fail(ExitInvalid, "Unknown output format: %s (use table, json, csv, jsonl, ids or oneline)\n", *format)
+			break
+		}
+		
+		query := strings.Join(words, " ")
+		if query == "" {
+			fmt.Print("What knowledge do you seek?: ")
+			query, _ = reader.ReadString('\n')
+			query = strings.TrimSpace(query)
+		}
+		
+		if query != "" {
+			app.SearchNotes(query, SearchOptions{Scope: scope, Mode: mode}, ListOptions{Format: *format, Clip: *clip})
+		} else {
+			app.fail(ExitInvalid, "You must speak your query to seek knowledge.\n")
+		}
+		
+	case "view-result", "result":
+		nInput := argOrPrompt(reader, args, "Enter the result number to reveal: ")
+		
+		n, err := strconv.Atoi(nInput)
+		if err != nil {
+			app.fail(ExitInvalid, "Invalid result number. Please enter a number.\n")
+		} else if id, ok := app.ResultID(n); ok {
+			app.ViewNote(id)
+		} else if len(app.LastResults) == 0 {
+			fmt.Println("No search results to follow. Seek knowledge first.")
+		} else {
+			fmt.Printf("Result %d does not exist; the last search found %d scrolls.\n", n, len(app.LastResults))
+		}
+		
+	case "6", "modify", "edit":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to modify: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.EditScroll(id)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "7", "retitle":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to retitle: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.RetitleScroll(id)
+		} else {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+		}
+		
+	case "8", "retag":
+		fs := flag.NewFlagSet("retag", flag.ContinueOnError)
+		fromStdin := fs.Bool("stdin", false, "read scroll IDs from stdin, one per line")
+		tagsInput := fs.String("tags", "", app.tagInputHint()+" runes to set without prompting")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		
+		if *fromStdin {
+			given := false
+			fs.Visit(func(f *flag.Flag) { given = given || f.Name == "tags" })
+			if !given {
+				app.fail(ExitInvalid, "Usage: retag --stdin --tags a,b\n")
+				break
+			}
+			if idList, err := app.readIDs(reader); err == nil {
+				app.RetagNotes(idList, app.parseTagInput(*tagsInput))
+			} else {
+				app.fail(ExitInvalid, "Error reading scroll IDs: %v\n", err)
+			}
+			break
+		}
+		
+		idInput := argOrPrompt(reader, ids, "Enter the scroll ID to retag: ")
+		
+		if id, err := strconv.Atoi(idInput); err != nil {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+		} else if *tagsInput != "" {
+			app.RetagNotes([]int{id}, app.parseTagInput(*tagsInput))
+		} else {
+			app.RetagScroll(id)
+		}
+		
+	case "rename-tag":
+		fs := flag.NewFlagSet("rename-tag", flag.ContinueOnError)
+		fromFile := fs.String("from-file", "", "file of old=new lines to apply in one pass")
+		names, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		
+		var renames []tagRename
+		if *fromFile != "" {
+			data, err := ioutil.ReadFile(*fromFile)
+			if err != nil {
+				app.fail(ExitIOError, "Error reading rune mapping: %v\n", err)
+				break
+			}
+			var problems []string
+			renames, problems = parseTagRenames(string(data))
+			for _, problem := range problems {
+				fmt.Printf("Skipping %s\n", problem)
+			}
+		} else if len(names) == 2 {
+			renames = []tagRename{{From: names[0], To: names[1]}}
+		} else {
+			app.fail(ExitInvalid, "Usage: rename-tag <old> <new> or rename-tag --from-file mapping.txt\n")
+			break
+		}
+		
+		if len(renames) == 0 {
+			fmt.Println("No runes to rename.")
+			break
+		}
+		app.RenameTags(renames)
+		
+	case "stats":
+		fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+		tag := fs.String("tag", "", "only measure scrolls bearing this rune")
+		since := fs.String("since", "", "only measure scrolls inscribed on or after this date")
+		until := fs.String("until", "", "only measure scrolls inscribed up to this date")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		
+		opts := StatsOptions{Tag: *tag}
+		var err error
+		if *since != "" {
+			if opts.Since, err = app.parseDate(*since); err != nil {
+				app.fail(ExitInvalid, "Error: %v\n", err)
+				break
+			}
+		}
+		if *until != "" {
+			if opts.Until, err = app.parseDate(*until); err != nil {
+				app.fail(ExitInvalid, "Error: %v\n", err)
+				break
+			}
+			// A bare date covers the whole of that day.
+			if h, m, sec := opts.Until.Clock(); h == 0 && m == 0 && sec == 0 {
+				opts.Until = opts.Until.AddDate(0, 0, 1)
+			}
+		}
+		app.ShowStats(opts)
+		
+	case "today":
+		app.ShowToday()
+		
+	case "goal":
+		if len(args) != 1 {
+			app.fail(ExitInvalid, "Usage: goal <words per day> (0 clears the goal)\n")
+			break
+		}
+		if words, err := strconv.Atoi(args[0]); err == nil && words >= 0 {
+			app.SetWordGoal(words)
+		} else {
+			app.fail(ExitInvalid, "Invalid goal. Please enter a number of words.\n")
+		}
+		
+	case "version":
+		fmt.Println(versionString())
+		
+	case "since":
+		if len(args) == 0 {
+			app.fail(ExitInvalid, "Usage: since <date>\n")
+			break
+		}
+		if since, err := app.parseDate(strings.Join(args, " ")); err == nil {
+			app.ShowSince(since)
+		} else {
+			app.fail(ExitInvalid, "Error: %v\n", err)
+		}
+		
+	case "outline", "toc":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to outline: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.ShowOutline(id)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "explode":
+		fs := flag.NewFlagSet("explode", flag.ContinueOnError)
+		fs.Bool("by-heading", true, "split at top-level headings (the only way for now)")
+		eraseOriginal := fs.Bool("delete", false, "erase the original scroll afterwards")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		idInput := argOrPrompt(reader, ids, "Enter the scroll ID to split: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.ExplodeNote(id, *eraseOriginal)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "mark-read", "mark-unread":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to mark "+strings.TrimPrefix(command, "mark-")+": ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.MarkRead(id, command == "mark-read")
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "pin", "unpin":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to "+command+": ")
+		
+		if id, err := strconv.Atoi(idInput); err != nil {
+			app.invalidID(idInput)
+		} else if command == "pin" {
+			app.PinNote(reader, id)
+		} else {
+			app.UnpinNote(id)
+		}
+		
+	case "tag-tree", "rune-tree":
+		app.ShowTagTree()
+		
+	case "todos", "tasks":
+		fs := flag.NewFlagSet("todos", flag.ContinueOnError)
+		done := fs.Bool("done", false, "list completed tasks instead of open ones")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		app.ShowTodos(*done)
+		
+	case "check", "uncheck":
+		if len(args) != 2 {
+			app.fail(ExitInvalid, "Usage: %s <id> <task number>\n", command)
+			break
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			app.invalidID(args[0])
+			break
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			app.fail(ExitInvalid, "Invalid task number: %s\n", args[1])
+			break
+		}
+		app.SetTaskDone(id, n, command == "check")
+		
+	case "sync":
+		fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+		newest := fs.Bool("newest", false, "settle conflicts by keeping the newer version without asking")
+		rest, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		dir := argOrPrompt(reader, rest, "Enter the directory to sync with: ")
+		if dir == "" {
+			app.fail(ExitInvalid, "A directory is required.\n")
+			break
+		}
+		if *newest || !isTerminal(os.Stdin) {
+			reader = nil
+		}
+		app.Sync(reader, dir)
+		
+	case "trash":
+		app.ListTrash()
+		
+	case "recover":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to recover from the trash: ")
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.RestoreNote(reader, id)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "empty-trash":
+		app.EmptyTrash(reader)
+		
+	case "tombstones":
+		app.ShowTombstones()
+		
+	case "purge-tombstones":
+		fs := flag.NewFlagSet("purge-tombstones", flag.ContinueOnError)
+		olderThan := fs.String("older-than", "", "only purge tombstones older than this span, e.g. 30d")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		var age time.Duration
+		if *olderThan != "" {
+			var err error
+			if age, err = parseSpan(*olderThan); err != nil {
+				app.fail(ExitInvalid, "Error: %v\n", err)
+				break
 			}
-			
-			app.CreateTextNote(title, content, tags)
-			
-		case "2", "capture", "screenshot":
-			fmt.Print("Enter the title for your captured image: ")
-			title, _ := reader.ReadString('\n')
-			title = strings.TrimSpace(title)
-			
-			fmt.Print("Mark with ancient runes (tags, comma-separated, optional): ")
-			tagsInput, _ := reader.ReadString('\n')
-			tagsInput = strings.TrimSpace(tagsInput)
-			
-			var tags []string
-			if tagsInput != "" {
-				tags = strings.Split(tagsInput, ",")
-				for i, tag := range tags {
-					tags[i] = strings.TrimSpace(tag)
-				}
+		}
+		app.PurgeTombstones(age)
+		
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+		list := fs.Bool("list", false, "list the available backups")
+		backup := fs.String("backup", "", "name of the backup to restore")
+		yes := fs.Bool("yes", false, "restore without asking for confirmation")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		if *list || *backup == "" {
+			app.ListBackups()
+			if *backup == "" && !*list {
+				fmt.Println("Speak 'restore --backup <name>' to restore one.")
 			}
-			
-			app.TakeScreenshot(title, tags)
-			
-		case "3", "archive", "list":
-			app.ListNotes()
-			
-		case "4", "reveal", "view":
-			fmt.Print("Enter the scroll ID to reveal: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				app.ViewNote(id)
-			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+			break
+		}
+		
+		if !*yes {
+			fmt.Printf("Replace the %d scrolls of this notebook with backup %s? The current archives will be set aside. (y/n): ", len(app.Notes), *backup)
+			confirm, _ := reader.ReadString('\n')
+			confirm = strings.TrimSpace(strings.ToLower(confirm))
+			if confirm != "y" && confirm != "yes" {
+				fmt.Println("The archives remain as they are.")
+				break
 			}
-			
-		case "5", "seek", "search":
-			fmt.Print("What knowledge do you seek?: ")
-			query, _ := reader.ReadString('\n')
-			query = strings.TrimSpace(query)
-			
-			if query != "" {
-				app.SearchNotes(query)
-			} else {
-				fmt.Println("You must speak your query to seek knowledge.")
+		}
+		app.RestoreBackup(*backup)
+		
+	case "compact":
+		fs := flag.NewFlagSet("compact", flag.ContinueOnError)
+		minify := fs.Bool("minify", false, "write the archives without indentation from now on")
+		pretty := fs.Bool("pretty", false, "write the archives indented from now on")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		if *minify && *pretty {
+			app.fail(ExitInvalid, "Choose either --minify or --pretty, not both.\n")
+			break
+		}
+		
+		var style *bool
+		if *minify || *pretty {
+			style = minify
+		}
+		app.Compact(style)
+		
+	case "digest":
+		fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+		last := fs.String("last", "7d", "span of time to summarise, e.g. 7d, 2w or 36h")
+		clip := fs.Bool("clip", false, "copy the digest to the clipboard instead of printing it")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		if span, err := parseSpan(*last); err == nil {
+			app.ShowDigest(span, *clip)
+		} else {
+			app.fail(ExitInvalid, "Error: %v\n", err)
+		}
+		
+	case "reindex":
+		fs := flag.NewFlagSet("reindex", flag.ContinueOnError)
+		yes := fs.Bool("yes", false, "renumber without asking for confirmation")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		
+		if !*yes {
+			fmt.Printf("Renumber all %d scrolls by creation order? Their IDs will change. (y/n): ", len(app.Notes))
+			confirm, _ := reader.ReadString('\n')
+			confirm = strings.TrimSpace(strings.ToLower(confirm))
+			if confirm != "y" && confirm != "yes" {
+				fmt.Println("The scrolls keep their numbers.")
+				break
 			}
-			
-		case "6", "modify", "edit":
-			fmt.Print("Enter the scroll ID to modify: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				app.EditScroll(id)
-			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+		}
+		app.Reindex()
+		
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ContinueOnError)
+		withBacklinks := fs.Bool("with-backlinks", false, "list the scrolls that link to this one")
+		out := fs.String("out", "", "file to write instead of printing")
+		dir := fs.String("dir", "", "folder to write <id>-<slug>.md into, with a copy of any captured image")
+		rest, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if *dir != "" && (*out != "" || *withBacklinks) {
+			app.fail(ExitInvalid, "--dir cannot be combined with --out or --with-backlinks.\n")
+			break
+		}
+		idInput := argOrPrompt(reader, rest, "Enter the scroll ID to export: ")
+		id, err := strconv.Atoi(idInput)
+		if err != nil {
+			app.invalidID(idInput)
+			break
+		}
+		if len(rest) == 0 && *out == "" && *dir == "" && !*withBacklinks {
+			fmt.Print("Enter the folder to export to (press Enter to print it here): ")
+			folder, _ := reader.ReadString('\n')
+			*dir = strings.TrimSpace(folder)
+		}
+		
+		if *dir != "" {
+			app.ExportMarkdown(id, *dir)
+		} else {
+			app.ExportScroll(id, *out, *withBacklinks)
+		}
+		
+	case "export-md":
+		if len(args) != 1 {
+			app.fail(ExitInvalid, "Usage: export-md <path>\n")
+			break
+		}
+		app.ExportAllMarkdown(args[0])
+		
+	case "export-txt":
+		fs := flag.NewFlagSet("export-txt", flag.ContinueOnError)
+		stream := fs.Bool("stream", false, "write one scroll at a time from the store, for very large archives")
+		rest, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if len(rest) != 1 {
+			app.fail(ExitInvalid, "Usage: export-txt [--stream] <path>\n")
+			break
+		}
+		if *stream {
+			app.StreamPlainText(rest[0])
+		} else {
+			app.ExportPlainText(rest[0])
+		}
+		
+	case "import-txt":
+		if len(args) == 0 {
+			app.fail(ExitInvalid, "Usage: import-txt <path>\n")
+			break
+		}
+		app.ImportText(strings.Join(args, " "))
+		
+	case "where":
+		app.ShowWhere()
+		
+	case "validate-config":
+		app.ValidateConfig()
+		
+	case "notebooks":
+		app.ListNotebooks()
+		
+	case "rename-notebook":
+		if len(args) != 2 {
+			app.fail(ExitInvalid, "Usage: rename-notebook <old> <new>\n")
+			break
+		}
+		app.RenameNotebook(args[0], args[1])
+		
+	case "move":
+		fs := flag.NewFlagSet("move", flag.ContinueOnError)
+		to := fs.String("to", "", "notebook to move the scroll into")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if len(ids) != 1 || *to == "" {
+			app.fail(ExitInvalid, "Usage: move <id> --to <notebook>\n")
+			break
+		}
+		
+		if id, err := strconv.Atoi(ids[0]); err == nil {
+			app.MoveNote(id, *to)
+		} else {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+		}
+		
+	case "last":
+		app.ViewLast()
+		
+	case "back":
+		app.ViewBack()
+		
+	case "biggest":
+		n := 10
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				app.fail(ExitInvalid, "Invalid count: %s (use a positive number)\n", args[0])
+				break
 			}
-			
-		case "7", "retitle":
-			fmt.Print("Enter the scroll ID to retitle: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				app.RetitleScroll(id)
-			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+		}
+		app.ShowBiggest(n)
+		
+	case "similar", "find-similar":
+		fs := flag.NewFlagSet("similar", flag.ContinueOnError)
+		top := fs.Int("top", 5, "how many of the closest scrolls to list")
+		rest, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		idInput := argOrPrompt(reader, rest, "Enter the scroll ID to find resemblances of: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.ShowSimilar(id, *top)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "diff", "compare":
+		if len(args) != 2 {
+			app.fail(ExitInvalid, "Usage: diff <idA> <idB>\n")
+			break
+		}
+		idA, errA := strconv.Atoi(args[0])
+		idB, errB := strconv.Atoi(args[1])
+		if errA != nil || errB != nil {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+			break
+		}
+		app.DiffScrolls(idA, idB)
+		
+	case "update":
+		fs := flag.NewFlagSet("update", flag.ContinueOnError)
+		title := fs.String("title", "", "new title")
+		tagsInput := fs.String("tags", "", "new "+app.tagInputHint()+" runes (empty removes all)")
+		content := fs.String("content", "", "new content")
+		contentFile := fs.String("content-file", "", "file holding the new content")
+		appendContent := fs.Bool("append", false, "append the content instead of replacing it")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if len(ids) != 1 {
+			app.fail(ExitInvalid, "Usage: update <id> [--title t] [--tags a,b] [--content c | --content-file f] [--append]\n")
+			break
+		}
+		id, err := strconv.Atoi(ids[0])
+		if err != nil {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+			break
+		}
+		
+		update := noteUpdate{Append: *appendContent}
+		given := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		
+		if given["content"] && given["content-file"] {
+			app.fail(ExitInvalid, "Give either --content or --content-file, not both.\n")
+			break
+		}
+		if given["title"] {
+			update.Title = title
+		}
+		if given["tags"] {
+			tags := app.parseTagInput(*tagsInput)
+			update.Tags = &tags
+		}
+		if given["content"] {
+			update.Content = content
+		}
+		if given["content-file"] {
+			data, err := ioutil.ReadFile(*contentFile)
+			if err != nil {
+				app.fail(ExitIOError, "Error reading content: %v\n", err)
+				break
 			}
-			
-		case "8", "retag":
-			fmt.Print("Enter the scroll ID to retag: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				app.RetagScroll(id)
-			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+			text := strings.TrimRight(string(data), "\n")
+			update.Content = &text
+		}
+		
+		app.UpdateNote(id, update)
+		
+	case "append":
+		if len(args) == 0 {
+			app.fail(ExitInvalid, "Usage: append <id> [text], or pipe the text in\n")
+			break
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			app.invalidID(args[0])
+			break
+		}
+		
+		update := noteUpdate{Append: true}
+		var text string
+		if len(args) > 1 {
+			text = strings.Join(args[1:], " ")
+		} else if !app.menu && !isTerminal(os.Stdin) {
+			// Piped output is kept exactly as it came
+			data, err := ioutil.ReadAll(reader)
+			if err != nil {
+				app.fail(ExitIOError, "Error reading stdin: %v\n", err)
+				break
 			}
-			
-		case "9", "recapture":
-			fmt.Print("Enter the scroll ID to recapture: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				app.RecaptureImage(id)
+			text = string(data)
+			update.Literal = true
+		} else {
+			fmt.Print("Inscribe the text to append: ")
+			text, _ = reader.ReadString('\n')
+			text = strings.TrimSpace(text)
+		}
+		if text == "" {
+			app.fail(ExitInvalid, "Nothing to append.\n")
+			break
+		}
+		update.Content = &text
+		app.UpdateNote(id, update)
+		
+	case "patch":
+		fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+		patchFile := fs.String("file", "", "JSON file holding any of title, content and tags")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if len(ids) != 1 || *patchFile == "" {
+			app.fail(ExitInvalid, "Usage: patch <id> --file changes.json\n")
+			break
+		}
+		id, err := strconv.Atoi(ids[0])
+		if err != nil {
+			app.invalidID(ids[0])
+			break
+		}
+		
+		data, err := ioutil.ReadFile(*patchFile)
+		if err != nil {
+			app.fail(ExitIOError, "Error reading patch: %v\n", err)
+			break
+		}
+		if update, err := parsePatch(data); err == nil {
+			app.UpdateNote(id, update)
+		} else {
+			app.fail(ExitInvalid, "Invalid patch: %v\n", err)
+		}
+		
+	case "write", "open-editor":
+		fs := flag.NewFlagSet("write", flag.ContinueOnError)
+		background := fs.Bool("background", false, "keep the archives usable while the editor is open")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		idInput := argOrPrompt(reader, ids, "Enter the scroll ID to open in your editor: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.EditInEditor(id, *background)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "attach-file", "attach":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to attach a file to: ")
+		id, err := strconv.Atoi(idInput)
+		if err != nil {
+			app.invalidID(idInput)
+			break
+		}
+		
+		var path string
+		if len(args) > 1 {
+			path = strings.Join(args[1:], " ")
+		} else {
+			fmt.Print("Path of the file to attach: ")
+			path, _ = reader.ReadString('\n')
+			path = strings.TrimSpace(path)
+		}
+		if path == "" {
+			app.fail(ExitInvalid, "A file path is required.\n")
+			break
+		}
+		app.AttachFile(id, path)
+		
+	case "mirror":
+		fs := flag.NewFlagSet("mirror", flag.ContinueOnError)
+		off := fs.Bool("off", false, "stop mirroring the scroll")
+		rest, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		if len(rest) == 0 || (*off) == (len(rest) > 1) {
+			app.fail(ExitInvalid, "Usage: mirror <id> <path> | mirror <id> --off\n")
+			break
+		}
+		id, err := strconv.Atoi(rest[0])
+		if err != nil {
+			app.invalidID(rest[0])
+			break
+		}
+		app.SetMirror(id, strings.Join(rest[1:], " "))
+		
+	case "missing-images":
+		fs := flag.NewFlagSet("missing-images", flag.ContinueOnError)
+		convert := fs.Bool("convert", false, "turn the scrolls into text scrolls")
+		clear := fs.Bool("clear", false, "forget the missing files, keeping the scrolls as they are")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		
+		if *convert && *clear {
+			app.fail(ExitInvalid, "Choose either --convert or --clear, not both.\n")
+			break
+		}
+		
+		fix := ""
+		if *convert {
+			fix = "convert"
+		} else if *clear {
+			fix = "clear"
+		}
+		app.ShowMissingImages(fix)
+		
+	case "backfill-ocr":
+		app.BackfillOCR()
+		
+	case "relocate-images":
+		dir := argOrPrompt(reader, args, "Enter the new directory for captured images: ")
+		if dir == "" {
+			app.fail(ExitInvalid, "A directory is required.\n")
+			break
+		}
+		app.RelocateImages(dir)
+		
+	case "rename-screenshot":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID whose captured image to rename: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.RenameScreenshot(id)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "boost":
+		if len(args) != 2 {
+			app.fail(ExitInvalid, "Usage: boost <id> <n | none>\n")
+			break
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			app.invalidID(args[0])
+			break
+		}
+		
+		boost := 0
+		if strings.ToLower(args[1]) != "none" {
+			if boost, err = strconv.Atoi(args[1]); err != nil {
+				app.fail(ExitInvalid, "Invalid boost: %s (use a whole number or none)\n", args[1])
+				break
+			}
+		}
+		app.SetSearchBoost(id, boost)
+		
+	case "due":
+		if len(args) < 2 {
+			app.fail(ExitInvalid, "Usage: due <id> <when | none>\n")
+			break
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			app.invalidID(args[0])
+			break
+		}
+		
+		var due *time.Time
+		if when := strings.Join(args[1:], " "); strings.ToLower(when) != "none" {
+			t, err := parseDue(when, time.Now())
+			if err != nil {
+				app.fail(ExitInvalid, "Error: %v\n", err)
+				break
+			}
+			due = &t
+		}
+		app.SetDue(id, due)
+		
+	case "remind":
+		fs := flag.NewFlagSet("remind", flag.ContinueOnError)
+		watch := fs.Bool("watch", false, "keep checking until interrupted")
+		every := fs.Duration("every", time.Minute, "how often to check when watching")
+		if _, err := app.parseCommandFlags(fs, args); err != nil {
+			break
+		}
+		if *watch {
+			if *every <= 0 {
+				app.fail(ExitInvalid, "The interval must be positive.\n")
+				break
+			}
+			app.WatchReminders(*every)
+			break
+		}
+		if len(app.dueNotes(time.Now())) == 0 {
+			fmt.Println("No scrolls have come due.")
+			break
+		}
+		app.CheckReminders()
+		
+	case "priority":
+		if len(args) != 2 {
+			app.fail(ExitInvalid, "Usage: priority <id> <n | none>\n")
+			break
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			app.invalidID(args[0])
+			break
+		}
+		
+		var priority *int
+		if strings.ToLower(args[1]) != "none" {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				app.fail(ExitInvalid, "Invalid priority: %s (use a whole number or none)\n", args[1])
+				break
+			}
+			priority = &n
+		}
+		app.SetPriority(id, priority)
+		
+	case "move-up", "move-down":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to move: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			delta := 1
+			if command == "move-up" {
+				delta = -1
+			}
+			app.MoveScroll(id, delta)
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "touch":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to touch: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.TouchNote(id)
+		} else {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+		}
+		
+	case "9", "recapture":
+		idInput := argOrPrompt(reader, args, "Enter the scroll ID to recapture: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			app.RecaptureImage(id)
+		} else {
+			app.fail(ExitInvalid, "Invalid scroll ID. Please enter a number.\n")
+		}
+		
+	case "10", "erase", "delete":
+		fs := flag.NewFlagSet("erase", flag.ContinueOnError)
+		fromStdin := fs.Bool("stdin", false, "read scroll IDs from stdin, one per line, and erase them without prompting")
+		images := fs.Bool("images", false, "with --stdin, destroy the scrolls and their captured images instead of moving them to the trash")
+		shred := fs.Bool("shred", false, "overwrite the captured image with random data before erasing")
+		preview := fs.Bool("preview", false, "show the whole scroll before asking to erase it")
+		ids, err := app.parseCommandFlags(fs, args)
+		if err != nil {
+			break
+		}
+		
+		if *fromStdin {
+			if app.Settings.StrictDeleteConfirm {
+				app.fail(ExitInvalid, "strict_delete_confirm is set; erase scrolls one at a time, retyping each title.\n")
+				break
+			}
+			if idList, err := app.readIDs(reader); err == nil {
+				app.DeleteNotes(idList, *images)
 			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+				app.fail(ExitInvalid, "Error reading scroll IDs: %v\n", err)
 			}
-			
-		case "10", "erase", "delete":
-			fmt.Print("Enter the scroll ID to erase from existence: ")
-			idInput, _ := reader.ReadString('\n')
-			idInput = strings.TrimSpace(idInput)
-			
-			if id, err := strconv.Atoi(idInput); err == nil {
-				fmt.Printf("Are you certain you wish to erase scroll #%d from the archives? (y/n): ", id)
-				confirm, _ := reader.ReadString('\n')
-				confirm = strings.TrimSpace(strings.ToLower(confirm))
-				
-				if confirm == "y" || confirm == "yes" {
-					app.DeleteNote(id)
-				} else {
-					fmt.Println("The scroll remains preserved in the archives.")
-				}
+			break
+		}
+		
+		idInput := argOrPrompt(reader, ids, "Enter the scroll ID to erase from existence: ")
+		
+		if id, err := strconv.Atoi(idInput); err == nil {
+			if *preview {
+				app.previewScroll(id)
+			}
+			if app.confirmErase(reader, id) {
+				app.DeleteNote(id, DeleteOptions{Shred: *shred})
 			} else {
-				fmt.Println("Invalid scroll ID. Please enter a number.")
+				fmt.Println("The scroll remains preserved in the archives.")
 			}
-			
-		case "11", "wisdom", "help":
-			app.ShowHelp()
-			
-		case "12", "depart", "quit", "exit":
-			fmt.Println("May the ancient wisdom guide you on your journey. Farewell! 🏛️")
-			return
-			
+		} else {
+			app.invalidID(idInput)
+		}
+		
+	case "runes", "tagged":
+		tag := argOrPrompt(reader, args, "Which rune do you seek?: ")
+		
+		mode := TagMatchExact
+		if len(args) > 1 {
+			mode = strings.ToLower(args[1])
+		}
+		
+		switch {
+		case tag == "":
+			app.fail(ExitInvalid, "You must name a rune to seek.\n")
+		case mode != TagMatchExact && mode != TagMatchPrefix && mode != TagMatchSubstring:
+			app.fail(ExitInvalid, "Unknown match mode: %s (use exact, prefix or substring)\n", mode)
 		default:
-			fmt.Printf("Unknown command: %s\n", input)
-			fmt.Println("Speak 'wisdom' to learn the ancient commands.")
+			app.FilterByTag(tag, mode)
 		}
+		
+	case "11", "wisdom", "help":
+		app.ShowHelp()
+		
+	case "12", "depart", "quit", "exit":
+		fmt.Println("May the ancient wisdom guide you on your journey. Farewell! 🏛️")
+		return false
+		
+	default:
+		app.fail(ExitInvalid, "Unknown command: %s\n", strings.Join(fields, " "))
+		fmt.Println("Speak 'wisdom' to learn the ancient commands.")
 	}
+	return true
 }
 
 func main() {
-	app := NewNotesApp()
+	reconfigure := flag.Bool("reconfigure", false, "run the setup wizard again")
+	defaultOutput := OutputTable
+	if value := os.Getenv("SKELOS_OUTPUT"); value != "" {
+		defaultOutput = value
+	}
+	output := flag.String("output", defaultOutput, "output format for list, search and view: table, json, csv, jsonl, ids or oneline (overrides SKELOS_OUTPUT)")
+	jsonOutput := flag.Bool("json", false, "print plain JSON for list, search and view; short for --output json")
+	notebook := flag.String("notebook", DefaultNotebook, "notebook (separate archive) to open")
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	strict := flag.Bool("strict", false, "refuse to open archives holding invalid scrolls")
+	for _, o := range settingOverrides {
+		flag.String(o.Flag, "", o.Usage+" (overrides "+o.Env+" and the settings file)")
+	}
+	flag.Parse()
+	
+	if *showVersion || (flag.NArg() == 1 && flag.Arg(0) == "version") {
+		fmt.Println(versionString())
+		return
+	}
+	
+	if *jsonOutput {
+		*output = OutputJSON
+	}
+	if !validOutputFormat(*output) {
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s (use table, json, csv, jsonl, ids or oneline)\n", *output)
+		os.Exit(ExitInvalid)
+	}
+	if err := validNotebookName(*notebook); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(ExitInvalid)
+	}
+	
+	path := settingsPath()
+	settings, found := LoadSettings(path)
+	// The wizard greets only a seeker at the terminal, never a script
+	// running a command on a machine without settings.
+	if *reconfigure || (!found && flag.NArg() == 0 && isTerminal(os.Stdin)) {
+		settings = RunSetupWizard(stdin, settings)
+		if err := SaveSettings(path, settings); err != nil {
+			fmt.Printf("Error saving settings: %v\n", err)
+		} else {
+			fmt.Printf("Settings inscribed in: %s\n", path)
+			found = true
+		}
+	}
+	
+	flagValues := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { flagValues[f.Name] = f.Value.String() })
+	base := "settings file"
+	if !found {
+		base = "default"
+	}
+	sources := applyOverrides(&settings, base, flagValues)
+	if !validStorage(settings.Storage) {
+		fmt.Fprintf(os.Stderr, "Unknown storage: %s (use json or markdown)\n", settings.Storage)
+		os.Exit(ExitInvalid)
+	}
+	
+	exitOnInterrupt()
+	
+	app := NewNotesApp(settings, *notebook)
+	if *strict && len(app.Invalid) > 0 {
+		fmt.Fprintf(os.Stderr, "Refusing to open the archives: %d invalid scrolls.\n", len(app.Invalid))
+		os.Exit(ExitInvalid)
+	}
+	app.Output = *output
+	app.SettingSources = sources
+	
+	// Words left after the flags form a single command to carry out
+	// without entering the interactive archives.
+	if flag.NArg() > 0 {
+		app.Execute(stdin, flag.Args())
+		app.waitForEditors()
+		app.flush()
+		os.Exit(app.ExitCode)
+	}
 	app.Run()
 }
@@ -0,0 +1,4239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp opens empty archives in a temporary directory, with HOME
+// pointed there too so that no settings file outside it is touched.
+func newTestApp(t *testing.T, storage string) *NotesApp {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	settings := defaultSettings()
+	settings.NotesDir = filepath.Join(dir, "archives")
+	settings.Storage = storage
+	return NewNotesApp(settings, DefaultNotebook)
+}
+
+// captureOutput returns what fn prints to standard output.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureFile returns what fn writes to *f, which is standard output or
+// standard error.
+func captureFile(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *f
+	*f = w
+	defer func() { *f = saved }()
+	
+	out := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestParseMarkdownScrollFrontMatter(t *testing.T) {
+	for _, text := range []string{"---\n---", "---\n---\n", "---\n---\nbody\n"} {
+		note, err := parseMarkdownScroll(text)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", text, err)
+			continue
+		}
+		if note.ID != 0 || note.Title != "" {
+			t.Errorf("%q: empty front matter gave %+v", text, note)
+		}
+	}
+	if note, _ := parseMarkdownScroll("---\n---\nbody\n"); note.Content != "body" {
+		t.Errorf("content after empty front matter = %q, want %q", note.Content, "body")
+	}
+	
+	for _, text := range []string{"", "no front matter", "---\n", "---\nid: 1", "---\nid: 1\n--", "---\nid: x\n---\n"} {
+		if _, err := parseMarkdownScroll(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestMarkdownScrollRoundTrip(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	due := created.Add(48 * time.Hour)
+	note := Note{
+		ID:        7,
+		Title:     "A \"quoted\": title",
+		Type:      "text",
+		Content:   "first line\n\n---\nafter a rule",
+		Tags:      []string{"alpha", "work/project"},
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Hour),
+		DueAt:     &due,
+		Read:      true,
+	}
+	
+	got, err := parseMarkdownScroll(formatMarkdownScroll(note))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, note) {
+		t.Errorf("round trip changed the scroll:\n got %+v\nwant %+v", got, note)
+	}
+	
+	empty := Note{ID: 8, Title: "Empty", Type: "text", CreatedAt: created, UpdatedAt: created}
+	got, err = parseMarkdownScroll(formatMarkdownScroll(empty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "" || got.Title != "Empty" || !strings.HasPrefix(formatMarkdownScroll(empty), "---\n") {
+		t.Errorf("round trip of an empty scroll gave %+v", got)
+	}
+	
+	for _, content := range []string{"ends in a newline\n", "two of them\n\n", "\n"} {
+		note.Content = content
+		for i := 0; i < 3; i++ {
+			if note, err = parseMarkdownScroll(formatMarkdownScroll(note)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if note.Content != content {
+			t.Errorf("after three saves %q reads back as %q", content, note.Content)
+		}
+	}
+}
+
+func TestMarkdownSaveRemovesOnlyItsOwnFiles(t *testing.T) {
+	app := newTestApp(t, StorageMarkdown)
+	store := markdownStore{dir: app.NotesDir}
+	plan := filepath.Join(app.NotesDir, "2024-plan.md")
+	if err := ioutil.WriteFile(plan, []byte("my own notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.CreateTextNote("Old title", "kept", nil)
+	app.CreateTextNote("Doomed", "erased", nil)
+	
+	app.Notes[0].Title = "New title"
+	app.SaveNotes()
+	captureOutput(t, func() { app.DeleteNote(2, DeleteOptions{}) })
+	
+	entries, err := ioutil.ReadDir(app.NotesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			names = append(names, entry.Name())
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"1-new-title.md", "2024-plan.md"}) {
+		t.Errorf("markdown files left: %q", names)
+	}
+	if data, _ := ioutil.ReadFile(plan); string(data) != "my own notes\n" {
+		t.Errorf("a file the store never wrote was changed: %q", data)
+	}
+	
+	// A scroll file named by hand gives way to the store's own name.
+	hand := filepath.Join(app.NotesDir, "1-by-hand.md")
+	if err := ioutil.WriteFile(hand, []byte(formatMarkdownScroll(app.Notes[0])), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(app.Notes, app.NextID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(hand); !os.IsNotExist(err) {
+		t.Errorf("the hand-named copy of scroll #1 survived: %v", err)
+	}
+}
+
+func TestShredDestroysMarkdownFileAndMirror(t *testing.T) {
+	app := newTestApp(t, StorageMarkdown)
+	app.CreateTextNote("Secret plans", "the secret text", nil)
+	id := app.Notes[0].ID
+	mirror := filepath.Join(t.TempDir(), "mirror.md")
+	app.SetMirror(id, mirror)
+	
+	files := markdownStore{dir: app.NotesDir}.files(id)
+	if len(files) != 1 {
+		t.Fatalf("store files for #%d = %v, want one", id, files)
+	}
+	// A second link to the store file shows whether its blocks were
+	// overwritten or merely unlinked.
+	link := filepath.Join(t.TempDir(), "link.md")
+	if err := os.Link(files[0], link); err != nil {
+		t.Skipf("cannot hard-link in the temporary directory: %v", err)
+	}
+	
+	app.DeleteNote(id, DeleteOptions{Shred: true})
+	
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("store file still exists: %v", err)
+	}
+	if _, err := os.Stat(mirror); !os.IsNotExist(err) {
+		t.Errorf("mirror still exists: %v", err)
+	}
+	data, err := ioutil.ReadFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("the secret text")) {
+		t.Error("the store file's text survived shredding")
+	}
+}
+
+func TestParseTextImport(t *testing.T) {
+	tests := []struct {
+		text, title, content, created string
+		tags                          []string
+	}{
+		{"My title\n\nsome prose\nDate: whenever I like\nmore", "My title", "some prose\nDate: whenever I like\nmore", "", nil},
+		{"# My title\nTags: a, b\nDate: 2020-01-02\n\nbody\nTags: c", "My title", "body\nTags: c", "2020-01-02", []string{"a", "b"}},
+		{"Created: 2019-05-06\nTitle after\nbody", "Title after", "body", "2019-05-06", nil},
+		{"\n\n", "notes", "", "", nil},
+	}
+	for _, tt := range tests {
+		title, content, tags, created := parseTextImport("/tmp/notes.txt", tt.text)
+		if title != tt.title || content != tt.content || created != tt.created || !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("parseTextImport(%q) = %q, %q, %q, %q; want %q, %q, %q, %q",
+				tt.text, title, content, tags, created, tt.title, tt.content, tt.tags, tt.created)
+		}
+	}
+}
+
+func TestImportKeepsHistoricalDate(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	path := filepath.Join(t.TempDir(), "old.txt")
+	if err := ioutil.WriteFile(path, []byte("Old note\nCreated: 2019-05-06 07:08\n\nbody\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.ImportText(path)
+	if len(app.Notes) != 1 {
+		t.Fatalf("imported %d scrolls, want 1", len(app.Notes))
+	}
+	want := time.Date(2019, 5, 6, 7, 8, 0, 0, time.Local)
+	if note := app.Notes[0]; !note.CreatedAt.Equal(want) || note.Content != "body" {
+		t.Errorf("imported %+v, want created %v and content %q", note, want, "body")
+	}
+}
+
+// openRemote opens archives beside app's for it to sync with.
+func openRemote(t *testing.T, app *NotesApp) *NotesApp {
+	t.Helper()
+	settings := app.Settings
+	settings.NotesDir = t.TempDir()
+	return NewNotesApp(settings, DefaultNotebook)
+}
+
+func TestSyncNewestWinsAndUnion(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	remote := openRemote(t, app)
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	
+	app.Notes = []Note{
+		{ID: 1, Title: "Shared, newer here", Type: "text", CreatedAt: old, UpdatedAt: old.Add(2 * time.Hour)},
+		{ID: 2, Title: "Only here", Type: "text", CreatedAt: old, UpdatedAt: old},
+	}
+	app.NextID = 3
+	app.SaveNotes()
+	remote.Notes = []Note{
+		{ID: 1, Title: "Shared, older there", Type: "text", CreatedAt: old, UpdatedAt: old.Add(time.Hour)},
+		{ID: 3, Title: "Only there", Type: "text", CreatedAt: old, UpdatedAt: old},
+	}
+	remote.NextID = 4
+	remote.SaveNotes()
+	
+	app.Sync(nil, remote.NotesDir)
+	remote.LoadNotes()
+	
+	for _, side := range []*NotesApp{app, remote} {
+		if len(side.Notes) != 3 {
+			t.Fatalf("%s holds %d scrolls, want 3", side.NotesDir, len(side.Notes))
+		}
+		if i := side.noteIndex(1); side.Notes[i].Title != "Shared, newer here" {
+			t.Errorf("%s kept %q for #1, want the newer version", side.NotesDir, side.Notes[i].Title)
+		}
+		if side.noteIndex(2) < 0 || side.noteIndex(3) < 0 {
+			t.Errorf("%s is missing a scroll from the other side", side.NotesDir)
+		}
+		if side.NextID != 4 {
+			t.Errorf("%s has next ID %d, want 4", side.NotesDir, side.NextID)
+		}
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	local := Note{ID: 1, Title: "Local", Type: "text", Content: "a\nb", Tags: []string{"x"}, UpdatedAt: at}
+	other := Note{ID: 1, Title: "Remote", Type: "text", Content: "a\nc", Tags: []string{"y"}, UpdatedAt: at.Add(time.Hour)}
+	
+	tests := []struct {
+		input, title string
+		fromRemote   bool
+	}{
+		{"l\n", "Local", false},
+		{"r\n", "Remote", true},
+		{"\n", "Remote", true},            // Enter keeps the newer
+		{"what\n", "Remote", true},        // an invalid answer and then EOF keeps the newer
+		{"", "Remote", true},              // EOF at once keeps the newer
+		{"nonsense\nl\n", "Local", false}, // asked again after an invalid answer
+	}
+	for _, tt := range tests {
+		kept, fromRemote, _ := app.resolveConflict(bufio.NewReader(strings.NewReader(tt.input)), local, other, "remote")
+		if kept.Title != tt.title || fromRemote != tt.fromRemote {
+			t.Errorf("answer %q kept %q (remote %v), want %q (remote %v)", tt.input, kept.Title, fromRemote, tt.title, tt.fromRemote)
+		}
+	}
+	
+	merged, fromRemote, _ := app.resolveConflict(bufio.NewReader(strings.NewReader("m\n")), local, other, "remote")
+	if merged.Content != "a\nb\nc" || !reflect.DeepEqual(merged.Tags, []string{"x", "y"}) || merged.Title != "Remote" || !fromRemote {
+		t.Errorf("merge gave %+v (remote %v)", merged, fromRemote)
+	}
+}
+
+func TestSyncConflictTakesRemoteImageLocally(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	remote := openRemote(t, app)
+	at := time.Now().Add(-time.Hour)
+	
+	remoteImage := filepath.Join(remote.imagesDir(), "shot.png")
+	if err := ioutil.WriteFile(remoteImage, []byte("remote image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.Notes = []Note{{ID: 1, Title: "Shot", Type: "screenshot", Screenshot: "shot.png",
+		FilePath: filepath.Join(app.imagesDir(), "shot.png"), CreatedAt: at, UpdatedAt: at}}
+	app.NextID = 2
+	app.SaveNotes()
+	remote.Notes = []Note{{ID: 1, Title: "Shot, retitled", Type: "screenshot", Screenshot: "shot.png",
+		FilePath: remoteImage, CreatedAt: at, UpdatedAt: at.Add(time.Minute)}}
+	remote.NextID = 2
+	remote.SaveNotes()
+	
+	app.Sync(bufio.NewReader(strings.NewReader("r\n")), remote.NotesDir)
+	
+	note := app.Notes[app.noteIndex(1)]
+	if note.Title != "Shot, retitled" {
+		t.Errorf("kept %q, want the remote version", note.Title)
+	}
+	if filepath.Dir(note.FilePath) != app.imagesDir() {
+		t.Errorf("local scroll points at %s, outside %s", note.FilePath, app.imagesDir())
+	}
+	if data, err := ioutil.ReadFile(note.FilePath); err != nil || string(data) != "remote image" {
+		t.Errorf("local image = %q, %v; want the remote image", data, err)
+	}
+}
+
+func TestReindexRewritesReferences(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	app.Notes = []Note{
+		{ID: 9, Title: "Second", Type: "text", Content: "see [[#5]]", CreatedAt: at.Add(time.Hour), UpdatedAt: at},
+		{ID: 5, Title: "First", Type: "text", Content: "see [[#9]] and [[#40]]", CreatedAt: at, UpdatedAt: at},
+		{ID: 2, Title: "Third", Type: "text", CreatedAt: at.Add(2 * time.Hour), UpdatedAt: at},
+	}
+	app.NextID = 10
+	app.SaveNotes()
+	
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	if err := ioutil.WriteFile(src, []byte("attached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.AttachFile(9, src)
+	app.AttachFile(2, src)
+	if err := app.trashScrolls(Note{ID: 20, Title: "Trashed", Type: "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.saveTombstones([]Tombstone{{ID: 30, Title: "Erased", DeletedAt: at}}); err != nil {
+		t.Fatal(err)
+	}
+	
+	app.Reindex()
+	
+	want := map[int]string{1: "First", 2: "Second", 3: "Third"}
+	for id, title := range want {
+		i := app.noteIndex(id)
+		if i < 0 || app.Notes[i].Title != title {
+			t.Fatalf("scroll #%d is not %q after reindexing: %+v", id, title, app.Notes)
+		}
+	}
+	if content := app.Notes[app.noteIndex(1)].Content; content != "see [[#2]] and [[#40]]" {
+		t.Errorf("links in #1 = %q", content)
+	}
+	if content := app.Notes[app.noteIndex(2)].Content; content != "see [[#1]]" {
+		t.Errorf("links in #2 = %q", content)
+	}
+	for _, id := range []int{2, 3} {
+		note := app.Notes[app.noteIndex(id)]
+		a := note.Attachments[0]
+		if a.File != filepath.Join("attachments", strconv.Itoa(id), "notes.txt") {
+			t.Errorf("attachment of #%d is at %s", id, a.File)
+		}
+		if data, err := ioutil.ReadFile(app.attachmentPath(a)); err != nil || string(data) != "attached" {
+			t.Errorf("attachment of #%d reads %q, %v", id, data, err)
+		}
+	}
+	if app.NextID != 31 {
+		t.Errorf("next ID = %d, want 31, above the trash and tombstones", app.NextID)
+	}
+}
+
+func TestBlankTitleIsRefused(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("  ", "no title", nil)
+	if len(app.Notes) != 0 {
+		t.Fatalf("a scroll without a title was created: %+v", app.Notes)
+	}
+	if app.ExitCode != ExitInvalid {
+		t.Errorf("exit code = %d, want %d", app.ExitCode, ExitInvalid)
+	}
+}
+
+func TestLoadReportsInvalidScrolls(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	now := time.Now()
+	good := Note{ID: 1, Title: "Good", Type: "text", CreatedAt: now, UpdatedAt: now}
+	untitled := Note{ID: 2, Type: "text", CreatedAt: now, UpdatedAt: now}
+	twin := Note{ID: 1, Title: "Twin", Type: "text", CreatedAt: now, UpdatedAt: now}
+	if err := app.store.Save([]Note{good, untitled, twin}, 3); err != nil {
+		t.Fatal(err)
+	}
+	
+	app.LoadNotes()
+	
+	if len(app.Invalid) != 2 {
+		t.Fatalf("invalid scrolls = %q, want the untitled one and the duplicate", app.Invalid)
+	}
+	if !strings.Contains(app.Invalid[0], "same ID") || !strings.Contains(app.Invalid[1], "title is empty") {
+		t.Errorf("unexpected problems: %q", app.Invalid)
+	}
+}
+
+func TestSetupWizardWritesScriptedAnswers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	answers := strings.Join([]string{
+		filepath.Join(dir, "scrolls"),
+		"nano",
+		"",
+		"3",
+	}, "\n") + "\n"
+	
+	settings := RunSetupWizard(bufio.NewReader(strings.NewReader(answers)), defaultSettings())
+	path := filepath.Join(dir, "settings.json")
+	if err := SaveSettings(path, settings); err != nil {
+		t.Fatal(err)
+	}
+	
+	loaded, found := LoadSettings(path)
+	if !found {
+		t.Fatal("the wizard's settings were not found")
+	}
+	if loaded.NotesDir != filepath.Join(dir, "scrolls") || loaded.Editor != "nano" {
+		t.Errorf("notes dir %q, editor %q", loaded.NotesDir, loaded.Editor)
+	}
+	if loaded.ScreenshotTool != "" {
+		t.Errorf("screenshot tool = %q, want the default", loaded.ScreenshotTool)
+	}
+	if loaded.DateFormat != dateFormatChoices[2] {
+		t.Errorf("date format = %q, want %q", loaded.DateFormat, dateFormatChoices[2])
+	}
+}
+
+func TestContainsTagModes(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	want := map[string]map[string]bool{
+		TagMatchExact:     {"go": true, "golang": false, "ago": false},
+		TagMatchPrefix:    {"go": true, "golang": true, "ago": false},
+		TagMatchSubstring: {"go": true, "golang": true, "ago": true},
+	}
+	for mode, cases := range want {
+		for tag, match := range cases {
+			if got := app.containsTag([]string{tag}, "go", mode); got != match {
+				t.Errorf("%s match of go against rune %q = %v, want %v", mode, tag, got, match)
+			}
+		}
+	}
+	if !app.containsTag([]string{"Go/Tools"}, "go", TagMatchExact) {
+		t.Error("an exact match should take in the rune's descendants, ignoring case")
+	}
+}
+
+func TestFailedSaveKeepsOldArchive(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Kept", "safe and sound", nil)
+	path := app.store.Location()
+	before, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	// A year past 9999 cannot be written as JSON, so the save fails while
+	// marshaling.
+	app.Notes[0].UpdatedAt = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+	app.SaveNotes()
+	
+	if app.ExitCode != ExitIOError {
+		t.Errorf("exit code = %d, want %d", app.ExitCode, ExitIOError)
+	}
+	after, err := ioutil.ReadFile(path)
+	if err != nil || !bytes.Equal(before, after) {
+		t.Errorf("the archive changed on a failed save: %v\n%s", err, after)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTempFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "scrolls.json")
+	
+	// Renaming onto a directory that is not empty fails after the temporary
+	// file has been written.
+	if err := os.MkdirAll(filepath.Join(target, "in-the-way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(target, []byte("new"), 0644); err == nil {
+		t.Fatal("expected the rename to fail")
+	}
+	
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "scrolls.json" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("files left behind: %q", names)
+	}
+	
+	if err := os.RemoveAll(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(target, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(target); string(data) != "new" {
+		t.Errorf("after a good save the file holds %q", data)
+	}
+}
+
+func TestParseQueryOperators(t *testing.T) {
+	scroll := map[string]bool{"dragon": true, "gold": true, "red scales": true}
+	has := func(term string) bool { return scroll[term] }
+	
+	for _, c := range []struct {
+		query string
+		tree  string
+		match bool
+	}{
+		{"dragon AND gold", `("dragon" AND "gold")`, true},
+		{"dragon AND ice", `("dragon" AND "ice")`, false},
+		{"ice OR gold", `("ice" OR "gold")`, true},
+		{"ice OR silver", `("ice" OR "silver")`, false},
+		{"NOT ice", `NOT "ice"`, true},
+		{"NOT dragon", `NOT "dragon"`, false},
+		{"Dragon NOT ice", `("dragon" AND NOT "ice")`, true},
+		{`dragon AND (ice OR "red scales") NOT silver`, `(("dragon" AND ("ice" OR "red scales")) AND NOT "silver")`, true},
+		{"ice OR dragon AND NOT gold", `("ice" OR ("dragon" AND NOT "gold"))`, false},
+		{`"AND" dragon`, `"and dragon"`, false},
+	} {
+		expr, err := parseQuery(c.query)
+		if err != nil {
+			t.Errorf("%s: %v", c.query, err)
+			continue
+		}
+		if got := expr.String(); got != c.tree {
+			t.Errorf("%s parsed as %s, want %s", c.query, got, c.tree)
+		}
+		if got := expr.match(has); got != c.match {
+			t.Errorf("%s matched %v, want %v", c.query, got, c.match)
+		}
+	}
+	
+	for _, query := range []string{"", "dragon AND", "(dragon", "OR gold", "dragon )"} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("%q parsed without error", query)
+		}
+	}
+}
+
+func TestSearchCombinesOperators(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Dragon lore", "the red dragon hoards gold", []string{"beasts"})
+	app.CreateTextNote("Ice dragon", "cold and silent", []string{"beasts"})
+	app.CreateTextNote("Mining", "gold in the hills", []string{"trade"})
+	
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	for query, want := range map[string][]int{
+		"dragon AND gold":              {1},
+		"dragon OR hills":              {1, 2, 3},
+		"NOT beasts":                   {3},
+		"(gold OR cold) AND NOT trade": {1, 2},
+	} {
+		notes, err := app.Search(query, all)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var ids []int
+		for _, note := range notes {
+			ids = append(ids, note.ID)
+		}
+		sort.Ints(ids)
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s found %v, want %v", query, ids, want)
+		}
+	}
+}
+
+// runScripted runs the menu loop on the given input, failing the test if it
+// does not return once the input runs out.
+func runScripted(t *testing.T, app *NotesApp, input string) {
+	t.Helper()
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader(input))
+	defer func() { stdin = saved }()
+	
+	done := make(chan struct{})
+	go func() {
+		app.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the menu loop kept running after its input closed")
+	}
+}
+
+func TestMenuSavesOnEOF(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	titles := func() []string {
+		notes, _, err := app.store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, note := range notes {
+			titles = append(titles, note.Title)
+		}
+		return titles
+	}
+	
+	runScripted(t, app, "1\nScripted\nwritten through the menu\n.\n\n")
+	if got := titles(); !reflect.DeepEqual(got, []string{"Scripted"}) {
+		t.Fatalf("saved titles after inscribing = %q", got)
+	}
+	
+	// A change not yet written is saved when the input closes.
+	app.Notes[0].Title = "Changed"
+	runScripted(t, app, "")
+	if got := titles(); !reflect.DeepEqual(got, []string{"Changed"}) {
+		t.Errorf("saved titles after closing = %q", got)
+	}
+}
+
+func TestMenuEndsOnEOF(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	// The last input has no closing newline, so its command arrives
+	// together with EOF.
+	for _, input := range []string{"", "\n\n", "list\n", "list\nwisdom"} {
+		runScripted(t, app, input)
+	}
+}
+
+func TestListingReadsNoImages(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	src := filepath.Join(t.TempDir(), "shot.png")
+	if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.AddImageFile(src, "Shot", []string{"pictures"})
+	app.CreateTextNote("Words", "plain text", nil)
+	
+	var read []string
+	savedOpen, savedOCR := openForRead, readImageText
+	defer func() { openForRead, readImageText = savedOpen, savedOCR }()
+	openForRead = func(name string) (*os.File, error) {
+		read = append(read, name)
+		return savedOpen(name)
+	}
+	readImageText = func(path string) (string, error) {
+		read = append(read, path)
+		return "", nil
+	}
+	
+	for _, format := range []string{OutputTable, OutputJSON, OutputJSONL, OutputCSV, OutputIDs, OutputOneline} {
+		app.ListNotes(ListOptions{Format: format, WithPreview: true})
+	}
+	if len(read) > 0 {
+		t.Fatalf("listing read %q", read)
+	}
+	
+	app.ExportMarkdown(1, t.TempDir())
+	if len(read) != 1 || read[0] != app.Notes[app.noteIndex(1)].FilePath {
+		t.Errorf("exporting the image scroll read %q, want only its image", read)
+	}
+}
+
+func TestEmptyTrashWantsYes(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	src := filepath.Join(t.TempDir(), "shot.png")
+	if err := ioutil.WriteFile(src, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.AddImageFile(src, "Shot", nil)
+	app.CreateTextNote("Words", "plain text", nil)
+	image := app.Notes[0].FilePath
+	for _, id := range []int{1, 2} {
+		app.DeleteNote(id, DeleteOptions{})
+	}
+	
+	for _, answer := range []string{"y\n", "Y\n", "\n", ""} {
+		out := captureOutput(t, func() {
+			app.EmptyTrash(bufio.NewReader(strings.NewReader(answer)))
+		})
+		if !strings.Contains(out, "The trash holds 2 scrolls and 1 captured images (") {
+			t.Errorf("no summary before asking:\n%s", out)
+		}
+		if trash, err := app.loadTrash(); err != nil || len(trash) != 2 {
+			t.Fatalf("answer %q emptied the trash: %v, %v", answer, trash, err)
+		}
+	}
+	
+	captureOutput(t, func() {
+		app.EmptyTrash(bufio.NewReader(strings.NewReader("yes\n")))
+	})
+	if trash, err := app.loadTrash(); err != nil || len(trash) != 0 {
+		t.Errorf("yes left the trash holding %v, %v", trash, err)
+	}
+	if _, err := os.Stat(image); !os.IsNotExist(err) {
+		t.Errorf("the trashed image survived: %v", err)
+	}
+}
+
+func TestViewingMarksRead(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"One", "Two", "Three"} {
+		app.CreateTextNote(title, "inbox", nil)
+	}
+	read := func(id int) bool { return app.Notes[app.noteIndex(id)].Read }
+	
+	captureOutput(t, func() { app.ViewNote(1) })
+	if !read(1) || read(2) {
+		t.Fatal("view did not mark only the viewed scroll read")
+	}
+	out := captureOutput(t, func() { app.ListNotes(ListOptions{Format: OutputIDs, Unread: true}) })
+	if ids := strings.Fields(out); !reflect.DeepEqual(ids, []string{"3", "2"}) {
+		t.Errorf("unread scrolls = %q, want 3 and 2", ids)
+	}
+	
+	captureOutput(t, func() {
+		app.ViewNote(2)
+		app.MarkRead(1, false)
+		app.MarkRead(2, false)
+		app.ViewLast()
+	})
+	if !read(2) || read(1) {
+		t.Error("view-last did not mark the last scroll read")
+	}
+	captureOutput(t, func() { app.ViewBack() })
+	if !read(1) {
+		t.Error("view-back did not mark the earlier scroll read")
+	}
+}
+
+func TestTrashKeepsScrollsSharingAnID(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	oldImage := filepath.Join(app.imagesDir(), "scroll_capture_old_1.png")
+	if err := ioutil.WriteFile(oldImage, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.trashScrolls(Note{ID: 1, Title: "Old", Type: "screenshot", FilePath: oldImage}); err != nil {
+		t.Fatal(err)
+	}
+	app.CreateTextNote("Live", "erased later", nil)
+	captureOutput(t, func() { app.DeleteNote(1, DeleteOptions{}) })
+	
+	trash, err := app.loadTrash()
+	if err != nil || len(trash) != 2 {
+		t.Fatalf("trash = %+v, %v; want both scrolls erased as #1", trash, err)
+	}
+	if _, err := os.Stat(oldImage); err != nil {
+		t.Errorf("the earlier scroll's image is gone: %v", err)
+	}
+	
+	recover := func(answer string) string {
+		return captureOutput(t, func() {
+			app.RestoreNote(bufio.NewReader(strings.NewReader(answer)), 1)
+		})
+	}
+	if out := recover("3\n"); !strings.Contains(out, "2) Live") || len(app.Notes) != 0 {
+		t.Fatalf("a choice out of range recovered a scroll:\n%s", out)
+	}
+	if app.ExitCode != ExitInvalid {
+		t.Errorf("exit code = %d, want %d", app.ExitCode, ExitInvalid)
+	}
+	
+	recover("2\n")
+	if i := app.noteIndex(1); i < 0 || app.Notes[i].Title != "Live" {
+		t.Fatalf("choosing 2 recovered %+v", app.Notes)
+	}
+	recover("")
+	if i := app.noteIndex(2); i < 0 || app.Notes[i].Title != "Old" {
+		t.Errorf("the last scroll in the trash came back as %+v", app.Notes)
+	}
+}
+
+func TestSearchCacheIsFreshAfterSave(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Dragon", "scales", []string{"zeta", "alpha"})
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	
+	first, err := app.Search("dragon", all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := app.Search("dragon", all)
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("the cached search differs:\n%+v\n%+v", first, again)
+	}
+	
+	// Saving sorts the runes in place, which must reach neither the results
+	// already returned nor the cache.
+	app.Notes[0].Tags = []string{"zeta", "omega", "beta"}
+	app.generation++
+	returned, _ := app.Search("dragon", all)
+	app.Notes[0].Title = "Wyrm"
+	app.SaveNotes()
+	if !reflect.DeepEqual(returned[0].Tags, []string{"zeta", "omega", "beta"}) {
+		t.Errorf("saving changed the runes of a returned result: %q", returned[0].Tags)
+	}
+	
+	if found, _ := app.Search("dragon", all); len(found) != 0 {
+		t.Errorf("a search after saving returned stale results: %+v", found)
+	}
+	found, _ := app.Search("wyrm", all)
+	if len(found) != 1 || !reflect.DeepEqual(found[0].Tags, []string{"beta", "omega", "zeta"}) {
+		t.Errorf("a search after saving found %+v", found)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	dir := b.TempDir()
+	settings := defaultSettings()
+	settings.NotesDir = dir
+	app := NewNotesApp(settings, DefaultNotebook)
+	for i := 0; i < 2000; i++ {
+		app.Notes = append(app.Notes, Note{
+			ID:      i + 1,
+			Title:   fmt.Sprintf("Scroll %d", i),
+			Content: strings.Repeat("ancient lore of dragons and gold ", 20),
+			Tags:    []string{"lore", strconv.Itoa(i % 10)},
+			Type:    "text",
+		})
+	}
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			app.generation++
+			app.Search("dragons AND NOT silver", all)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			app.Search("dragons AND NOT silver", all)
+		}
+	})
+}
+
+func TestAppendTakesPipedTextVerbatim(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Log", "started", nil)
+	piped := "line one\n\n  indented {{date}}\nlast line\n"
+	
+	// Standard input must look like a pipe, whatever runs the tests.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = saved }()
+	
+	captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader(piped)), []string{"append", "1"})
+	})
+	if got := app.Notes[0].Content; got != "started\n"+piped {
+		t.Errorf("content after append = %q", got)
+	}
+	
+	// Within the menu only one line is taken, leaving the rest as commands.
+	app.menu = true
+	defer func() { app.menu = false }()
+	reader := bufio.NewReader(strings.NewReader("more\nlist\n"))
+	captureOutput(t, func() { app.Execute(reader, []string{"append", "1"}) })
+	if rest, _ := reader.ReadString('\n'); rest != "list\n" {
+		t.Errorf("append in the menu consumed the next command; %q is left", rest)
+	}
+	if got := app.Notes[0].Content; got != "started\n"+piped+"\nmore" {
+		t.Errorf("content after appending in the menu = %q", got)
+	}
+}
+
+func TestEraseReadsPipedIDs(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"One", "Two", "Three", "Four"} {
+		app.CreateTextNote(title, "batch", nil)
+	}
+	erase := func(input string) {
+		captureOutput(t, func() {
+			app.Execute(bufio.NewReader(strings.NewReader(input)), []string{"erase", "--stdin"})
+		})
+	}
+	titles := func() []string {
+		var titles []string
+		for _, note := range app.Notes {
+			titles = append(titles, note.Title)
+		}
+		sort.Strings(titles)
+		return titles
+	}
+	
+	erase("3\n\n  1 \n\n")
+	if got := titles(); !reflect.DeepEqual(got, []string{"Four", "Two"}) {
+		t.Fatalf("left after erasing 3 and 1: %q", got)
+	}
+	if trash, _ := app.loadTrash(); len(trash) != 2 {
+		t.Errorf("trash holds %d scrolls, want 2", len(trash))
+	}
+	
+	erase("2\nfour\n")
+	if got := titles(); !reflect.DeepEqual(got, []string{"Four", "Two"}) {
+		t.Errorf("a list holding junk still erased scrolls: %q", got)
+	}
+	if app.ExitCode != ExitInvalid {
+		t.Errorf("exit code = %d, want %d", app.ExitCode, ExitInvalid)
+	}
+	
+	// Within the menu the input holds the commands still to come.
+	app.menu = true
+	defer func() { app.menu = false }()
+	reader := bufio.NewReader(strings.NewReader("2\nlist\n"))
+	captureOutput(t, func() { app.Execute(reader, []string{"erase", "--stdin"}) })
+	if rest, _ := ioutil.ReadAll(reader); string(rest) != "2\nlist\n" || len(app.Notes) != 2 {
+		t.Errorf("erase --stdin in the menu read %q away", strings.TrimSuffix("2\nlist\n", string(rest)))
+	}
+}
+
+func TestMissingIDsSuggestNearest(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"One", "Two", "Three", "Four", "Five"} {
+		app.CreateTextNote(title, "", nil)
+	}
+	captureOutput(t, func() { app.DeleteNotes([]int{3}, true) })
+	
+	for name, run := range map[string]func(){
+		"move":    func() { app.MoveNote(3, "other") },
+		"touch":   func() { app.TouchNote(3) },
+		"update":  func() { app.UpdateNote(3, noteUpdate{}) },
+		"retitle": func() { app.RetitleScroll(3) },
+		"retag":   func() { app.RetagScroll(3) },
+		"erase":   func() { app.DeleteNotes([]int{3}, false) },
+		"diff":    func() { app.DiffScrolls(1, 3) },
+	} {
+		app.ExitCode = ExitOK
+		var stderr string
+		captureOutput(t, func() { stderr = captureFile(t, &os.Stderr, run) })
+		if app.ExitCode != ExitNotFound {
+			t.Errorf("%s: exit code = %d, want %d", name, app.ExitCode, ExitNotFound)
+		}
+		if !strings.Contains(stderr, "Did you mean: #1 One, #2 Two, #4 Four?") {
+			t.Errorf("%s: no suggestions in %q", name, stderr)
+		}
+	}
+}
+
+func TestListTitleWidth(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	long := strings.Repeat("a long and winding title ", 4)
+	
+	for _, c := range []struct {
+		file  string
+		width int
+	}{
+		{`{"editor": "vi"}`, 40},
+		{`{"list_title_width": 0}`, 0},
+		{`{"list_title_width": 12}`, 12},
+	} {
+		if err := ioutil.WriteFile(path, []byte(c.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		settings, _ := LoadSettings(path)
+		if settings.ListTitleWidth != c.width {
+			t.Errorf("%s: width %d, want %d", c.file, settings.ListTitleWidth, c.width)
+		}
+		
+		app := &NotesApp{Settings: settings}
+		title := app.decoratedTitle(Note{Title: long})
+		if c.width == 0 && title != long {
+			t.Errorf("width 0 cut the title to %q", title)
+		}
+		if c.width > 0 && title != long[:c.width]+"..." {
+			t.Errorf("width %d gave %q", c.width, title)
+		}
+	}
+	
+	// An explicit 0 survives being saved and loaded again.
+	settings := defaultSettings()
+	settings.ListTitleWidth = 0
+	if err := SaveSettings(path, settings); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := LoadSettings(path); loaded.ListTitleWidth != 0 {
+		t.Errorf("a saved width of 0 loads as %d", loaded.ListTitleWidth)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		data     []byte
+		text     string
+		encoding string
+	}{
+		{"utf-8", []byte("café"), "café", "UTF-8"},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFcafé"), "café", "UTF-8"},
+		{"utf-16le", []byte{0xFF, 0xFE, 'c', 0, 'a', 0, 'f', 0, 0xE9, 0, 0x3D, 0xD8, 0x1C, 0xDF}, "café🜜", "UTF-16LE"},
+		{"utf-16be", []byte{0xFE, 0xFF, 0, 'c', 0, 'a', 0, 'f', 0, 0xE9, 0xD8, 0x3D, 0xDF, 0x1C}, "café🜜", "UTF-16BE"},
+		{"latin-1", []byte("caf\xE9 na\xEFve \xA9"), "café naïve ©", "Windows-1252"},
+		{"windows-1252", []byte("\x93quoted\x94 \x80 5"), "“quoted” € 5", "Windows-1252"},
+	} {
+		text, encoding := decodeText(c.data)
+		if text != c.text || encoding != c.encoding {
+			t.Errorf("%s: got %q as %s, want %q as %s", c.name, text, encoding, c.text, c.encoding)
+		}
+	}
+}
+
+func TestImportLatin1Text(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	path := filepath.Join(t.TempDir(), "recette.txt")
+	if err := ioutil.WriteFile(path, []byte("Cr\xE8me br\xFBl\xE9e\n\nSucre et cr\xE8me.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	
+	captureOutput(t, func() { app.ImportText(path) })
+	
+	if len(app.Notes) != 1 {
+		t.Fatalf("imported %d scrolls", len(app.Notes))
+	}
+	note := app.Notes[0]
+	if note.Title != "Crème brûlée" || !strings.Contains(note.Content, "Sucre et crème.") {
+		t.Errorf("imported %q with %q", note.Title, note.Content)
+	}
+}
+
+func TestSearchFoldsCaseAcrossScripts(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"Straße", "istanbul", "κοσμος", "ſtar", "file", "10 k"} {
+		app.CreateTextNote(title, "", nil)
+	}
+	titles := SearchOptions{Scope: SearchScope{Title: true}}
+	for query, want := range map[string]string{
+		"STRASSE":   "Straße",
+		"İSTANBUL":  "istanbul",
+		"ISTANBUL":  "istanbul",
+		"ΚΟΣΜΟΣ":    "κοσμος",
+		"κοσμοσ":    "κοσμος",
+		"STAR":      "ſtar",
+		"ﬁle":       "file",
+		"10 \u212A": "10 k", // the Kelvin sign
+	} {
+		found, err := app.Search(query, titles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].Title != want {
+			t.Errorf("%s found %+v, want %q", query, found, want)
+		}
+	}
+	
+	if foldCase("ẞ ſ ς \u212A") != foldCase("ss s σ k") {
+		t.Errorf("%q and %q fold apart", foldCase("ẞ ſ ς \u212A"), foldCase("ss s σ k"))
+	}
+}
+
+func TestOpenCommandRefusesUnknownPlatforms(t *testing.T) {
+	for goos, name := range map[string]string{"darwin": "open", "linux": "xdg-open", "windows": "cmd"} {
+		cmd := openCommand(goos, "scroll.png")
+		if cmd == nil || cmd.Args[0] != name {
+			t.Errorf("openCommand(%q) = %v, want %s", goos, cmd, name)
+		}
+	}
+	if cmd := openCommand("plan9", "scroll.png"); cmd != nil {
+		t.Errorf("openCommand(plan9) = %v, want nil", cmd.Args)
+	}
+}
+
+func TestViewResultFollowsLastSearch(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Apple pie", "bake it", nil)
+	app.CreateTextNote("Banana", "peel it", nil)
+	app.CreateTextNote("Apple tart", "bake it too", nil)
+	
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	out := captureOutput(t, func() { app.SearchNotes("apple", all, ListOptions{Format: OutputTable}) })
+	for n, id := range app.LastResults {
+		if got, ok := app.ResultID(n + 1); !ok || got != id {
+			t.Errorf("ResultID(%d) = %d, %v, want %d", n+1, got, ok, id)
+		}
+		if line := fmt.Sprintf("\n%d) [%d] ", n+1, id); !strings.Contains(out, line) {
+			t.Errorf("result %d is not shown as %q:\n%s", n+1, line, out)
+		}
+	}
+	if len(app.LastResults) != 2 {
+		t.Fatalf("the search found %v, want two scrolls", app.LastResults)
+	}
+	for _, n := range []int{0, 3} {
+		if _, ok := app.ResultID(n); ok {
+			t.Errorf("ResultID(%d) found a scroll", n)
+		}
+	}
+	
+	second := app.LastResults[1]
+	out = captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"view-result", "2"})
+	})
+	if title := app.Notes[app.noteIndex(second)].Title; !strings.Contains(out, title) {
+		t.Errorf("view-result 2 did not reveal %q:\n%s", title, out)
+	}
+}
+
+func TestRenderFormatsParse(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Plain", "one line", []string{"a"})
+	app.CreateTextNote("Tricky, \"quoted\"", "two\nlines, with commas", []string{"b", "c"})
+	notes := app.Notes
+	
+	out, err := app.render(notes, OutputJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Note
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil || len(decoded) != 2 || decoded[1].Title != notes[1].Title {
+		t.Errorf("json gave %v, %v", decoded, err)
+	}
+	
+	out, err = app.render(notes, OutputJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("jsonl gave %d lines:\n%s", len(lines), out)
+	}
+	for i, line := range lines {
+		var note Note
+		if err := json.Unmarshal([]byte(line), &note); err != nil || note.Content != notes[i].Content {
+			t.Errorf("jsonl line %d gave %+v, %v", i+1, note, err)
+		}
+	}
+	
+	out, err = app.render(notes, OutputCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("csv gave %q, %v", records, err)
+	}
+	if got := records[2]; got[1] != notes[1].Title || got[3] != "b;c" || got[6] != notes[1].Content {
+		t.Errorf("csv row = %q", got)
+	}
+	
+	if out, err = app.render(nil, OutputJSON); err != nil || strings.TrimSpace(out) != "[]" {
+		t.Errorf("json of no scrolls = %q, %v", out, err)
+	}
+	if _, err := app.render(notes, "xml"); err == nil {
+		t.Error("an unknown format was accepted")
+	}
+	
+	out = captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"list", "--output", "jsonl"})
+	})
+	if n := strings.Count(out, "\n"); n != 2 || !strings.HasPrefix(out, "{") {
+		t.Errorf("list --output jsonl printed:\n%s", out)
+	}
+}
+
+func TestRenameTagsFromFile(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("One", "x", []string{"golang", "todo"})
+	app.CreateTextNote("Two", "x", []string{"js", "b"})
+	app.CreateTextNote("Three", "x", []string{"misc"})
+	
+	mapping := filepath.Join(t.TempDir(), "mapping.txt")
+	data := "golang=go\n# a comment\njs = javascript\nbroken line\ntodo=b\n\nb=c\n"
+	if err := ioutil.WriteFile(mapping, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"rename-tag", "--from-file", mapping})
+	})
+	if !strings.Contains(out, `Skipping line 4: expected old=new, got "broken line"`) {
+		t.Errorf("the malformed line was not reported:\n%s", out)
+	}
+	if !strings.Contains(out, "Renamed runes on 2 scrolls.") {
+		t.Errorf("no summary:\n%s", out)
+	}
+	
+	notes, _, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"One": {"b", "go"}, "Two": {"c", "javascript"}, "Three": {"misc"}}
+	for _, note := range notes {
+		if !reflect.DeepEqual(note.Tags, want[note.Title]) {
+			t.Errorf("%s was saved with runes %q, want %q", note.Title, note.Tags, want[note.Title])
+		}
+	}
+}
+
+func TestDiffLinesMarksChanges(t *testing.T) {
+	a := "keep\nold line\nshared\ngone"
+	b := "keep\nnew line\nshared\nadded"
+	want := []string{" keep", "-old line", "+new line", " shared", "-gone", "+added"}
+	if got := diffLines(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines = %q, want %q", got, want)
+	}
+	if got := diffLines("same", "same"); !reflect.DeepEqual(got, []string{" same"}) {
+		t.Errorf("diff of equal texts = %q", got)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Draft", a, nil)
+	app.CreateTextNote("Final", b, nil)
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"diff", "1", "2"})
+	})
+	wantOut := "--- #1 Draft\n+++ #2 Final\n" + strings.Join(want, "\n") + "\n"
+	if out != wantOut {
+		t.Errorf("diff 1 2 printed:\n%s\nwant:\n%s", out, wantOut)
+	}
+}
+
+func TestRecentViewsPersistAndStepBack(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"One", "Two", "Three"} {
+		app.CreateTextNote(title, "the scroll called "+title, nil)
+	}
+	captureOutput(t, func() {
+		for _, id := range []int{1, 2, 3, 2} {
+			app.ViewNote(id)
+		}
+	})
+	if want := []int{2, 3, 1}; !reflect.DeepEqual(app.Recent, want) {
+		t.Fatalf("recent views = %v, want %v", app.Recent, want)
+	}
+	
+	// A fresh session picks up the history where the last one left off.
+	app = NewNotesApp(app.Settings, DefaultNotebook)
+	none := bufio.NewReader(strings.NewReader(""))
+	for _, step := range []struct{ command, want string }{
+		{"last", "the scroll called Two"},
+		{"back", "the scroll called Three"},
+		{"back", "the scroll called One"},
+		{"back", "No earlier scrolls in your viewing history."},
+		{"last", "the scroll called Two"},
+	} {
+		out := captureOutput(t, func() { app.Execute(none, []string{step.command}) })
+		if !strings.Contains(out, step.want) {
+			t.Errorf("%s printed:\n%s\nwant %q", step.command, out, step.want)
+		}
+	}
+}
+
+func TestSlugifyMakesSafeNames(t *testing.T) {
+	for title, want := range map[string]string{
+		"Plans for 2026":       "plans-for-2026",
+		"../../etc/passwd":     "etc-passwd",
+		"a/b\\c:d":             "a-b-c-d",
+		"...":                  "scroll",
+		"":                     "scroll",
+		"bell\x07and\ttab":     "bell-and-tab",
+		"Café Ünïcode 東京":      "café-ünïcode-東京",
+		"snake_case.v2 -- end": "snake_case-v2-end",
+	} {
+		if got := slugify(title); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", title, got, want)
+		}
+	}
+	if got := slugify(strings.Repeat("é", 100)); len([]rune(got)) != 80 {
+		t.Errorf("a long title gave a %d-rune slug", len([]rune(got)))
+	}
+	
+	dir := t.TempDir()
+	for _, name := range []string{"../outside.md", "..", ".", "a/../../b", "/"} {
+		if path, err := safeJoin(dir, name); err == nil {
+			t.Errorf("safeJoin(%q) = %q, want an error", name, path)
+		}
+	}
+	if path, err := safeJoin(dir, "sub/scroll.md"); err != nil || path != filepath.Join(dir, "sub", "scroll.md") {
+		t.Errorf("safeJoin of a plain name = %q, %v", path, err)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("../../escape: now", "out", nil)
+	captureOutput(t, func() { app.ExportMarkdown(1, dir) })
+	if _, err := os.Stat(filepath.Join(dir, "1-escape-now.md")); err != nil {
+		t.Errorf("the export did not land in its folder: %v", err)
+	}
+}
+
+func TestStatsForATag(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	notes := []Note{
+		{ID: 1, Title: "a", Type: "text", Content: "four", Tags: []string{"work"}, CreatedAt: day(5), UpdatedAt: day(20)},
+		{ID: 2, Title: "b", Type: "text", Content: "eight ch", Tags: []string{"work", "x"}, CreatedAt: day(2), UpdatedAt: day(3)},
+		{ID: 3, Title: "c", Type: "screenshot", Tags: []string{"work"}, CreatedAt: day(9), UpdatedAt: day(9)},
+		{ID: 4, Title: "d", Type: "text", Content: "not counted at all", Tags: []string{"workshop"}, CreatedAt: day(1), UpdatedAt: day(28)},
+	}
+	want := scrollStats{Count: 3, Text: 2, Screenshots: 1, FirstCreated: day(2), LastCreated: day(9), LastUpdated: day(20), AvgLength: 6}
+	if got := computeStats(notes[:3]); got != want {
+		t.Errorf("computeStats = %+v, want %+v", got, want)
+	}
+	if got := computeStats(nil); got != (scrollStats{}) {
+		t.Errorf("computeStats(nil) = %+v", got)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.Settings.DateFormat = "2006-01-02"
+	app.Notes = notes
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"stats", "--tag", "work"})
+	})
+	for _, line := range []string{
+		"=== Measures of the Rune 'work' ===",
+		"Scrolls: 3 (2 text, 1 captured images)",
+		"First inscribed: 2026-03-02",
+		"Last inscribed: 2026-03-09",
+		"Last updated: 2026-03-20",
+		"Average length: 6.0 characters",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("stats --tag work lacks %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestNotebooksKeepTheirScrollsApart(t *testing.T) {
+	home := newTestApp(t, StorageJSON)
+	work := NewNotesApp(home.Settings, "work")
+	home.CreateTextNote("Groceries", "milk", nil)
+	work.CreateTextNote("Standup", "status", nil)
+	
+	for name, want := range map[string]string{DefaultNotebook: "Groceries", "work": "Standup"} {
+		notes, _, err := NewNotesApp(home.Settings, name).store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 1 || notes[0].Title != want {
+			t.Errorf("the %s notebook holds %+v, want only %q", name, notes, want)
+		}
+	}
+	
+	out := captureOutput(t, func() { home.ListNotebooks() })
+	for _, line := range []string{"* default (1 scrolls)", "  work (1 scrolls)"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("notebooks lacks %q:\n%s", line, out)
+		}
+	}
+	if err := validNotebookName("../escape"); err == nil {
+		t.Error("a notebook name with a path was accepted")
+	}
+}
+
+func TestMoveNoteToAnotherNotebook(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	src := filepath.Join(t.TempDir(), "shot.png")
+	if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\nimage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.CreateTextNote("Stays", "here", nil)
+	app.CreateTextNote("Travels", "line one\nline two", []string{"trip"})
+	app.AddImageFile(src, "Picture", nil)
+	work := NewNotesApp(app.Settings, "work")
+	work.CreateTextNote("Already there", "x", nil)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() {
+		app.Execute(none, []string{"move", "2", "--to", "work"})
+		app.Execute(none, []string{"move", "3", "--to", "work"})
+	})
+	if len(app.Notes) != 1 || app.Notes[0].Title != "Stays" {
+		t.Errorf("the source still holds %+v", app.Notes)
+	}
+	
+	work = NewNotesApp(app.Settings, "work")
+	if len(work.Notes) != 3 {
+		t.Fatalf("the work notebook holds %+v", work.Notes)
+	}
+	moved := work.Notes[1]
+	if moved.ID != 2 || moved.Title != "Travels" || moved.Content != "line one\nline two" || !reflect.DeepEqual(moved.Tags, []string{"trip"}) {
+		t.Errorf("the moved scroll arrived as %+v", moved)
+	}
+	image := work.Notes[2]
+	if image.ID != 3 || filepath.Dir(image.FilePath) != work.imagesDir() {
+		t.Errorf("the moved image scroll arrived as %+v", image)
+	}
+	if data, err := ioutil.ReadFile(image.FilePath); err != nil || string(data) != "\x89PNG\r\n\x1a\nimage" {
+		t.Errorf("the moved image reads %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(app.imagesDir(), image.Screenshot)); !os.IsNotExist(err) {
+		t.Errorf("the image stayed behind: %v", err)
+	}
+}
+
+func TestWordGoalProgress(t *testing.T) {
+	zone := time.FixedZone("east", 10*60*60)
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, zone)
+	notes := []Note{
+		{Type: "text", Content: "three words here", CreatedAt: now.Add(-time.Hour)},
+		// Still the 10th in the reader's zone, though the 9th in UTC.
+		{Type: "text", Content: "two more", CreatedAt: time.Date(2026, 5, 9, 15, 30, 0, 0, time.UTC)},
+		{Type: "text", Content: "yesterday does not count", CreatedAt: now.Add(-24 * time.Hour)},
+		{Type: "screenshot", Content: "nor do images", CreatedAt: now},
+	}
+	if got := wordsWrittenOn(notes, now); got != 5 {
+		t.Errorf("words written today = %d, want 5", got)
+	}
+	
+	for _, c := range []struct {
+		done, goal int
+		want       string
+	}{
+		{5, 20, "[#####---------------] 25%"},
+		{0, 20, "[--------------------] 0%"},
+		{20, 20, "[####################] 100%"},
+		{30, 20, "[####################] 150%"},
+		{5, 0, ""},
+	} {
+		if got := progressBar(c.done, c.goal, 20); got != c.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", c.done, c.goal, got, c.want)
+		}
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.Notes = notes
+	app.Settings.DailyWordGoal = 20
+	out := captureOutput(t, func() { app.showWordGoal(now) })
+	if want := "Words written today: 5 / 20 [#####---------------] 25%\n"; out != want {
+		t.Errorf("showWordGoal printed %q, want %q", out, want)
+	}
+}
+
+// TestMainProcess runs main when the test binary is started again by
+// runMain; otherwise it does nothing.
+func TestMainProcess(t *testing.T) {
+	if os.Getenv("SCROLLS_TEST_MAIN") != "1" {
+		return
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	os.Args = append([]string{"scrolls"}, args...)
+	main()
+	os.Exit(ExitOK)
+}
+
+// mainCommand prepares the program to run with args as a separate process
+// whose home is home.
+func mainCommand(home string, args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestMainProcess$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "SCROLLS_TEST_MAIN=1", "HOME="+home)
+	return cmd
+}
+
+// runMain runs the program with args as a separate process whose home is
+// home and whose input is input, returning its standard output, standard
+// error and exit code.
+func runMain(t *testing.T, home, input string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := mainCommand(home, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	code := 0
+	if exit, ok := err.(*exec.ExitError); ok {
+		code = exit.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+func TestExitCodes(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, "archives")
+	if _, _, code := runMain(t, home, "Kept\nx\n.\n\n", "--notes-dir", dir, "inscribe"); code != ExitOK {
+		t.Fatalf("inscribing exited with %d", code)
+	}
+	
+	for _, c := range []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"view", "1"}, ExitOK, ""},
+		{[]string{"view", "9"}, ExitNotFound, "Scroll with ID 9 not found in the archives.\n"},
+		{[]string{"view", "nine"}, ExitInvalid, "Invalid scroll ID"},
+		{[]string{"--output", "xml", "list"}, ExitInvalid, "Unknown output format: xml"},
+	} {
+		stdout, stderr, code := runMain(t, home, "", append([]string{"--notes-dir", dir}, c.args...)...)
+		if code != c.code || !strings.Contains(stderr, c.stderr) || c.stderr == "" && stderr != "" {
+			t.Errorf("%q exited with %d and wrote %q to stderr, want %d and %q", c.args, code, stderr, c.code, c.stderr)
+		}
+		if c.code != ExitOK && strings.Contains(stdout, c.stderr) {
+			t.Errorf("%q printed its error on stdout: %q", c.args, stdout)
+		}
+	}
+}
+
+// fakeCommand puts an executable shell script called name first on PATH.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPasteClipboardImage(t *testing.T) {
+	for goos, want := range map[string]string{"darwin": "pngpaste", "linux": "wl-paste", "windows": "powershell"} {
+		cmd, toStdout := clipboardImageCommand(goos, "shot.png")
+		if cmd == nil || cmd.Args[0] != want || toStdout != (goos == "linux") {
+			t.Errorf("clipboardImageCommand(%q) = %v, %v, want %s", goos, cmd, toStdout, want)
+		}
+	}
+	if cmd, _ := clipboardImageCommand("plan9", "shot.png"); cmd != nil {
+		t.Errorf("plan9 got a paste command %q", cmd.Args)
+	}
+	if err := pasteClipboardImage("plan9", "shot.png"); err == nil || err == errNoClipboardImage {
+		t.Errorf("pasting on plan9 gave %v", err)
+	}
+	
+	path := filepath.Join(t.TempDir(), "shot.png")
+	fakeCommand(t, "wl-paste", "printf 'just some text'")
+	if err := pasteClipboardImage("linux", path); err != errNoClipboardImage {
+		t.Errorf("a clipboard holding text gave %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("a file was left behind: %v", err)
+	}
+	
+	if runtime.GOOS == "linux" {
+		app := newTestApp(t, StorageJSON)
+		stderr := captureFile(t, &os.Stderr, func() { app.PasteImage("Nothing", nil) })
+		if app.ExitCode != ExitInvalid || !strings.Contains(stderr, "the clipboard holds no image") || len(app.Notes) != 0 {
+			t.Errorf("pasting no image exited %d with %q and %d scrolls", app.ExitCode, stderr, len(app.Notes))
+		}
+	}
+	
+	fakeCommand(t, "wl-paste", `printf '\211PNG\r\n\032\nimage'`)
+	if err := pasteClipboardImage("linux", path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "\x89PNG\r\n\x1a\nimage" {
+		t.Errorf("the pasted image reads %q", data)
+	}
+}
+
+func TestUpdateChangesOnlyGivenFields(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Original", "first", []string{"keep"})
+	original := app.Notes[0]
+	file := filepath.Join(t.TempDir(), "content.txt")
+	if err := ioutil.WriteFile(file, []byte("from a file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	update := func(args ...string) Note {
+		t.Helper()
+		before := app.Notes[0].UpdatedAt
+		time.Sleep(time.Millisecond)
+		captureOutput(t, func() { app.Execute(none, append([]string{"update", "1"}, args...)) })
+		notes, _, err := app.store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !notes[0].UpdatedAt.After(before) {
+			t.Errorf("update %q left UpdatedAt at %v", args, notes[0].UpdatedAt)
+		}
+		return notes[0]
+	}
+	
+	note := update("--title", "Renamed")
+	if note.Title != "Renamed" || note.Content != "first" || !reflect.DeepEqual(note.Tags, []string{"keep"}) {
+		t.Errorf("--title alone gave %+v", note)
+	}
+	note = update("--content", "second", "--append")
+	if note.Title != "Renamed" || note.Content != "first\nsecond" || !reflect.DeepEqual(note.Tags, []string{"keep"}) {
+		t.Errorf("--content --append gave %+v", note)
+	}
+	note = update("--tags", "b, a")
+	if note.Content != "first\nsecond" || !reflect.DeepEqual(note.Tags, []string{"a", "b"}) {
+		t.Errorf("--tags gave %+v", note)
+	}
+	note = update("--content-file", file, "--tags", "")
+	if note.Title != "Renamed" || note.Content != "from a file" || len(note.Tags) != 0 {
+		t.Errorf("--content-file with empty --tags gave %+v", note)
+	}
+	if !note.CreatedAt.Equal(original.CreatedAt) || note.ID != original.ID {
+		t.Errorf("update changed the scroll's identity: %+v", note)
+	}
+	
+	stderr := captureFile(t, &os.Stderr, func() { app.Execute(none, []string{"update", "1"}) })
+	if !strings.Contains(stderr, "Nothing to update") || app.ExitCode != ExitInvalid {
+		t.Errorf("an update without fields wrote %q and exited %d", stderr, app.ExitCode)
+	}
+}
+
+func TestScreenshotToolIsDetectedOnce(t *testing.T) {
+	candidates := platformScreenshotTools()
+	if len(candidates) == 0 {
+		t.Skip("no capture tools are known on this platform")
+	}
+	found := candidates[len(candidates)-1]
+	installed := map[string]bool{found: true}
+	looked := make(map[string]int)
+	saved := lookPath
+	defer func() { lookPath = saved }()
+	lookPath = func(name string) (string, error) {
+		looked[name]++
+		if !installed[name] {
+			return "", fmt.Errorf("%s: not found", name)
+		}
+		return "/usr/bin/" + name, nil
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	for _, tool := range candidates {
+		if looked[tool] != 1 {
+			t.Fatalf("opening the archives looked for %s %d times", tool, looked[tool])
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if tool := app.currentScreenshotTool(); tool != found {
+			t.Fatalf("the cached tool is %q, want %q", tool, found)
+		}
+	}
+	for _, tool := range candidates[:len(candidates)-1] {
+		if looked[tool] != 1 {
+			t.Errorf("later captures looked for %s again", tool)
+		}
+	}
+	out := captureOutput(t, func() { app.ShowWhere() })
+	if !strings.Contains(out, "Screenshot tool: "+found) {
+		t.Errorf("where does not report %s:\n%s", found, out)
+	}
+	
+	// A tool that disappears is looked for again, and so are the others.
+	installed[found] = false
+	before := looked[candidates[0]]
+	if tool := app.currentScreenshotTool(); tool != "" {
+		t.Errorf("a vanished tool is still used: %q", tool)
+	}
+	if looked[candidates[0]] == before {
+		t.Errorf("the tools were not detected again once %s vanished", found)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	for input, want := range map[string][]string{
+		"go, , Go, rust,":  {"go", "rust"},
+		"":                 nil,
+		" , ,":             nil,
+		"Work, urgent":     {"urgent", "Work"},
+		"b,a,B,A,c":        {"a", "b", "c"},
+		"  spaced  , out ": {"out", "spaced"},
+	} {
+		if got := parseTags(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseTags(%q) = %q, want %q", input, got, want)
+		}
+	}
+	
+	// Runes typed at the retag prompt go through the same parsing.
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Scroll", "x", parseTags("go, , Go, rust,"))
+	if got := app.Notes[0].Tags; !reflect.DeepEqual(got, []string{"go", "rust"}) {
+		t.Errorf("the scroll was inscribed with runes %q", got)
+	}
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader("rust, Rust ,, zig,\n"))
+	defer func() { stdin = saved }()
+	captureOutput(t, func() { app.Execute(stdin, []string{"retag", "1"}) })
+	if got := app.Notes[0].Tags; !reflect.DeepEqual(got, []string{"rust", "zig"}) {
+		t.Errorf("retagging gave runes %q, want [rust zig]", got)
+	}
+}
+
+func TestVersionPrintsBuildInfo(t *testing.T) {
+	savedVersion, savedCommit, savedDate := version, commit, buildDate
+	defer func() { version, commit, buildDate = savedVersion, savedCommit, savedDate }()
+	version, commit, buildDate = "1.2.0", "abc123", "2024-01-01"
+	
+	app := newTestApp(t, StorageJSON)
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"version"})
+	})
+	want := "The Ancient Scrolls 1.2.0 (commit abc123, built 2024-01-01, " + runtime.Version() + ")\n"
+	if out != want {
+		t.Errorf("version printed %q, want %q", out, want)
+	}
+	
+	// Without -ldflags the flag reports a development build.
+	stdout, _, code := runMain(t, t.TempDir(), "", "--version")
+	if code != ExitOK || !strings.HasPrefix(stdout, "The Ancient Scrolls dev (commit unknown, built unknown, ") {
+		t.Errorf("--version exited %d, printing %q", code, stdout)
+	}
+}
+
+func TestRecaptureNoticesIdenticalImage(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "shot.png")
+	if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\nsame region"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The fake tool captures the same bytes every time, into the path
+	// that follows -f.
+	fakeCommand(t, "gnome-screenshot", `while [ "$1" != "-f" ]; do shift; done; cp "`+src+`" "$2"`)
+	
+	app := newTestApp(t, StorageJSON)
+	app.Settings.ScreenshotTool = "gnome-screenshot"
+	app.AddImageFile(src, "Region", nil)
+	old := app.Notes[0]
+	
+	saved := stdin
+	defer func() { stdin = saved }()
+	stdin = bufio.NewReader(strings.NewReader("n\ny\n"))
+	out := captureOutput(t, func() { app.RecaptureImage(1) })
+	if !strings.Contains(out, "The new capture is identical to the old image.") {
+		t.Fatalf("the duplicate went unnoticed:\n%s", out)
+	}
+	if app.Notes[0].FilePath != old.FilePath {
+		t.Errorf("the scroll moved to %s", app.Notes[0].FilePath)
+	}
+	files, _ := ioutil.ReadDir(app.imagesDir())
+	if len(files) != 1 {
+		t.Errorf("the images folder holds %d files, want only the old image", len(files))
+	}
+	
+	// Declining keeps the new capture instead.
+	stdin = bufio.NewReader(strings.NewReader("n\nn\n"))
+	captureOutput(t, func() { app.RecaptureImage(1) })
+	if app.Notes[0].FilePath == old.FilePath {
+		t.Error("declining kept the old image")
+	}
+}
+
+func TestPreviewStripsMarkdown(t *testing.T) {
+	content := "# Heading\n\n**Bold** start,  `code` and ~~gone~~ words\n> quoted\n- [x] done task\n1. numbered\n\n\t  trailing   space  "
+	want := "Heading Bold start, code and gone words quoted done task numbered trailing space"
+	if got := normalizePreview(content); got != want {
+		t.Errorf("normalizePreview = %q, want %q", got, want)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	if got := app.preview(content, 20); got != "Heading Bold start, ..." {
+		t.Errorf("preview = %q", got)
+	}
+	app.Settings.RawPreviews = true
+	if got := app.preview("# Raw\r\n**kept**", 100); got != "# Raw **kept**" {
+		t.Errorf("raw preview = %q", got)
+	}
+	
+	for _, c := range []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"héllo wörld", 5, "héllo..."},
+		{"東京都庁", 2, "東京..."},
+		{"", 3, ""},
+	} {
+		if got := truncateRunes(c.text, c.limit); got != c.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", c.text, c.limit, got, c.want)
+		}
+	}
+	
+	// Only the preview changes; the stored content is left as written.
+	app.Settings.RawPreviews = false
+	app.CreateTextNote("Marked", content, nil)
+	out := captureOutput(t, func() { app.ListNotes(ListOptions{Format: OutputTable, WithPreview: true}) })
+	if strings.Contains(out, "**") || strings.Contains(out, "# Heading") {
+		t.Errorf("the listing shows raw markdown:\n%s", out)
+	}
+	if app.Notes[0].Content != content {
+		t.Errorf("the stored content changed to %q", app.Notes[0].Content)
+	}
+}
+
+func TestTouchBumpsOnlyUpdatedAt(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Old", "unchanged words", []string{"x"})
+	past := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	app.Notes[0].CreatedAt, app.Notes[0].UpdatedAt = past, past
+	app.SaveNotes()
+	before := app.Notes[0]
+	
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"touch", "1"})
+	})
+	if !strings.Contains(out, "Scroll #1 has been touched.") {
+		t.Errorf("touch printed %q", out)
+	}
+	notes, _, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := notes[0]
+	if !after.UpdatedAt.After(past.Add(47 * time.Hour)) {
+		t.Errorf("UpdatedAt is still %v", after.UpdatedAt)
+	}
+	if after.Title != before.Title || after.Content != before.Content || !reflect.DeepEqual(after.Tags, before.Tags) || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("touch changed more than UpdatedAt:\n got %+v\nwant %+v", after, before)
+	}
+	
+	stderr := captureFile(t, &os.Stderr, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"touch", "5"})
+	})
+	if app.ExitCode != ExitNotFound || !strings.Contains(stderr, "not found") {
+		t.Errorf("touching a missing scroll exited %d with %q", app.ExitCode, stderr)
+	}
+}
+
+func TestEnvironmentOverridesAndFlagsWin(t *testing.T) {
+	t.Setenv("SKELOS_EDITOR", "vim")
+	t.Setenv("SKELOS_DATE_FORMAT", "02.01.2006")
+	settings := Settings{Editor: "nano", DateFormat: "2006-01-02", NotesDir: "/from/file"}
+	sources := applyOverrides(&settings, "settings file", map[string]string{"date-format": "Jan 2"})
+	
+	if settings.Editor != "vim" || sources["editor"] != "environment SKELOS_EDITOR" {
+		t.Errorf("editor = %q from %q, want vim from the environment", settings.Editor, sources["editor"])
+	}
+	if settings.DateFormat != "Jan 2" || sources["date-format"] != "flag --date-format" {
+		t.Errorf("date format = %q from %q, want the flag's", settings.DateFormat, sources["date-format"])
+	}
+	if settings.NotesDir != "/from/file" || sources["notes-dir"] != "settings file" {
+		t.Errorf("notes dir = %q from %q, want the file's", settings.NotesDir, sources["notes-dir"])
+	}
+	
+	home := t.TempDir()
+	t.Setenv("SKELOS_NOTES_DIR", filepath.Join(home, "from-env"))
+	stdout, _, code := runMain(t, home, "", "--editor", "emacs", "where")
+	for _, line := range []string{
+		"Archives: " + filepath.Join(home, "from-env") + " (from environment SKELOS_NOTES_DIR)",
+		"Editor: emacs (from flag --editor)",
+		"Date format: 02.01.2006 (from environment SKELOS_DATE_FORMAT)",
+		"Precedence: command-line flags, then SKELOS_* environment variables",
+	} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("where lacks %q:\n%s", line, stdout)
+		}
+	}
+	if code != ExitOK {
+		t.Errorf("where exited %d", code)
+	}
+}
+
+func TestListClipsRenderedOutput(t *testing.T) {
+	saved := lookPath
+	defer func() { lookPath = saved }()
+	for _, wayland := range []bool{true, false} {
+		lookPath = func(name string) (string, error) {
+			if name == "wl-copy" && wayland {
+				return "/usr/bin/wl-copy", nil
+			}
+			return "", fmt.Errorf("%s: not found", name)
+		}
+		want := "xclip"
+		if wayland {
+			want = "wl-copy"
+		}
+		if cmd := clipboardWriteCommand("linux"); cmd == nil || cmd.Args[0] != want {
+			t.Errorf("with wl-copy installed %v, the clipboard command is %v, want %s", wayland, cmd, want)
+		}
+	}
+	lookPath = saved
+	for goos, want := range map[string]string{"darwin": "pbcopy", "windows": "clip"} {
+		if cmd := clipboardWriteCommand(goos); cmd == nil || cmd.Args[0] != want {
+			t.Errorf("clipboardWriteCommand(%q) = %v, want %s", goos, cmd, want)
+		}
+	}
+	if cmd := clipboardWriteCommand("plan9"); cmd != nil {
+		t.Errorf("plan9 got a clipboard command %q", cmd.Args)
+	}
+	
+	if runtime.GOOS != "linux" {
+		return
+	}
+	clipboard := filepath.Join(t.TempDir(), "clipboard")
+	fakeCommand(t, "wl-copy", `cat > "`+clipboard+`"`)
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("First", "x", []string{"a"})
+	app.CreateTextNote("Second", "y", nil)
+	
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"list", "--clip", "--output", "oneline"})
+	})
+	if out != "Copied 2 scrolls to the clipboard.\n" {
+		t.Errorf("list --clip printed %q", out)
+	}
+	want, _ := app.render(app.Notes, OutputOneline)
+	if data, err := ioutil.ReadFile(clipboard); err != nil || string(data) != want {
+		t.Errorf("the clipboard holds %q, %v, want %q", data, err, want)
+	}
+}
+
+func TestShredOverwritesImageBeforeRemoving(t *testing.T) {
+	original := bytes.Repeat([]byte("secret image bytes "), 100)
+	src := filepath.Join(t.TempDir(), "shot.png")
+	if err := ioutil.WriteFile(src, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, StorageJSON)
+	app.AddImageFile(src, "Sensitive", nil)
+	image := app.Notes[0].FilePath
+	link := filepath.Join(t.TempDir(), "link.png")
+	if err := os.Link(image, link); err != nil {
+		t.Skipf("cannot hard-link in the temporary directory: %v", err)
+	}
+	
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("y\n")), []string{"erase", "--shred", "1"})
+	})
+	if !strings.Contains(out, "shredding is best-effort") {
+		t.Errorf("no best-effort warning:\n%s", out)
+	}
+	if _, err := os.Stat(image); !os.IsNotExist(err) {
+		t.Errorf("the image still exists: %v", err)
+	}
+	data, err := ioutil.ReadFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(original) || bytes.Equal(data, original) || bytes.Contains(data, []byte("secret")) {
+		t.Errorf("the image's bytes were not overwritten in place (%d bytes)", len(data))
+	}
+	if trash, _ := app.loadTrash(); len(trash) != 0 {
+		t.Errorf("a shredded scroll went to the trash: %v", trash)
+	}
+	
+	if err := shredFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("shredding a missing file gave %v", err)
+	}
+}
+
+func TestChangesSinceGroupsByDay(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2026, 7, day, hour, 0, 0, 0, time.Local) }
+	since := at(10, 12)
+	notes := []Note{
+		{ID: 1, Title: "Untouched", CreatedAt: at(1, 9), UpdatedAt: at(9, 9)},
+		{ID: 2, Title: "Edited late", CreatedAt: at(2, 9), UpdatedAt: at(12, 18)},
+		{ID: 3, Title: "New", CreatedAt: at(11, 8), UpdatedAt: at(11, 8)},
+		{ID: 4, Title: "Edited early", CreatedAt: at(3, 9), UpdatedAt: at(12, 7)},
+		{ID: 5, Title: "Just before", CreatedAt: at(10, 11), UpdatedAt: at(10, 11)},
+		{ID: 6, Title: "Same day after", CreatedAt: at(10, 13), UpdatedAt: at(10, 13)},
+	}
+	
+	days, groups := changesSince(notes, since)
+	if want := []string{"2026-07-10", "2026-07-11", "2026-07-12"}; !reflect.DeepEqual(days, want) {
+		t.Fatalf("days = %q, want %q", days, want)
+	}
+	for day, want := range map[string][]int{"2026-07-10": {6}, "2026-07-11": {3}, "2026-07-12": {4, 2}} {
+		var ids []int
+		for _, note := range groups[day] {
+			ids = append(ids, note.ID)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s holds %v, want %v", day, ids, want)
+		}
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.Notes = notes
+	app.Settings.DateFormat = "2006-01-02 15:04"
+	out := captureOutput(t, func() { app.ShowSince(since) })
+	if !strings.Contains(out, "2026-07-12\n  [4] Edited early (, updated)\n  [2] Edited late (, updated)\n") ||
+		!strings.Contains(out, "  [3] New (, new)") {
+		t.Errorf("since printed:\n%s", out)
+	}
+	if days, _ := changesSince(notes, at(13, 0)); len(days) != 0 {
+		t.Errorf("changes after the last update = %q", days)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.Settings.DateFormat = "02.01.2006"
+	day := time.Date(2026, 7, 4, 0, 0, 0, 0, time.Local)
+	for _, input := range []string{"04.07.2026", "2026-07-04", " 07/04/2026 ", "Jul 4 2026", "Jul 4, 2026"} {
+		got, err := app.parseDate(input)
+		if err != nil || !got.Equal(day) {
+			t.Errorf("parseDate(%q) = %v, %v, want %v", input, got, err, day)
+		}
+	}
+	if got, err := app.parseDate("2026-07-04 15:30"); err != nil || !got.Equal(day.Add(15*time.Hour+30*time.Minute)) {
+		t.Errorf("a date with a time gave %v, %v", got, err)
+	}
+	for _, input := range []string{"", "yesterday", "2026-13-01", "31.02.2026"} {
+		if _, err := app.parseDate(input); err == nil {
+			t.Errorf("parseDate(%q) was accepted", input)
+		}
+	}
+}
+
+func TestPatchChangesOnlyTags(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Patched", "body stays", []string{"old"})
+	before := app.Notes[0]
+	
+	dir := t.TempDir()
+	patch := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		stderr := captureFile(t, &os.Stderr, func() {
+			captureOutput(t, func() {
+				app.Execute(bufio.NewReader(strings.NewReader("")), []string{"patch", "1", "--file", path})
+			})
+		})
+		return stderr
+	}
+	
+	if stderr := patch("tags.json", `{"tags": ["new", "other"]}`); stderr != "" {
+		t.Fatalf("the patch failed: %s", stderr)
+	}
+	notes, _, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := notes[0]
+	if !reflect.DeepEqual(after.Tags, []string{"new", "other"}) {
+		t.Errorf("runes after the patch = %q", after.Tags)
+	}
+	if after.Title != before.Title || after.Content != before.Content || after.Type != before.Type || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("the patch changed more than the runes: %+v", after)
+	}
+	
+	for name, body := range map[string]string{
+		"unknown.json": `{"color": "red"}`,
+		"type.json":    `{"title": 7}`,
+		"array.json":   `["title"]`,
+		"broken.json":  `{"title": `,
+	} {
+		app.ExitCode = ExitOK
+		if stderr := patch(name, body); !strings.Contains(stderr, "Invalid patch") || app.ExitCode != ExitInvalid {
+			t.Errorf("%s: stderr %q, exit %d", name, stderr, app.ExitCode)
+		}
+	}
+	if app.Notes[0].Title != "Patched" || !reflect.DeepEqual(app.Notes[0].Tags, []string{"new", "other"}) {
+		t.Errorf("a rejected patch changed the scroll: %+v", app.Notes[0])
+	}
+}
+
+func TestExportPlainText(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.Settings.DateFormat = "2006-01-02"
+	app.Settings.TagStyles = []string{"urgent=red ⚠"}
+	app.colors = true
+	app.CreateTextNote("First", "# Heading kept as written\nsecond line", []string{"urgent"})
+	app.CreateTextNote("Second", "plain", nil)
+	created := app.Notes[0].CreatedAt.Format("2006-01-02")
+	
+	path := filepath.Join(t.TempDir(), "scrolls.txt")
+	captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"export-txt", path})
+	})
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	
+	sep := plainTextSeparator
+	first := sep + "\nScroll 1: First\nType: text\nCreated: " + created + "\nUpdated: " + created + "\nTags: urgent\n\n# Heading kept as written\nsecond line\n"
+	second := sep + "\nScroll 2: Second\nType: text\nCreated: " + created + "\nUpdated: " + created + "\nTags: none\n\nplain\n"
+	if !strings.Contains(text, first+"\n"+second+"\n"+sep+"\n") {
+		t.Errorf("the export does not hold both scrolls between separators:\n%s", text)
+	}
+	if !strings.HasPrefix(text, "The Ancient Scrolls\nExported ") || !strings.Contains(text, ", 2 scrolls\n") {
+		t.Errorf("the export has no header:\n%s", text)
+	}
+	if strings.Contains(text, "\x1b[") || strings.Contains(text, "⚠") {
+		t.Errorf("the export holds terminal decoration:\n%q", text)
+	}
+}
+
+func TestSecondEditOfALockedScrollIsRefused(t *testing.T) {
+	release := filepath.Join(t.TempDir(), "release")
+	fakeCommand(t, "slow-editor", `while [ ! -e "`+release+`" ]; do sleep 0.01; done; printf 'from the editor\n' > "$1"`)
+	app := newTestApp(t, StorageJSON)
+	app.Settings.Editor = "slow-editor"
+	app.CreateTextNote("Shared", "original", nil)
+	
+	captureOutput(t, func() { app.EditInEditor(1, true) })
+	for name, edit := range map[string]func(){
+		"the editor": func() { app.EditInEditor(1, true) },
+		"update": func() {
+			app.Execute(bufio.NewReader(strings.NewReader("")), []string{"update", "1", "--title", "Late"})
+		},
+		"erase": func() { app.DeleteNote(1, DeleteOptions{}) },
+	} {
+		app.ExitCode = ExitOK
+		stderr := captureFile(t, &os.Stderr, func() { captureOutput(t, edit) })
+		if !strings.Contains(stderr, "Scroll #1 is being edited (open in slow-editor); finish that edit first.") || app.ExitCode != ExitInvalid {
+			t.Errorf("%s was not refused: %q, exit %d", name, stderr, app.ExitCode)
+		}
+	}
+	if len(app.Notes) != 1 || app.Notes[0].Title != "Shared" {
+		t.Fatalf("a refused edit changed the archives: %+v", app.Notes)
+	}
+	
+	if err := ioutil.WriteFile(release, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() { app.waitForEditors() })
+	if app.Notes[0].Content != "from the editor" {
+		t.Errorf("the editor's content was not saved: %q", app.Notes[0].Content)
+	}
+	app.ExitCode = ExitOK
+	captureOutput(t, func() { app.TouchNote(1) })
+	if app.isLocked(1) || app.ExitCode != ExitOK {
+		t.Error("the lock outlived the editor")
+	}
+}
+
+func TestListOneline(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Groceries", "milk", []string{"home", "errands"})
+	app.CreateTextNote("No runes", "x", nil)
+	app.CreateTextNote("Spaced  title", "y", []string{"work"})
+	for i := range app.Notes {
+		app.Notes[i].CreatedAt = time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC)
+	}
+	
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"list", "--oneline"})
+	})
+	want := "#3 Spaced  title [work]\n#2 No runes\n#1 Groceries [errands, home]\n"
+	if out != want {
+		t.Errorf("list --oneline printed:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestStatsSinceUntil(t *testing.T) {
+	at := func(month, day int) time.Time {
+		return time.Date(2026, time.Month(month), day, 10, 0, 0, 0, time.Local)
+	}
+	app := newTestApp(t, StorageJSON)
+	app.Settings.DateFormat = "2006-01-02"
+	app.Notes = []Note{
+		{ID: 1, Type: "text", Content: "a long january scroll", CreatedAt: at(1, 5), UpdatedAt: at(1, 5)},
+		{ID: 2, Type: "text", Content: "april", CreatedAt: at(4, 1), UpdatedAt: at(7, 1)},
+		{ID: 3, Type: "screenshot", CreatedAt: at(5, 20), UpdatedAt: at(5, 20)},
+		{ID: 4, Type: "text", Content: "end of june", CreatedAt: at(6, 30), UpdatedAt: at(6, 30)},
+		{ID: 5, Type: "text", Content: "july", CreatedAt: at(7, 1), UpdatedAt: at(7, 1)},
+	}
+	
+	stats := func(args ...string) string {
+		return captureOutput(t, func() {
+			app.Execute(bufio.NewReader(strings.NewReader("")), append([]string{"stats"}, args...))
+		})
+	}
+	all := stats()
+	quarter := stats("--since", "2026-04-01", "--until", "2026-06-30")
+	for _, line := range []string{"Scrolls: 5 (4 text, 1 captured images)", "First inscribed: 2026-01-05", "Last inscribed: 2026-07-01", "Average length: 10.2 characters"} {
+		if !strings.Contains(all, line) {
+			t.Errorf("all-time stats lack %q:\n%s", line, all)
+		}
+	}
+	for _, line := range []string{
+		"Inscribed from 2026-04-01 until 2026-07-01",
+		"Scrolls: 3 (2 text, 1 captured images)",
+		"First inscribed: 2026-04-01",
+		"Last inscribed: 2026-06-30",
+		"Last updated: 2026-07-01",
+		"Average length: 8.0 characters",
+	} {
+		if !strings.Contains(quarter, line) {
+			t.Errorf("the quarter's stats lack %q:\n%s", line, quarter)
+		}
+	}
+	if out := stats("--since", "2026-08-01"); !strings.Contains(out, "Scrolls: 0 (0 text, 0 captured images)") {
+		t.Errorf("stats after the last scroll:\n%s", out)
+	}
+	app.ExitCode = ExitOK
+	captureFile(t, &os.Stderr, func() { stats("--since", "someday") })
+	if app.ExitCode != ExitInvalid {
+		t.Errorf("an unreadable date exited %d", app.ExitCode)
+	}
+}
+
+func TestInterruptLeavesArchivesWhole(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent to another process on Windows")
+	}
+	home := t.TempDir()
+	dir := filepath.Join(home, "archives")
+	cmd := mainCommand(home, "--notes-dir", dir)
+	input, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	
+	// Inscribe large scrolls until well after the interrupt, so that it
+	// is likely to arrive in the middle of a save.
+	body := strings.Repeat("a line of the batch\n", 500)
+	go func() {
+		for i := 1; i <= 500; i++ {
+			if _, err := fmt.Fprintf(input, "1\nBatch %d\n%s.\n\n", i, body); err != nil {
+				return
+			}
+		}
+	}()
+	
+	created := 0
+	lines := bufio.NewScanner(output)
+	for lines.Scan() {
+		if strings.Contains(lines.Text(), "Created scroll #") {
+			created++
+			if created == 20 {
+				cmd.Process.Signal(os.Interrupt)
+			}
+		}
+	}
+	err = cmd.Wait()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != ExitInterrupted {
+		t.Fatalf("the interrupted program ended with %v, want exit code %d", err, ExitInterrupted)
+	}
+	
+	settings := defaultSettings()
+	settings.NotesDir = dir
+	notes, nextID, err := newStore(settings, dir).Load()
+	if err != nil {
+		t.Fatalf("the archives were left unreadable: %v", err)
+	}
+	// A save may finish after its scroll was reported, but never before.
+	if len(notes) < created || len(notes) > created+1 {
+		t.Errorf("the archives hold %d scrolls after %d were reported", len(notes), created)
+	}
+	for i, note := range notes {
+		if note.ID != i+1 || note.Content != strings.TrimSuffix(body, "\n") {
+			t.Errorf("scroll %d was saved as #%d with %d bytes", i+1, note.ID, len(note.Content))
+		}
+	}
+	if nextID != len(notes)+1 {
+		t.Errorf("the next ID is %d after %d scrolls", nextID, len(notes))
+	}
+}
+
+func TestCompactKeepsTheSameScrolls(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("One", "first\nscroll", []string{"b", "a"})
+	app.CreateTextNote("Two", "second", nil)
+	app.TouchNote(1)
+	
+	// Churn from older versions: stale fields and loose formatting.
+	file := app.store.(jsonStore).file()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	raw["legacy_setting"] = "old"
+	for _, note := range raw["notes"].([]interface{}) {
+		note.(map[string]interface{})["stale_field"] = strings.Repeat("x", 200)
+	}
+	data, _ = json.MarshalIndent(raw, "", "        ")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	
+	app = NewNotesApp(app.Settings, DefaultNotebook)
+	before, beforeID, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"compact", "--minify"})
+	})
+	if !strings.Contains(out, "rewritten as compact JSON") || !strings.Contains(out, "saved)") {
+		t.Errorf("compact printed %q", out)
+	}
+	
+	compacted, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compacted) >= len(data) || bytes.Contains(compacted, []byte("stale_field")) || bytes.Contains(compacted, []byte("\n  ")) {
+		t.Errorf("the compacted archives still hold churn:\n%s", compacted)
+	}
+	after, afterID, err := NewNotesApp(app.Settings, DefaultNotebook).store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) || afterID != beforeID {
+		t.Errorf("compacting changed the scrolls:\n got %+v\nwant %+v", after, before)
+	}
+}
+
+func TestRenameScreenshotToTitle(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, name := range []string{"a.png", "b.png"} {
+		src := filepath.Join(t.TempDir(), name)
+		if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\n"+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		app.AddImageFile(src, "Whiteboard: Q3 plan", nil)
+	}
+	old := app.Notes[0].FilePath
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() {
+		app.Execute(none, []string{"rename-screenshot", "1"})
+		app.Execute(none, []string{"rename-screenshot", "2"})
+	})
+	for i, want := range []string{"whiteboard-q3-plan.png", "whiteboard-q3-plan-2.png"} {
+		note := app.Notes[i]
+		if note.Screenshot != want || note.FilePath != filepath.Join(app.imagesDir(), want) {
+			t.Errorf("scroll #%d's image is %s at %s, want %s", note.ID, note.Screenshot, note.FilePath, want)
+		}
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("the old file name still exists: %v", err)
+	}
+	
+	// The renamed image still belongs to its scroll after reloading.
+	app = NewNotesApp(app.Settings, DefaultNotebook)
+	if data, err := ioutil.ReadFile(app.Notes[0].FilePath); err != nil || string(data) != "\x89PNG\r\n\x1a\na.png" {
+		t.Errorf("scroll #1's image reads %q, %v", data, err)
+	}
+	out := captureOutput(t, func() { app.Execute(none, []string{"rename-screenshot", "1"}) })
+	if !strings.Contains(out, "is already named whiteboard-q3-plan.png") {
+		t.Errorf("renaming again printed %q", out)
+	}
+}
+
+func TestSearchCountsOccurrences(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Foo notes", "foo and FOO again", nil)
+	app.CreateTextNote("Other", "one foo here", []string{"foo"})
+	app.CreateTextNote("Unrelated", "nothing", nil)
+	
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	out := captureOutput(t, func() { app.SearchNotes("foo", all, ListOptions{Format: OutputTable}) })
+	if !strings.Contains(out, "'foo' appears 5 times across 2 scrolls.") {
+		t.Errorf("the summary is wrong:\n%s", out)
+	}
+	
+	content := SearchOptions{Scope: SearchScope{Content: true}}
+	out = captureOutput(t, func() { app.SearchNotes("foo", content, ListOptions{Format: OutputTable}) })
+	if !strings.Contains(out, "'foo' appears 3 times across 2 scrolls.") {
+		t.Errorf("the content-only summary is wrong:\n%s", out)
+	}
+	
+	exact := SearchOptions{Scope: SearchScope{Content: true}, Mode: SearchCaseSensitive}
+	out = captureOutput(t, func() { app.SearchNotes("FOO", exact, ListOptions{Format: OutputTable}) })
+	if !strings.Contains(out, "'FOO' appears 1 times across 1 scrolls.") {
+		t.Errorf("the case-sensitive summary is wrong:\n%s", out)
+	}
+}
+
+func TestAutoBackupOnStartKeepsTheNewest(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Precious", "keep me safe", nil)
+	
+	// Without the setting, opening the archives backs nothing up.
+	captureOutput(t, func() { runScripted(t, app, "") })
+	if names := app.listBackups(); len(names) != 0 {
+		t.Fatalf("backups were made unasked: %q", names)
+	}
+	
+	app.Settings.AutoBackupOnStart = true
+	app.Settings.BackupsToKeep = 2
+	var made []string
+	for i := 0; i < 4; i++ {
+		out := captureOutput(t, func() { runScripted(t, app, "") })
+		names := app.listBackups()
+		if len(names) == 0 || !strings.Contains(out, "backed up to backups/"+names[len(names)-1]) {
+			t.Fatalf("start %d made no backup:\n%s", i+1, out)
+		}
+		made = append(made, names[len(names)-1])
+	}
+	
+	names := app.listBackups()
+	if !reflect.DeepEqual(names, made[2:]) {
+		t.Errorf("kept backups %q, want the newest two of %q", names, made)
+	}
+	settings := app.Settings
+	settings.NotesDir = filepath.Join(app.backupsDir(), names[1])
+	notes, _, err := newStore(settings, settings.NotesDir).Load()
+	if err != nil || len(notes) != 1 || notes[0].Content != "keep me safe" {
+		t.Errorf("the newest backup holds %+v, %v", notes, err)
+	}
+}
+
+func TestRestoreBackupSetsCurrentAside(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Backed up", "from the backup", nil)
+	name, err := app.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.CreateTextNote("Later", "written after the backup", nil)
+	
+	run := func(input string, args ...string) string {
+		return captureOutput(t, func() {
+			app.Execute(bufio.NewReader(strings.NewReader(input)), append([]string{"restore"}, args...))
+		})
+	}
+	if out := run("", "--list"); !strings.Contains(out, "\n"+name+"\n") {
+		t.Errorf("restore --list does not show %s:\n%s", name, out)
+	}
+	if out := run("n\n", "--backup", name); !strings.Contains(out, "The archives remain as they are.") || len(app.Notes) != 2 {
+		t.Fatalf("declining restored anyway:\n%s", out)
+	}
+	
+	out := run("y\n", "--backup", name)
+	if len(app.Notes) != 1 || app.Notes[0].Title != "Backed up" || app.NextID != 2 {
+		t.Fatalf("after restoring, the archives hold %+v (next ID %d)", app.Notes, app.NextID)
+	}
+	if notes, _, err := app.store.Load(); err != nil || len(notes) != 1 {
+		t.Errorf("the restored store holds %+v, %v", notes, err)
+	}
+	
+	var aside string
+	for _, backup := range app.listBackups() {
+		if strings.HasSuffix(backup, "-before-restore") {
+			aside = backup
+		}
+	}
+	if aside == "" || !strings.Contains(out, "rest in backups/"+aside) {
+		t.Fatalf("no backup of the archives before the restore:\n%s", out)
+	}
+	settings := app.Settings
+	settings.NotesDir = filepath.Join(app.backupsDir(), aside)
+	notes, _, err := newStore(settings, settings.NotesDir).Load()
+	if err != nil || len(notes) != 2 || notes[1].Title != "Later" {
+		t.Errorf("the archives set aside hold %+v, %v", notes, err)
+	}
+	
+	app.ExitCode = ExitOK
+	captureFile(t, &os.Stderr, func() { run("", "--backup", "../archives", "--yes") })
+	if app.ExitCode != ExitNotFound || len(app.Notes) != 1 {
+		t.Errorf("restoring a path outside backups/ exited %d", app.ExitCode)
+	}
+}
+
+func TestTodosExtractOpenAndDone(t *testing.T) {
+	content := "# Chores\n- [ ] feed the owls\n* [x] sweep the hall\nnot a [ ] task\n  + [X] polish lamps\r\n- [ ]   mend the roof\n-[ ] no space, no task\n"
+	want := []Task{
+		{N: 1, Text: "feed the owls"},
+		{N: 2, Text: "sweep the hall", Done: true},
+		{N: 3, Text: "polish lamps", Done: true},
+		{N: 4, Text: "mend the roof"},
+	}
+	if got := extractTasks(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractTasks = %+v, want %+v", got, want)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Chores", content, nil)
+	app.CreateTextNote("Nothing to do", "plain text", nil)
+	app.CreateTextNote("Errands", "- [x] buy ink", nil)
+	none := bufio.NewReader(strings.NewReader(""))
+	
+	open := captureOutput(t, func() { app.Execute(none, []string{"todos"}) })
+	wantOpen := "\n#1 Chores\n  1) [ ] feed the owls\n  4) [ ] mend the roof\n\n2 tasks.\n"
+	if !strings.HasSuffix(open, wantOpen) || strings.Contains(open, "Errands") {
+		t.Errorf("todos printed:\n%s\nwant it to end with:\n%s", open, wantOpen)
+	}
+	done := captureOutput(t, func() { app.Execute(none, []string{"todos", "--done"}) })
+	wantDone := "\n#1 Chores\n  2) [x] sweep the hall\n  3) [x] polish lamps\n\n#3 Errands\n  1) [x] buy ink\n\n3 tasks.\n"
+	if !strings.HasSuffix(done, wantDone) {
+		t.Errorf("todos --done printed:\n%s\nwant it to end with:\n%s", done, wantDone)
+	}
+}
+
+func TestCheckTogglesOnlyTheNthTask(t *testing.T) {
+	content := "intro\n- [ ] first\n- [x] second\n  * [ ] third [ ] with brackets\nend"
+	for _, c := range []struct {
+		n    int
+		done bool
+		want string
+	}{
+		{1, true, "intro\n- [x] first\n- [x] second\n  * [ ] third [ ] with brackets\nend"},
+		{2, false, "intro\n- [ ] first\n- [ ] second\n  * [ ] third [ ] with brackets\nend"},
+		{3, true, "intro\n- [ ] first\n- [x] second\n  * [x] third [ ] with brackets\nend"},
+	} {
+		got, err := setTask(content, c.n, c.done)
+		if err != nil || got != c.want {
+			t.Errorf("setTask(%d, %v) = %q, %v, want %q", c.n, c.done, got, err, c.want)
+		}
+	}
+	for _, n := range []int{0, 4} {
+		if _, err := setTask(content, n, true); err == nil {
+			t.Errorf("setTask(%d) found a task", n)
+		}
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Tasks", content, nil)
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() {
+		app.Execute(none, []string{"check", "1", "3"})
+		app.Execute(none, []string{"uncheck", "1", "2"})
+	})
+	notes, _, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "intro\n- [ ] first\n- [ ] second\n  * [x] third [ ] with brackets\nend"; notes[0].Content != want {
+		t.Errorf("after check 3 and uncheck 2 the scroll reads %q, want %q", notes[0].Content, want)
+	}
+	out := captureOutput(t, func() { app.Execute(none, []string{"check", "1", "3"}) })
+	if !strings.Contains(out, "already so marked") {
+		t.Errorf("checking a checked task printed %q", out)
+	}
+}
+
+func TestMoveUpChangesManualOrder(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for i, title := range []string{"A", "B", "C"} {
+		app.CreateTextNote(title, "x", nil)
+		app.Notes[i].CreatedAt = time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC)
+	}
+	none := bufio.NewReader(strings.NewReader(""))
+	list := func() string {
+		return captureOutput(t, func() { app.Execute(none, []string{"list", "--sort", "manual", "--output", "ids"}) })
+	}
+	if got := list(); got != "1\n2\n3\n" {
+		t.Fatalf("the manual order starts as %q, want creation order", got)
+	}
+	
+	captureOutput(t, func() {
+		app.Execute(none, []string{"move-up", "3"})
+		app.Execute(none, []string{"move-up", "3"})
+		app.Execute(none, []string{"move-down", "1"})
+	})
+	if got := list(); got != "3\n2\n1\n" {
+		t.Errorf("after moving, the manual order is %q, want 3 2 1", got)
+	}
+	out := captureOutput(t, func() { app.Execute(none, []string{"move-up", "3"}) })
+	if !strings.Contains(out, "Scroll #3 is already at the top.") {
+		t.Errorf("moving the top scroll up printed %q", out)
+	}
+	
+	// The order survives reopening the archives, and new scrolls go last.
+	app = NewNotesApp(app.Settings, DefaultNotebook)
+	app.CreateTextNote("D", "x", nil)
+	if got := list(); got != "3\n2\n1\n4\n" {
+		t.Errorf("after reopening, the manual order is %q, want 3 2 1 4", got)
+	}
+}
+
+func TestSeekInScopesTheSearch(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Harbour", "the lighthouse keeper", []string{"coast"})
+	app.CreateTextNote("Lighthouse", "tall and white", nil)
+	app.CreateTextNote("Maps", "charts", []string{"lighthouse"})
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	for in, want := range map[string]string{
+		"":              "1\n2\n3\n",
+		"title":         "2\n",
+		"content":       "1\n",
+		"tags":          "3\n",
+		"title,content": "1\n2\n",
+		" Tags , title": "2\n3\n",
+	} {
+		args := []string{"seek", "--output", "ids"}
+		if in != "" {
+			args = append(args, "--in", in)
+		}
+		out := captureOutput(t, func() { app.Execute(none, append(args, "lighthouse")) })
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		sort.Strings(lines)
+		if got := strings.Join(lines, "\n") + "\n"; got != want {
+			t.Errorf("seek --in %q found %q, want %q", in, got, want)
+		}
+	}
+	
+	app.ExitCode = ExitOK
+	stderr := captureFile(t, &os.Stderr, func() {
+		captureOutput(t, func() { app.Execute(none, []string{"seek", "--in", "body", "lighthouse"}) })
+	})
+	if app.ExitCode != ExitInvalid || stderr == "" {
+		t.Errorf("an unknown scope exited %d with %q", app.ExitCode, stderr)
+	}
+}
+
+func TestStrictDeleteWantsTheTitle(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.Settings.StrictDeleteConfirm = true
+	app.CreateTextNote("Ledger 2026", "numbers", nil)
+	
+	for _, typed := range []string{"y\n", "yes\n", "ledger 2026\n", "Ledger 2026 \n", "Ledger\n", ""} {
+		out := captureOutput(t, func() {
+			app.Execute(bufio.NewReader(strings.NewReader(typed)), []string{"erase", "1"})
+		})
+		if len(app.Notes) != 1 {
+			t.Fatalf("typing %q erased the scroll", typed)
+		}
+		if !strings.Contains(out, "type its title exactly (Ledger 2026)") {
+			t.Errorf("typing %q was not asked for the title:\n%s", typed, out)
+		}
+	}
+	
+	captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("Ledger 2026\r\n")), []string{"erase", "1"})
+	})
+	if len(app.Notes) != 0 {
+		t.Error("the exact title did not erase the scroll")
+	}
+	
+	// Without the setting, y is enough.
+	app.Settings.StrictDeleteConfirm = false
+	app.CreateTextNote("Scratch", "x", nil)
+	captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("y\n")), []string{"erase", "2"})
+	})
+	if len(app.Notes) != 0 {
+		t.Error("y did not erase the scroll without strict_delete_confirm")
+	}
+}
+
+func TestOutlineIndentsByLevel(t *testing.T) {
+	content := "# Guide\nintro\n## Setup\n### Linux ###\n```sh\n# a comment, not a heading\n```\n#not a heading\n## Use\n###### Deep\n####### too deep"
+	want := []Heading{
+		{Level: 1, Text: "Guide", Line: 1},
+		{Level: 2, Text: "Setup", Line: 3},
+		{Level: 3, Text: "Linux", Line: 4},
+		{Level: 2, Text: "Use", Line: 9},
+		{Level: 6, Text: "Deep", Line: 10},
+	}
+	if got := extractHeadings(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractHeadings = %+v, want %+v", got, want)
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Guide", content, nil)
+	out := captureOutput(t, func() {
+		app.Execute(bufio.NewReader(strings.NewReader("")), []string{"outline", "1"})
+	})
+	wantOut := "\n=== Outline of Scroll #1: Guide ===\n" +
+		"   1  Guide\n" +
+		"   3    Setup\n" +
+		"   4      Linux\n" +
+		"   9    Use\n" +
+		"  10            Deep\n"
+	if out != wantOut {
+		t.Errorf("outline printed:\n%s\nwant:\n%s", out, wantOut)
+	}
+}
+
+func TestPinLimitRefusesOrEvicts(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, title := range []string{"One", "Two", "Three", "Four"} {
+		app.CreateTextNote(title, "x", nil)
+	}
+	pin := func(answer string, id string) (string, string) {
+		var out string
+		stderr := captureFile(t, &os.Stderr, func() {
+			out = captureOutput(t, func() {
+				app.Execute(bufio.NewReader(strings.NewReader(answer)), []string{"pin", id})
+			})
+		})
+		return out, stderr
+	}
+	pinned := func() []int {
+		var ids []int
+		for _, i := range app.pinnedIndexes() {
+			ids = append(ids, app.Notes[i].ID)
+		}
+		return ids
+	}
+	
+	app.Settings.MaxPinned = 2
+	pin("", "1")
+	pin("", "2")
+	if _, stderr := pin("", "3"); !strings.Contains(stderr, "2 scrolls are pinned, the most allowed.") || app.ExitCode != ExitInvalid {
+		t.Errorf("pinning past the limit was not refused: %q", stderr)
+	}
+	if got := pinned(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("pinned after refusing = %v", got)
+	}
+	
+	app.Settings.PinOverflow = PinEvict
+	if out, _ := pin("n\n", "3"); !strings.Contains(out, "Unpin the oldest, #1 One?") || !strings.Contains(out, "The pins remain as they were.") {
+		t.Errorf("declining the eviction printed %q", out)
+	}
+	if got := pinned(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("pinned after declining = %v", got)
+	}
+	pin("y\n", "3")
+	if got := pinned(); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("pinned after evicting = %v, want [2 3]", got)
+	}
+	pin("y\n", "4")
+	if got := pinned(); !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Errorf("pinned after evicting again = %v, want [3 4]", got)
+	}
+	
+	notes, _, err := app.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range notes {
+		if (note.PinnedAt != nil) != (note.ID >= 3) {
+			t.Errorf("scroll #%d was saved with pin %v", note.ID, note.PinnedAt)
+		}
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	for input, want := range map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		" 2W ":  14 * 24 * time.Hour,
+		"36h":   36 * time.Hour,
+		"90m":   90 * time.Minute,
+		"1h30m": 90 * time.Minute,
+	} {
+		if got, err := parseSpan(input); err != nil || got != want {
+			t.Errorf("parseSpan(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "d", "0d", "-3d", "1.5d", "7", "week", "-2h", "0h"} {
+		if got, err := parseSpan(input); err == nil {
+			t.Errorf("parseSpan(%q) = %v, want an error", input, got)
+		}
+	}
+}
+
+func TestDigestForAWindow(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 9, day, 12, 0, 0, 0, time.UTC) }
+	app := newTestApp(t, StorageJSON)
+	app.Notes = []Note{
+		{ID: 1, Title: "Too old", Type: "text", Content: "x", Tags: []string{"work"}, CreatedAt: at(1), UpdatedAt: at(1)},
+		{ID: 2, Title: "Revised", Type: "text", Content: "x", Tags: []string{"home"}, CreatedAt: at(2), UpdatedAt: at(9)},
+		{ID: 3, Title: "Plan", Type: "text", Content: "# Goals\n**Ship** it", Tags: []string{"work", "q3"}, CreatedAt: at(8), UpdatedAt: at(8)},
+		{ID: 4, Title: "Board", Type: "screenshot", Tags: []string{"work"}, CreatedAt: at(10), UpdatedAt: at(10)},
+		{ID: 5, Title: "Too new", Type: "text", Content: "x", Tags: []string{"home"}, CreatedAt: at(15), UpdatedAt: at(15)},
+	}
+	want := "Digest of the Ancient Scrolls, 2026-09-07 to 2026-09-14\n\n" +
+		"2 new scrolls, 1 updated, 5 in the archives.\n" +
+		"\nNew scrolls:\n" +
+		"- #3 Plan: Goals Ship it\n" +
+		"- #4 Board (captured image)\n" +
+		"\nUpdated scrolls:\n" +
+		"- #2 Revised\n" +
+		"\nMost used runes:\n" +
+		"- work (2)\n" +
+		"- q3 (1)\n"
+	if got := app.digest(at(7), at(14)); got != want {
+		t.Errorf("digest:\n%s\nwant:\n%s", got, want)
+	}
+	
+	quiet := app.digest(at(20), at(27))
+	if !strings.Contains(quiet, "0 new scrolls, 0 updated, 5 in the archives.\n") || strings.Contains(quiet, "runes") {
+		t.Errorf("an empty window's digest:\n%s", quiet)
+	}
+}
+
+func TestPrioritySortBreaksTiesByDate(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for i, title := range []string{"A", "B", "C", "D", "E"} {
+		app.CreateTextNote(title, "x", nil)
+		app.Notes[i].CreatedAt = time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC)
+	}
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() {
+		app.Execute(none, []string{"priority", "1", "5"})
+		app.Execute(none, []string{"priority", "2", "1"})
+		app.Execute(none, []string{"priority", "4", "5"})
+		app.Execute(none, []string{"priority", "5", "3"})
+		app.Execute(none, []string{"priority", "5", "none"})
+	})
+	
+	// 1 and 4 tie at 5, so the newer 4 comes first; 3 and 5 have none.
+	out := captureOutput(t, func() { app.Execute(none, []string{"list", "--sort", "priority", "--output", "ids"}) })
+	if out != "4\n1\n2\n5\n3\n" {
+		t.Errorf("the priority order is %q, want 4 1 2 5 3", out)
+	}
+	
+	out = captureFile(t, &os.Stderr, func() { app.Execute(none, []string{"priority", "1", "high"}) })
+	if !strings.Contains(out, "Invalid priority: high") {
+		t.Errorf("a word priority printed %q", out)
+	}
+}
+
+func TestPagerIsBypassedWhenPiped(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "paged")
+	fakeCommand(t, "fakepager", "cat > "+marker)
+	t.Setenv("LINES", "5")
+	app := newTestApp(t, StorageJSON)
+	app.Settings.Pager = "fakepager"
+	for i := 0; i < 20; i++ {
+		app.CreateTextNote(fmt.Sprintf("Scroll %d", i), "x", nil)
+	}
+	
+	long := strings.Repeat("line\n", 40)
+	if out := captureOutput(t, func() { app.page(long) }); out != long {
+		t.Errorf("paging into a pipe printed %d bytes, want the text as is", len(out))
+	}
+	out := captureOutput(t, func() { app.ListNotes(ListOptions{Format: OutputTable}) })
+	if !strings.Contains(out, "Scroll 19") {
+		t.Errorf("listing into a pipe printed %q", out)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("the pager ran although the output was not a terminal")
+	}
+	
+	// The setting wins over $PAGER, and none turns paging off.
+	t.Setenv("PAGER", "more")
+	if cmd := app.pagerCommand(); cmd == nil || filepath.Base(cmd.Path) != "fakepager" {
+		t.Errorf("pagerCommand() = %v, want the configured pager", cmd)
+	}
+	app.Settings.Pager = "none"
+	if cmd := app.pagerCommand(); cmd != nil {
+		t.Errorf("with the pager set to none, pagerCommand() = %v", cmd.Args)
+	}
+}
+
+func TestTagTreeCountsDescendants(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Button", "x", []string{"work/project/ui"})
+	app.CreateTextNote("Schema", "x", []string{"work/project/db", "Work/Project/UI"})
+	app.CreateTextNote("Standup", "x", []string{"work"})
+	app.CreateTextNote("Groceries", "x", []string{"home"})
+	
+	root := buildTagTree(app.Notes)
+	work := root.Children["work"]
+	if work == nil || work.Count != 3 {
+		t.Fatalf("work counts %v, want all 3 work scrolls", work)
+	}
+	if project := work.Children["project"]; project.Count != 2 || project.Children["ui"].Count != 2 {
+		t.Errorf("project counts %d and ui %d, want 2 each", project.Count, project.Children["ui"].Count)
+	}
+	
+	out := captureOutput(t, app.ShowTagTree)
+	want := "├── home (1)\n" +
+		"└── work (3)\n" +
+		"    └── project (2)\n" +
+		"        ├── db (1)\n" +
+		"        └── ui (2)\n"
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("the tree printed:\n%s\nwant it to end:\n%s", out, want)
+	}
+	
+	// Filtering by a parent rune takes in its descendants, but not look-alikes.
+	for query, want := range map[string]bool{"work": true, "work/project/ui": true, "Work/Project": true, "workshop": false, "home/work": false} {
+		if got := app.containsTag([]string{query}, "work", TagMatchExact); got != want {
+			t.Errorf("%q matches work: %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestCompressedStoreRoundTripsAndMigrates(t *testing.T) {
+	dir := t.TempDir()
+	plain := jsonStore{path: filepath.Join(dir, "scrolls.json")}
+	notes := []Note{
+		{ID: 1, Title: "Plan", Type: "text", Content: strings.Repeat("ship it ", 100), Tags: []string{"work"}},
+		{ID: 2, Title: "Board", Type: "screenshot", FilePath: "board.png"},
+	}
+	if err := plain.Save(notes, 3); err != nil {
+		t.Fatal(err)
+	}
+	
+	// A compressed store reads the plain file until it next saves.
+	zipped := jsonStore{path: plain.path, compress: true}
+	got, nextID, err := zipped.Load()
+	if err != nil || nextID != 3 || len(got) != 2 || got[0].Content != notes[0].Content {
+		t.Fatalf("loading the plain file gave %d scrolls, next %d, %v", len(got), nextID, err)
+	}
+	if err := zipped.Save(got, nextID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plain.path); !os.IsNotExist(err) {
+		t.Error("the plain scrolls.json is still there after migrating")
+	}
+	data, err := ioutil.ReadFile(plain.path + ".gz")
+	if err != nil || !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		t.Fatalf("scrolls.json.gz is not gzipped (%v)", err)
+	}
+	
+	got, nextID, err = zipped.Load()
+	if err != nil || nextID != 3 || !reflect.DeepEqual(got, notes) {
+		t.Errorf("the compressed store read back %+v, next %d, %v", got, nextID, err)
+	}
+	
+	// Switching compression off migrates back.
+	got, _, err = plain.Load()
+	if err != nil || len(got) != 2 {
+		t.Fatalf("the plain store could not read scrolls.json.gz: %v", err)
+	}
+	if err := plain.Save(got, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plain.path + ".gz"); !os.IsNotExist(err) {
+		t.Error("scrolls.json.gz is still there after switching compression off")
+	}
+}
+
+func TestNearestIDs(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	for _, id := range []int{2, 5, 7, 8, 20} {
+		app.Notes = append(app.Notes, Note{ID: id, Title: fmt.Sprintf("Scroll %d", id)})
+	}
+	for _, c := range []struct {
+		id, n int
+		want  []int
+	}{
+		{6, 3, []int{5, 7, 8}},
+		{6, 2, []int{5, 7}}, // 5 and 7 are equally near
+		{1, 2, []int{2, 5}},
+		{100, 1, []int{20}},
+		{14, 1, []int{8}}, // ties go to the lower ID
+		{6, 10, []int{2, 5, 7, 8, 20}},
+	} {
+		if got := app.nearestIDs(c.id, c.n); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("nearestIDs(%d, %d) = %v, want %v", c.id, c.n, got, c.want)
+		}
+	}
+	
+	app.Notes = nil
+	if got := app.nearestIDs(6, 3); len(got) != 0 {
+		t.Errorf("with no scrolls, nearestIDs = %v", got)
+	}
+}
+
+func TestMirrorFollowsEdits(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Plan", "first draft", []string{"work"})
+	app.CreateTextNote("Other", "x", nil)
+	mirror := filepath.Join(t.TempDir(), "plan.md")
+	read := func() string {
+		data, err := ioutil.ReadFile(mirror)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() { app.Execute(none, []string{"mirror", "1", mirror}) })
+	if got := read(); got != "# Plan\n\nTags: work\n\nfirst draft\n" {
+		t.Errorf("the new mirror holds %q", got)
+	}
+	
+	content := "second draft"
+	captureOutput(t, func() { app.UpdateNote(1, noteUpdate{Content: &content}) })
+	if got := read(); got != "# Plan\n\nTags: work\n\nsecond draft\n" {
+		t.Errorf("after editing, the mirror holds %q", got)
+	}
+	
+	// Stopping the mirror leaves the file as it was.
+	captureOutput(t, func() { app.Execute(none, []string{"mirror", "1", "--off"}) })
+	content = "third draft"
+	captureOutput(t, func() { app.UpdateNote(1, noteUpdate{Content: &content}) })
+	if got := read(); !strings.Contains(got, "second draft") {
+		t.Errorf("after --off, the mirror holds %q", got)
+	}
+}
+
+func TestMissingImagesReportsOnlyTheGone(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.png")
+	if err := ioutil.WriteFile(present, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, StorageJSON)
+	app.Notes = []Note{
+		{ID: 1, Title: "Kept", Type: "screenshot", FilePath: present, Screenshot: "present.png"},
+		{ID: 2, Title: "Lost", Type: "screenshot", FilePath: filepath.Join(dir, "gone.png"), Screenshot: "gone.png"},
+		{ID: 3, Title: "Words", Type: "text", Content: "x"},
+	}
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	out := captureOutput(t, func() { app.Execute(none, []string{"missing-images"}) })
+	if !strings.Contains(out, "[2] Lost: ") || strings.Contains(out, "Kept") || !strings.Contains(out, "1 scrolls are missing") {
+		t.Errorf("missing-images printed:\n%s", out)
+	}
+	if app.Notes[1].Type != "screenshot" || app.Notes[1].FilePath == "" {
+		t.Error("listing alone changed the lost scroll")
+	}
+	
+	captureOutput(t, func() { app.Execute(none, []string{"missing-images", "--convert"}) })
+	lost := app.Notes[1]
+	if lost.Type != "text" || lost.FilePath != "" || lost.Content != "(The captured image gone.png was lost.)" {
+		t.Errorf("after --convert the lost scroll is %+v", lost)
+	}
+	if app.Notes[0].Type != "screenshot" || app.Notes[0].FilePath != present {
+		t.Error("--convert touched the scroll whose image is present")
+	}
+	out = captureOutput(t, func() { app.Execute(none, []string{"missing-images"}) })
+	if !strings.Contains(out, "Every captured image rests where its scroll expects it.") {
+		t.Errorf("after mending, missing-images printed %q", out)
+	}
+}
+
+func TestRenameNotebookKeepsItsScrolls(t *testing.T) {
+	home := newTestApp(t, StorageJSON)
+	work := NewNotesApp(home.Settings, "work")
+	work.CreateTextNote("Standup", "status", nil)
+	image := filepath.Join(work.imagesDir(), "board.png")
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	work.Notes = append(work.Notes, Note{ID: 2, Title: "Board", Type: "screenshot", FilePath: image})
+	work.SaveNotes()
+	NewNotesApp(home.Settings, "archive").CreateTextNote("Old", "x", nil)
+	
+	out := captureOutput(t, func() { home.RenameNotebook("work", "job") })
+	if !strings.Contains(out, "The work notebook is now called job.") {
+		t.Errorf("rename-notebook printed %q", out)
+	}
+	if _, err := os.Stat(notebookDir(home.Settings.NotesDir, "work")); !os.IsNotExist(err) {
+		t.Error("the work directory is still there")
+	}
+	
+	job := NewNotesApp(home.Settings, "job")
+	if len(job.Notes) != 2 || job.Notes[0].Title != "Standup" {
+		t.Fatalf("the job notebook holds %+v", job.Notes)
+	}
+	if path := job.Notes[1].FilePath; !strings.HasPrefix(path, job.NotesDir) {
+		t.Errorf("the image scroll points at %s, outside %s", path, job.NotesDir)
+	} else if _, err := os.Stat(path); err != nil {
+		t.Errorf("the image scroll's file is gone: %v", err)
+	}
+	
+	stderr := captureFile(t, &os.Stderr, func() { home.RenameNotebook("job", "archive") })
+	if home.ExitCode != ExitInvalid || !strings.Contains(stderr, "A notebook named archive already exists.") {
+		t.Errorf("renaming onto an existing notebook gave exit %d, %q", home.ExitCode, stderr)
+	}
+	if len(NewNotesApp(home.Settings, "job").Notes) != 2 {
+		t.Error("the refused rename disturbed the job notebook")
+	}
+}
+
+func TestTagDelimiterSetting(t *testing.T) {
+	for _, c := range []struct {
+		delimiter, input string
+		want             []string
+	}{
+		{TagDelimiterSpace, "#work  #urgent work", []string{"urgent", "work"}},
+		{TagDelimiterSpace, "a,b c", []string{"a,b", "c"}},
+		{TagDelimiterSemicolon, "fish, chips; peas;", []string{"fish, chips", "peas"}},
+		{TagDelimiterComma, "#work #urgent, b", []string{"#work #urgent", "b"}},
+		{"", "a, b", []string{"a", "b"}},
+	} {
+		if got := splitTags(c.input, c.delimiter); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitTags(%q, %q) = %q, want %q", c.input, c.delimiter, got, c.want)
+		}
+	}
+	
+	// The retag prompt follows the setting.
+	app := newTestApp(t, StorageJSON)
+	app.Settings.TagDelimiter = TagDelimiterSpace
+	app.CreateTextNote("Scroll", "x", nil)
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader("#work #urgent\n"))
+	defer func() { stdin = saved }()
+	out := captureOutput(t, func() { app.Execute(stdin, []string{"retag", "1"}) })
+	if got := app.Notes[0].Tags; !reflect.DeepEqual(got, []string{"urgent", "work"}) {
+		t.Errorf("retagging with spaces gave runes %q", got)
+	}
+	if !strings.Contains(out, "space-separated") {
+		t.Errorf("the retag prompt did not say how to separate runes:\n%s", out)
+	}
+}
+
+func TestErasePreviewComesBeforeThePrompt(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Secret plan", "the whole of it", []string{"work"})
+	
+	reader := bufio.NewReader(strings.NewReader("n\n"))
+	out := captureOutput(t, func() { app.Execute(reader, []string{"erase", "--preview", "1"}) })
+	prompt := strings.Index(out, "Are you certain")
+	if prompt < 0 {
+		t.Fatalf("erase --preview never asked:\n%s", out)
+	}
+	for _, detail := range []string{"Secret plan", "work", "the whole of it"} {
+		if i := strings.Index(out, detail); i < 0 || i > prompt {
+			t.Errorf("%q is not shown before the prompt:\n%s", detail, out)
+		}
+	}
+	if len(app.Notes) != 1 {
+		t.Error("declining after the preview still erased the scroll")
+	}
+	
+	reader = bufio.NewReader(strings.NewReader("y\n"))
+	out = captureOutput(t, func() { app.Execute(reader, []string{"erase", "1"}) })
+	if strings.Contains(out, "the whole of it") {
+		t.Errorf("erase without --preview showed the content:\n%s", out)
+	}
+}
+
+func TestTagStylesDecorateOnlyTerminals(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.Settings.TagStyles = []string{"urgent=red ⚠", "work=blue", "urgent=green ★"}
+	app.CreateTextNote("Fire", "x", []string{"urgent", "work"})
+	app.CreateTextNote("Report", "x", []string{"work"})
+	app.CreateTextNote("Walk", "x", []string{"home"})
+	list := func() string {
+		return captureOutput(t, func() { app.ListNotes(ListOptions{Format: OutputTable}) })
+	}
+	
+	// Output captured through a pipe is not a terminal.
+	if app.colors {
+		t.Fatal("colors are on although standard output is not a terminal")
+	}
+	if out := list(); strings.Contains(out, "\x1b[") || strings.Contains(out, "⚠") || !strings.Contains(out, "] Fire (text)") {
+		t.Errorf("the plain listing is decorated:\n%q", out)
+	}
+	
+	app.colors = true
+	out := list()
+	for _, want := range []string{"] ⚠ \x1b[31mFire\x1b[0m (text)", "] \x1b[34mReport\x1b[0m (text)", "] Walk (text)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the terminal listing lacks %q:\n%q", want, out)
+		}
+	}
+}
+
+func TestBackfillOCRSkipsReadImages(t *testing.T) {
+	saved := readImageText
+	defer func() { readImageText = saved }()
+	var read []string
+	readImageText = func(path string) (string, error) {
+		read = append(read, path)
+		switch path {
+		case "blank.png":
+			return "", nil
+		case "broken.png":
+			return "", fmt.Errorf("not an image")
+		}
+		return "text of " + path, nil
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.Notes = []Note{
+		{ID: 1, Title: "Done", Type: "screenshot", FilePath: "done.png", OCRText: "read before"},
+		{ID: 2, Title: "Board", Type: "screenshot", FilePath: "board.png"},
+		{ID: 3, Title: "Words", Type: "text", Content: "x"},
+		{ID: 4, Title: "Blank", Type: "screenshot", FilePath: "blank.png"},
+		{ID: 5, Title: "Broken", Type: "screenshot", FilePath: "broken.png"},
+	}
+	
+	out := captureOutput(t, app.BackfillOCR)
+	if want := []string{"board.png", "blank.png", "broken.png"}; !reflect.DeepEqual(read, want) {
+		t.Errorf("OCR ran over %q, want %q", read, want)
+	}
+	if app.Notes[0].OCRText != "read before" || app.Notes[1].OCRText != "text of board.png" || app.Notes[3].OCRText != "" {
+		t.Errorf("the scrolls' text is %q, %q, %q", app.Notes[0].OCRText, app.Notes[1].OCRText, app.Notes[3].OCRText)
+	}
+	for _, want := range []string{"[1/3] #2 Board... 17 characters", "no text found", "failed: not an image", "Read the text of 1 captured images; 1 had been read before."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the progress lacks %q:\n%s", want, out)
+		}
+	}
+	
+	notes, _, err := app.store.Load()
+	if err != nil || notes[1].OCRText != "text of board.png" {
+		t.Errorf("the read text was not saved (%v)", err)
+	}
+}
+
+func TestEraseLeavesATombstone(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Kept", "x", nil)
+	app.CreateTextNote("Gone", "x", nil)
+	app.CreateTextNote("Unrecorded", "x", nil)
+	
+	captureOutput(t, func() { app.DeleteNote(3, DeleteOptions{}) })
+	if tombstones, err := app.loadTombstones(); err != nil || len(tombstones) != 0 {
+		t.Fatalf("without keep_tombstones, erasing recorded %+v (%v)", tombstones, err)
+	}
+	
+	app.Settings.KeepTombstones = true
+	before := time.Now()
+	captureOutput(t, func() { app.DeleteNote(2, DeleteOptions{}) })
+	tombstones, err := app.loadTombstones()
+	if err != nil || len(tombstones) != 1 {
+		t.Fatalf("erasing recorded %+v (%v), want one tombstone", tombstones, err)
+	}
+	if ts := tombstones[0]; ts.ID != 2 || ts.Title != "Gone" || ts.DeletedAt.Before(before) {
+		t.Errorf("the tombstone is %+v", ts)
+	}
+	if out := captureOutput(t, app.ShowTombstones); !strings.Contains(out, "#2 Gone (erased ") {
+		t.Errorf("tombstones printed %q", out)
+	}
+	
+	// Purging by age keeps the recent erasure and drops an old one.
+	tombstones = append(tombstones, Tombstone{ID: 9, Title: "Ancient", DeletedAt: before.AddDate(0, -2, 0)})
+	if err := app.saveTombstones(tombstones); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() { app.PurgeTombstones(30 * 24 * time.Hour) })
+	if tombstones, _ = app.loadTombstones(); len(tombstones) != 1 || tombstones[0].ID != 2 || !strings.Contains(out, "Purged 1 tombstones; 1 remain.") {
+		t.Errorf("purging a month back left %+v and printed %q", tombstones, out)
+	}
+	captureOutput(t, func() { app.PurgeTombstones(0) })
+	if tombstones, _ = app.loadTombstones(); len(tombstones) != 0 {
+		t.Errorf("purge-tombstones without an age left %+v", tombstones)
+	}
+}
+
+func TestSyncErasesByTombstoneAndCopiesImages(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 9, day, 12, 0, 0, 0, time.UTC) }
+	app := newTestApp(t, StorageJSON)
+	remote := openRemote(t, app)
+	
+	image := filepath.Join(remote.imagesDir(), "board.png")
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.Notes = []Note{
+		{ID: 1, Title: "Kept", Type: "text", Content: "a", CreatedAt: at(1), UpdatedAt: at(1)},
+		{ID: 3, Title: "Erased there", Type: "text", CreatedAt: at(1), UpdatedAt: at(2)},
+		{ID: 4, Title: "Revived here", Type: "text", CreatedAt: at(1), UpdatedAt: at(6)},
+	}
+	app.NextID = 5
+	app.SaveNotes()
+	remote.Notes = []Note{
+		{ID: 2, Title: "Board", Type: "screenshot", Screenshot: "board.png", FilePath: image, CreatedAt: at(1), UpdatedAt: at(1)},
+	}
+	remote.NextID = 5
+	remote.SaveNotes()
+	tombstones := []Tombstone{{ID: 3, DeletedAt: at(4)}, {ID: 4, DeletedAt: at(4)}}
+	if err := remote.saveTombstones(tombstones); err != nil {
+		t.Fatal(err)
+	}
+	
+	// A tombstone erases the scroll unless it changed after the erasure.
+	out := captureOutput(t, func() { app.Sync(nil, remote.NotesDir) })
+	if !strings.Contains(out, "2 scrolls sent, 1 received, 1 erased, 0 conflicts.") {
+		t.Errorf("sync printed:\n%s", out)
+	}
+	remote.LoadNotes()
+	for _, side := range []*NotesApp{app, remote} {
+		if side.noteIndex(3) >= 0 || side.noteIndex(4) < 0 {
+			t.Errorf("%s: erased #3 kept %v, revived #4 kept %v", side.NotesDir, side.noteIndex(3) >= 0, side.noteIndex(4) >= 0)
+		}
+	}
+	if got, _ := app.loadTombstones(); len(got) != 2 {
+		t.Errorf("this notebook learnt %d tombstones, want 2", len(got))
+	}
+	if i := app.noteIndex(2); i < 0 || !strings.HasPrefix(app.Notes[i].FilePath, app.imagesDir()) {
+		t.Error("the remote image scroll does not point at a local copy")
+	} else if _, err := os.Stat(app.Notes[i].FilePath); err != nil {
+		t.Errorf("the remote image was not copied: %v", err)
+	}
+	
+	// Changes since the last sync go across without a conflict.
+	i := app.noteIndex(1)
+	app.Notes[i].Content = "b"
+	app.Notes[i].UpdatedAt = time.Now().Add(time.Minute)
+	app.SaveNotes()
+	out = captureOutput(t, func() { app.Sync(nil, remote.NotesDir) })
+	if !strings.Contains(out, "1 scrolls sent, 0 received, 0 erased, 0 conflicts.") {
+		t.Errorf("the second sync printed:\n%s", out)
+	}
+}
+
+func TestAppendFillsInDateAndTime(t *testing.T) {
+	now := time.Date(2026, 9, 14, 8, 5, 0, 0, time.UTC)
+	if got, err := expandEntry("- {{date}} {{time}}: fed the cat", now); err != nil || got != "- 2026-09-14 08:05: fed the cat" {
+		t.Errorf("expandEntry = %q, %v", got, err)
+	}
+	if _, err := expandEntry("{{date", now); err == nil {
+		t.Error("an unclosed placeholder was accepted")
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Log", "start", nil)
+	none := bufio.NewReader(strings.NewReader(""))
+	today := time.Now().Format("2006-01-02")
+	captureOutput(t, func() {
+		app.Execute(none, []string{"update", "1", "--append", "--content", "{{date}} ran"})
+		app.Execute(none, []string{"append", "1", "at", "{{time}}"})
+	})
+	lines := strings.Split(app.Notes[0].Content, "\n")
+	if len(lines) != 3 || lines[1] != today+" ran" || len(lines[2]) != len("at 15:04") || strings.Contains(lines[2], "{{") {
+		t.Errorf("after appending, the scroll holds %q", app.Notes[0].Content)
+	}
+	
+	// Replacing the content keeps placeholders as they are.
+	captureOutput(t, func() { app.Execute(none, []string{"update", "1", "--content", "{{date}}"}) })
+	if app.Notes[0].Content != "{{date}}" {
+		t.Errorf("replacing the content gave %q", app.Notes[0].Content)
+	}
+	stderr := captureFile(t, &os.Stderr, func() { app.Execute(none, []string{"append", "1", "{{nope}}"}) })
+	if app.ExitCode != ExitInvalid || !strings.Contains(stderr, "Error in the appended text") || app.Notes[0].Content != "{{date}}" {
+		t.Errorf("an unknown placeholder gave exit %d, %q, content %q", app.ExitCode, stderr, app.Notes[0].Content)
+	}
+}
+
+func TestExplodeByHeading(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Week", "## Monday\nrest\n### Evening\nread\n\n## Tuesday\nwork\n## Wednesday\nswim", []string{"diary"})
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	captureOutput(t, func() { app.Execute(none, []string{"explode", "1", "--by-heading"}) })
+	if len(app.Notes) != 4 {
+		t.Fatalf("exploding left %d scrolls, want the original and three", len(app.Notes))
+	}
+	for n, want := range []Note{
+		{ID: 2, Title: "Monday", Content: "rest\n### Evening\nread"},
+		{ID: 3, Title: "Tuesday", Content: "work"},
+		{ID: 4, Title: "Wednesday", Content: "swim"},
+	} {
+		got := app.Notes[n+1]
+		if got.ID != want.ID || got.Title != want.Title || got.Content != want.Content || !reflect.DeepEqual(got.Tags, []string{"diary"}) {
+			t.Errorf("scroll %d is #%d %q %q %q, want #%d %q %q", n+1, got.ID, got.Title, got.Content, got.Tags, want.ID, want.Title, want.Content)
+		}
+	}
+	
+	// Text before the first heading keeps the title, and --delete erases
+	// the original.
+	app.CreateTextNote("Trip", "packing list\n# Day one\nfly\n# Day two\nhike", nil)
+	captureOutput(t, func() { app.Execute(none, []string{"explode", "5", "--delete"}) })
+	var titles []string
+	for _, note := range app.Notes[4:] {
+		titles = append(titles, note.Title)
+	}
+	if want := []string{"Trip", "Day one", "Day two"}; !reflect.DeepEqual(titles, want) || app.noteIndex(5) >= 0 {
+		t.Errorf("exploding with --delete left %q (original kept: %v)", titles, app.noteIndex(5) >= 0)
+	}
+}
+
+func TestRelocateImagesMovesFilesAndScrolls(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	work := NewNotesApp(app.Settings, "work")
+	addImage := func(book *NotesApp, name string) string {
+		path := filepath.Join(book.imagesDir(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		book.Notes = append(book.Notes, Note{ID: book.NextID, Title: name, Type: "screenshot", Screenshot: name, FilePath: path})
+		book.NextID++
+		book.SaveNotes()
+		return path
+	}
+	oldHome := addImage(app, "home.png")
+	oldWork := addImage(work, "work.png")
+	
+	root := filepath.Join(t.TempDir(), "big-disk")
+	out := captureOutput(t, func() { app.RelocateImages(root) })
+	if !strings.Contains(out, "Moved 2 captured images to "+root) {
+		t.Errorf("relocate-images printed %q", out)
+	}
+	for _, old := range []string{oldHome, oldWork} {
+		if _, err := os.Stat(old); !os.IsNotExist(err) {
+			t.Errorf("%s is still in its old place", old)
+		}
+	}
+	
+	for _, book := range []*NotesApp{NewNotesApp(app.Settings, DefaultNotebook), NewNotesApp(app.Settings, "work")} {
+		note := book.Notes[0]
+		if want := filepath.Join(notebookDir(root, book.Notebook), note.Screenshot); note.FilePath != want {
+			t.Errorf("%s: the scroll points at %s, want %s", book.Notebook, note.FilePath, want)
+		}
+		if data, err := ioutil.ReadFile(note.FilePath); err != nil || string(data) != note.Screenshot {
+			t.Errorf("%s: the moved image reads %q, %v", book.Notebook, data, err)
+		}
+	}
+	
+	settings, _ := LoadSettings(settingsPath())
+	if settings.ImagesDir != root || app.imagesDir() != notebookDir(root, DefaultNotebook) {
+		t.Errorf("the images root is recorded as %q, and new images go to %s", settings.ImagesDir, app.imagesDir())
+	}
+}
+
+func TestRemindersFireOncePerDueScroll(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time { due := now.Add(d); return &due }
+	app := newTestApp(t, StorageJSON)
+	app.Notes = []Note{
+		{ID: 1, Title: "Overdue", Type: "text", Content: "pay the rent", DueAt: at(-time.Hour)},
+		{ID: 2, Title: "Later", Type: "text", DueAt: at(time.Hour)},
+		{ID: 3, Title: "Undated", Type: "text"},
+		{ID: 4, Title: "Done", Type: "text", DueAt: at(-2 * time.Hour), Notified: true},
+		{ID: 5, Title: "Flaky", Type: "text", DueAt: at(-time.Minute)},
+	}
+	if got := app.dueNotes(now); !reflect.DeepEqual(got, []int{0, 4}) {
+		t.Errorf("dueNotes(now) = %v, want [0 4]", got)
+	}
+	if got := app.dueNotes(now.Add(2 * time.Hour)); !reflect.DeepEqual(got, []int{0, 1, 4}) {
+		t.Errorf("dueNotes(now+2h) = %v, want [0 1 4]", got)
+	}
+	
+	saved := notify
+	defer func() { notify = saved }()
+	var fired []string
+	fail := true
+	notify = func(title, body string) error {
+		if strings.Contains(title, "Flaky") && fail {
+			return fmt.Errorf("no notification daemon")
+		}
+		fired = append(fired, title+": "+body)
+		return nil
+	}
+	
+	out := captureOutput(t, app.CheckReminders)
+	if want := []string{"Scroll #1 is due: Overdue: pay the rent"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("the first check sent %q, want %q", fired, want)
+	}
+	if !strings.Contains(out, "Could not send the reminder for scroll #5") || app.Notes[4].Notified {
+		t.Errorf("a failed reminder was not left to retry:\n%s", out)
+	}
+	
+	// Only the reminder that failed is sent again.
+	fired, fail = nil, false
+	captureOutput(t, app.CheckReminders)
+	if want := []string{"Scroll #5 is due: Flaky: "}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("the second check sent %q, want %q", fired, want)
+	}
+	fired = nil
+	captureOutput(t, app.CheckReminders)
+	if len(fired) != 0 {
+		t.Errorf("the third check sent %q", fired)
+	}
+	notes, _, _ := app.store.Load()
+	if !notes[0].Notified || !notes[4].Notified {
+		t.Error("the fired reminders were not saved")
+	}
+	
+	// Setting a new due time rearms the reminder.
+	captureOutput(t, func() { app.SetDue(1, at(-time.Second)) })
+	captureOutput(t, app.CheckReminders)
+	if len(fired) != 1 || !strings.Contains(fired[0], "Overdue") {
+		t.Errorf("after a new due time, the check sent %q", fired)
+	}
+	
+	for goos, want := range map[string]string{"darwin": "osascript", "linux": "notify-send", "windows": "powershell"} {
+		if cmd := notifyCommand(goos, "t", "b"); cmd == nil || cmd.Args[0] != want {
+			t.Errorf("notifyCommand(%q) = %v, want %s", goos, cmd, want)
+		}
+	}
+	if cmd := notifyCommand("plan9", "t", "b"); cmd != nil {
+		t.Errorf("plan9 got a notify command %q", cmd.Args)
+	}
+}
+
+func TestListWithPreviewShowsTwoLines(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Poem", "# First line\n\n**second** line\nthird line", nil)
+	app.CreateTextNote("Long", strings.Repeat("ö", 120)+"\nshort", nil)
+	app.CreateTextNote("Empty", "", nil)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	out := captureOutput(t, func() { app.Execute(none, []string{"list", "--with-preview"}) })
+	for _, want := range []string{
+		"  | First line\n  | second line\n---",
+		"  | " + strings.Repeat("ö", 100) + "...\n  | short\n---",
+		"(text)\nCreated: ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the listing lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "third line") || strings.Contains(out, "Preview:") {
+		t.Errorf("the listing shows more than two lines, or the single preview:\n%s", out)
+	}
+	if strings.Count(out, "  | ") != 4 {
+		t.Errorf("the listing has %d preview lines, want 4 (none for the empty scroll):\n%s", strings.Count(out, "  | "), out)
+	}
+	
+	// Without the flag each scroll keeps its one-line preview.
+	out = captureOutput(t, func() { app.Execute(none, []string{"list"}) })
+	if strings.Contains(out, "  | ") || !strings.Contains(out, "Preview: First line second line third line") {
+		t.Errorf("the plain listing:\n%s", out)
+	}
+}
+
+func TestTagsAreStoredSorted(t *testing.T) {
+	for _, storage := range []string{StorageJSON, StorageMarkdown} {
+		app := newTestApp(t, storage)
+		app.CreateTextNote("One", "x", nil)
+		app.CreateTextNote("Two", "x", nil)
+		app.Notes[0].Tags = []string{"zeta", "Alpha", "beta", "alpha"}
+		app.SaveNotes()
+		none := bufio.NewReader(strings.NewReader(""))
+		captureOutput(t, func() { app.Execute(none, []string{"update", "2", "--tags", "Work,urgent,api"}) })
+		
+		notes, _, err := app.store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+		if want := []string{"Alpha", "alpha", "beta", "zeta"}; !reflect.DeepEqual(notes[0].Tags, want) {
+			t.Errorf("%s: set directly, the runes were stored as %q, want %q", storage, notes[0].Tags, want)
+		}
+		if want := []string{"api", "urgent", "Work"}; !reflect.DeepEqual(notes[1].Tags, want) {
+			t.Errorf("%s: through update, the runes were stored as %q, want %q", storage, notes[1].Tags, want)
+		}
+	}
+	
+	// The same set entered in any order is written the same way.
+	a, b := []string{"b", "C", "a"}, []string{"C", "a", "b"}
+	sortTags(a)
+	sortTags(b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("sortTags gave %q and %q for the same runes", a, b)
+	}
+}
+
+func TestSimilarRanksTheNearDuplicateFirst(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Sourdough", "Feed the starter with flour and water, then bake the loaf at 250 degrees.", nil)
+	app.CreateTextNote("Bread", "Bake the loaf at 220 degrees.", nil)
+	app.CreateTextNote("Sourdough again", "Feed the STARTER with flour and water; bake the loaf at 250 degrees!", nil)
+	app.CreateTextNote("Taxes", "File the return by April.", nil)
+	app.CreateTextNote("Zzz", "quux", nil)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	out := captureOutput(t, func() { app.Execute(none, []string{"similar", "1", "--top", "2"}) })
+	first, second := strings.Index(out, "1) [3] Sourdough again"), strings.Index(out, "2) [2] Bread")
+	if first < 0 || second < first {
+		t.Errorf("similar ranked:\n%s", out)
+	}
+	if strings.Contains(out, "Taxes") || !reflect.DeepEqual(app.LastResults, []int{3, 2}) {
+		t.Errorf("similar --top 2 listed %v:\n%s", app.LastResults, out)
+	}
+	
+	out = captureOutput(t, func() { app.Execute(none, []string{"similar", "5"}) })
+	if !strings.Contains(out, "No scroll shares any words with scroll #5.") {
+		t.Errorf("a scroll sharing no words printed %q", out)
+	}
+	
+	a := map[string]float64{"bake": 2, "loaf": 1}
+	if got := cosineSimilarity(a, a); math.Abs(got-1) > 1e-9 {
+		t.Errorf("a vector's similarity with itself is %v", got)
+	}
+	if got := cosineSimilarity(a, map[string]float64{"tax": 1}); got != 0 {
+		t.Errorf("vectors with no words in common score %v", got)
+	}
+	if got := cosineSimilarity(a, nil); got != 0 {
+		t.Errorf("an empty vector scores %v", got)
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	now := time.Date(2026, 9, 14, 12, 0, 0, 0, time.Local)
+	for input, want := range map[string]time.Time{
+		"2019-05-06":           time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local),
+		"2019-05-06 07:08":     time.Date(2019, 5, 6, 7, 8, 0, 0, time.Local),
+		"2019-05-06 07:08:09":  time.Date(2019, 5, 6, 7, 8, 9, 0, time.Local),
+		"2019-05-06T07:08:09Z": time.Date(2019, 5, 6, 7, 8, 9, 0, time.UTC),
+	} {
+		if got, err := parseImportDate(input, now); err != nil || !got.Equal(want) {
+			t.Errorf("parseImportDate(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"2026-09-15", "yesterday", "06/05/2019", "2019-13-01"} {
+		if got, err := parseImportDate(input, now); err == nil {
+			t.Errorf("parseImportDate(%q) = %v, want an error", input, got)
+		}
+	}
+	
+	// A date that cannot be used leaves the scroll dated today.
+	app := newTestApp(t, StorageJSON)
+	path := filepath.Join(t.TempDir(), "odd.txt")
+	if err := ioutil.WriteFile(path, []byte("Odd note\nCreated: someday\n\nbody\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	out := captureOutput(t, func() { app.ImportText(path) })
+	if !strings.Contains(out, `Warning: unrecognised date "someday"`) || app.Notes[0].CreatedAt.Before(before) {
+		t.Errorf("importing an unreadable date gave %v and printed %q", app.Notes[0].CreatedAt, out)
+	}
+}
+
+func TestBiggestOrdersBySize(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "board.png")
+	if err := ioutil.WriteFile(image, bytes.Repeat([]byte("x"), 3000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Short", "tiny", nil)
+	app.CreateTextNote("Long", strings.Repeat("words ", 100), nil)
+	app.CreateTextNote("Middle", strings.Repeat("w", 50), nil)
+	app.Notes = append(app.Notes,
+		Note{ID: 4, Title: "Board", Type: "screenshot", FilePath: image},
+		Note{ID: 5, Title: "Lost", Type: "screenshot", FilePath: image + ".gone"},
+	)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	out := captureOutput(t, func() { app.Execute(none, []string{"biggest", "4"}) })
+	want := "1) [4] Board (screenshot, 2.9 KB)\n" +
+		"2) [2] Long (text, 600 bytes)\n" +
+		"3) [3] Middle (text, 50 bytes)\n" +
+		"4) [1] Short (text, 4 bytes)\n"
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("biggest 4 printed:\n%s\nwant it to end:\n%s", out, want)
+	}
+	if !reflect.DeepEqual(app.LastResults, []int{4, 2, 3, 1}) {
+		t.Errorf("the numbered results are %v", app.LastResults)
+	}
+	
+	out = captureOutput(t, func() { app.Execute(none, []string{"biggest"}) })
+	if !strings.Contains(out, "5) [5] Lost (screenshot, image missing)") {
+		t.Errorf("a missing image is not shown last:\n%s", out)
+	}
+}
+
+func TestCaptureWithoutAToolOffersAlternatives(t *testing.T) {
+	saved := lookPath
+	defer func() { lookPath = saved }()
+	lookPath = func(name string) (string, error) { return "", fmt.Errorf("%s: %w", name, exec.ErrNotFound) }
+	
+	src := filepath.Join(t.TempDir(), "board.png")
+	if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\nimage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, StorageJSON)
+	capture := func(input string) (string, string) {
+		var stdout, stderr string
+		stdout = captureOutput(t, func() {
+			stderr = captureFile(t, &os.Stderr, func() {
+				app.Execute(bufio.NewReader(strings.NewReader(input)), []string{"capture"})
+			})
+		})
+		return stdout, stderr
+	}
+	
+	out, stderr := capture("3\n")
+	if !strings.Contains(out, "1. Inscribe a text scroll instead") || !strings.Contains(out, "No scroll was made.") || len(app.Notes) != 0 {
+		t.Errorf("declining the offer printed:\n%s", out)
+	}
+	if stderr != "" || app.ExitCode != ExitOK {
+		t.Errorf("declining the offer failed with exit %d: %q", app.ExitCode, stderr)
+	}
+	if len(platformScreenshotTools()) > 0 && !strings.Contains(out, "No capture tool is installed (looked for ") {
+		t.Errorf("the offer does not say what was looked for:\n%s", out)
+	}
+	
+	out, stderr = capture("2\n" + src + "\nBoard\nwork\n")
+	if len(app.Notes) != 1 || stderr != "" {
+		t.Fatalf("adding an image file made %d scrolls: %s%s", len(app.Notes), out, stderr)
+	}
+	note := app.Notes[0]
+	if note.Type != "screenshot" || note.Title != "Board" || !reflect.DeepEqual(note.Tags, []string{"work"}) || !strings.HasPrefix(note.FilePath, app.imagesDir()) {
+		t.Errorf("the added image scroll is %+v", note)
+	}
+	if _, err := os.Stat(note.FilePath); err != nil {
+		t.Errorf("the image file was not copied in: %v", err)
+	}
+	
+	_, stderr = capture("2\n" + src + ".gone\n")
+	if app.ExitCode != ExitNotFound || !strings.Contains(stderr, "No image file at ") || len(app.Notes) != 1 {
+		t.Errorf("a missing image file gave exit %d, %q", app.ExitCode, stderr)
+	}
+}
+
+func TestRecapturePassesTheStoredRegion(t *testing.T) {
+	for _, c := range []struct {
+		tool, region string
+		want         []string
+	}{
+		{"maim", "640x480+10+20", []string{"maim", "-g", "640x480+10+20", "shot.png"}},
+		{"scrot", "640x480+10+20", []string{"scrot", "-a", "10,20,640,480", "shot.png"}},
+		{"screencapture", "640x480+-5+20", []string{"screencapture", "-R", "-5,20,640,480", "shot.png"}},
+		{"gnome-screenshot", "640x480+10+20", nil},
+		{"maim", "the left half", nil},
+	} {
+		cmd := regionCommand(c.tool, "shot.png", c.region)
+		if (cmd == nil) != (c.want == nil) || (cmd != nil && !reflect.DeepEqual(cmd.Args, c.want)) {
+			t.Errorf("regionCommand(%q, %q) = %v, want %q", c.tool, c.region, cmd, c.want)
+		}
+	}
+	
+	savedLook, savedSelect := lookPath, selectRegion
+	defer func() { lookPath, selectRegion = savedLook, savedSelect }()
+	lookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	selected := 0
+	selectRegion = func() (string, error) {
+		selected++
+		return "100x50+1+2", nil
+	}
+	app := newTestApp(t, StorageJSON)
+	
+	// A stored region is captured again without asking for a selection.
+	app.Settings.ScreenshotTool = "maim"
+	app.detectScreenshotTool()
+	cmd, region, err := app.captureCommand("shot.png", "640x480+10+20")
+	if err != nil || region != "640x480+10+20" || !reflect.DeepEqual(cmd.Args, []string{"maim", "-g", "640x480+10+20", "shot.png"}) || selected != 0 {
+		t.Errorf("recapturing a stored region gave %v, %q, %v (selected %d times)", cmd, region, err, selected)
+	}
+	
+	// Without one, the selection is remembered.
+	cmd, region, err = app.captureCommand("shot.png", "")
+	if err != nil || region != "100x50+1+2" || cmd.Args[2] != "100x50+1+2" || selected != 1 {
+		t.Errorf("a new capture gave %v, %q, %v", cmd, region, err)
+	}
+	
+	// A tool that takes no region falls back to its own selection.
+	app.Settings.ScreenshotTool = "gnome-screenshot"
+	app.detectScreenshotTool()
+	out := captureOutput(t, func() { cmd, region, err = app.captureCommand("shot.png", "640x480+10+20") })
+	if err != nil || region != "" || cmd.Args[0] != "gnome-screenshot" || !strings.Contains(out, "gnome-screenshot cannot capture a given area") {
+		t.Errorf("gnome-screenshot gave %v, %q, %v and printed %q", cmd, region, err, out)
+	}
+}
+
+func TestBoostLiftsAContentMatchAboveATitleMatch(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Lighthouse", "tall and white", nil)
+	app.CreateTextNote("Reference", "see the lighthouse log", nil)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	seek := func() string {
+		return captureOutput(t, func() { app.Execute(none, []string{"seek", "--output", "ids", "lighthouse"}) })
+	}
+	if got := seek(); got != "1\n2\n" {
+		t.Fatalf("without a boost, seek ranked %q, want the title match first", got)
+	}
+	
+	captureOutput(t, func() { app.Execute(none, []string{"boost", "2", "10"}) })
+	if got := seek(); got != "2\n1\n" {
+		t.Errorf("with a boost of 10, seek ranked %q, want the boosted content match first", got)
+	}
+	notes, _, _ := app.store.Load()
+	if notes[1].SearchBoost != 10 {
+		t.Errorf("the boost was saved as %d", notes[1].SearchBoost)
+	}
+	
+	// A boost alone does not make a scroll match.
+	app.CreateTextNote("Unrelated", "x", nil)
+	captureOutput(t, func() { app.Execute(none, []string{"boost", "3", "100"}) })
+	if got := seek(); got != "2\n1\n" {
+		t.Errorf("a boosted scroll without the word was found: %q", got)
+	}
+	
+	captureOutput(t, func() { app.Execute(none, []string{"boost", "2", "none"}) })
+	if got := seek(); got != "1\n2\n" {
+		t.Errorf("after clearing the boost, seek ranked %q", got)
+	}
+}
+
+func TestExportWithBacklinks(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("Glossary", "terms", []string{"ref"})
+	app.CreateTextNote("Essay", "as defined in [[#1]], and again [[#1]]", nil)
+	app.CreateTextNote("Other", "see [[#10]] and [[#12]]", nil)
+	app.CreateTextNote("Notes", "from [[#1]]", nil)
+	
+	none := bufio.NewReader(strings.NewReader(""))
+	out := captureOutput(t, func() { app.Execute(none, []string{"export", "1", "--with-backlinks"}) })
+	want := "# Glossary\n\nTags: ref\n\nterms\n" +
+		"\n## Referenced by\n\n" +
+		"- [[#2]] Essay\n" +
+		"- [[#4]] Notes\n"
+	if out != want {
+		t.Errorf("export --with-backlinks printed:\n%q\nwant:\n%q", out, want)
+	}
+	
+	path := filepath.Join(t.TempDir(), "essay.md")
+	captureOutput(t, func() { app.Execute(none, []string{"export", "2", "--with-backlinks", "--out", path}) })
+	if data, err := ioutil.ReadFile(path); err != nil || strings.Contains(string(data), "Referenced by") {
+		t.Errorf("a scroll nobody links to was exported as %q (%v)", data, err)
+	}
+	captureOutput(t, func() { app.Execute(none, []string{"export", "1", "--out", path}) })
+	if data, _ := ioutil.ReadFile(path); strings.Contains(string(data), "Referenced by") {
+		t.Error("export without --with-backlinks listed backlinks")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	for _, c := range []struct {
+		file string
+		want []string
+	}{
+		{`{"date_format": "2006-01-02", "daily_word_goal": 500, "storage": "json"}`, nil},
+		{`{"daily_word_goal": "lots", "colour": "red"}`, []string{`colour: unknown setting`, `daily_word_goal: expected int, found "lots"`}},
+		{`{"storage": "sqlite", "max_pinned": -1}`, []string{`max_pinned: -1 is negative`, `storage: "sqlite" is neither json nor markdown`}},
+		{`{"storage": `, []string{"the file is not valid JSON: unexpected end of JSON input"}},
+	} {
+		got := checkSettingsFile([]byte(c.file))
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("checkSettingsFile(%s) = %q, want %q", c.file, got, c.want)
+		}
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	write := func(text string) {
+		if err := os.MkdirAll(filepath.Dir(settingsPath()), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(settingsPath(), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	write(`{"pin_overflow": "shuffle"}`)
+	var stderr string
+	out := captureOutput(t, func() { stderr = captureFile(t, &os.Stderr, app.ValidateConfig) })
+	if app.ExitCode != ExitInvalid || !strings.Contains(out, `pin_overflow: "shuffle" is neither refuse nor evict`) || !strings.Contains(stderr, "has 1 problems") {
+		t.Errorf("an invalid settings file gave exit %d:\n%s%s", app.ExitCode, out, stderr)
+	}
+	
+	app.ExitCode = ExitOK
+	write(`{"pin_overflow": "evict"}`)
+	out = captureOutput(t, app.ValidateConfig)
+	if app.ExitCode != ExitOK || !strings.Contains(out, settingsPath()+" is valid.") || !strings.Contains(out, "Settings in effect:") {
+		t.Errorf("a valid settings file gave exit %d:\n%s", app.ExitCode, out)
+	}
+}
+
+func TestParallelMarkdownLoadMatchesSerial(t *testing.T) {
+	store := markdownStore{dir: t.TempDir()}
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var notes []Note
+	for id := 1; id <= 300; id++ {
+		notes = append(notes, Note{
+			ID:        id,
+			Title:     fmt.Sprintf("Scroll %d", id),
+			Content:   strings.Repeat(fmt.Sprintf("line %d\n", id), id%7+1),
+			Tags:      []string{fmt.Sprintf("t%d", id%5)},
+			CreatedAt: created.Add(time.Duration(id) * time.Minute),
+			UpdatedAt: created.Add(time.Duration(id) * time.Hour),
+			Type:      "text",
+		})
+	}
+	if err := store.Save(notes, 400); err != nil {
+		t.Fatal(err)
+	}
+	
+	// The serial reading, file by file in the directory's order.
+	entries, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serial []Note
+	for _, entry := range entries {
+		if markdownScrollFile.MatchString(entry.Name()) {
+			note, err := store.loadFile(entry.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			serial = append(serial, note)
+		}
+	}
+	
+	for run := 0; run < 3; run++ {
+		parallel, nextID, err := store.Load()
+		if err != nil || nextID != 400 {
+			t.Fatalf("Load gave next ID %d, %v", nextID, err)
+		}
+		if !reflect.DeepEqual(parallel, serial) {
+			t.Fatalf("run %d: the parallel load differs from the serial one", run)
+		}
+	}
+	if len(serial) != len(notes) {
+		t.Errorf("loaded %d scrolls, want %d", len(serial), len(notes))
+	}
+	
+	// A broken file among many is still reported.
+	if err := ioutil.WriteFile(filepath.Join(store.dir, entries[150].Name()), []byte("---\nid: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Load(); err == nil || !strings.Contains(err.Error(), entries[150].Name()) {
+		t.Errorf("a broken scroll file gave %v", err)
+	}
+}
+
+func TestIndexedSearchMatchesALinearScan(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	words := []string{"dragon", "gold", "silver", "lighthouse", "Ünïcode", "keeper", "tide"}
+	for i := 0; i < 60; i++ {
+		var content []string
+		for j, word := range words {
+			if (i>>uint(j))&1 == 1 {
+				content = append(content, word)
+			}
+		}
+		app.CreateTextNote(fmt.Sprintf("Scroll %d %s", i, words[i%len(words)]), strings.Join(content, " "), []string{words[(i+3)%len(words)]})
+	}
+	all := SearchOptions{Scope: SearchScope{Title: true, Content: true, Tags: true}}
+	
+	linear := func(query string) []int {
+		expr, m, err := compileSearch(query, SearchPlain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, note := range app.Notes {
+			text := note.Title + "\n" + note.Content + "\n" + strings.Join(note.Tags, "\n")
+			if expr.match(func(term string) bool { return m.contains(text, term) }) {
+				ids = append(ids, note.ID)
+			}
+		}
+		return ids
+	}
+	indexed := func(query string) []int {
+		found, err := app.Search(query, all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, note := range found {
+			ids = append(ids, note.ID)
+		}
+		sort.Ints(ids)
+		return ids
+	}
+	for _, query := range []string{"dragon", "GOLD", "ragon", "unicode", "light house", "dragon AND silver", "tide OR keeper", "gold AND NOT silver", "zebra"} {
+		if got, want := indexed(query), linear(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("seek %q found %v, a linear scan %v", query, got, want)
+		}
+	}
+	
+	// The index follows a changed scroll.
+	before, err := ioutil.ReadFile(app.searchIndexPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "a kraken appears"
+	captureOutput(t, func() { app.UpdateNote(1, noteUpdate{Content: &content}) })
+	after, _ := ioutil.ReadFile(app.searchIndexPath())
+	if bytes.Equal(before, after) {
+		t.Error("saving a change left the search index as it was")
+	}
+	if got := indexed("kraken"); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("seek kraken found %v after the change, want [1]", got)
+	}
+}
+
+func TestStreamingExportMatchesInMemory(t *testing.T) {
+	for _, storage := range []string{StorageJSON, StorageMarkdown} {
+		app := newTestApp(t, storage)
+		app.Settings.DateFormat = "2006-01-02"
+		app.CreateTextNote("First", "# Heading\nsecond line", []string{"b", "a"})
+		app.CreateTextNote("Second", "", nil)
+		app.Notes = append(app.Notes, Note{ID: 3, Title: "Board", Type: "screenshot", Screenshot: "board.png", FilePath: "/images/board.png"})
+		for i := 4; i <= 12; i++ {
+			app.Notes = append(app.Notes, Note{ID: i, Title: fmt.Sprintf("Scroll %d", i), Type: "text", Content: strings.Repeat("ü", i)})
+		}
+		app.SaveNotes()
+		
+		dir := t.TempDir()
+		inMemory, streamed := filepath.Join(dir, "memory.txt"), filepath.Join(dir, "stream.txt")
+		none := bufio.NewReader(strings.NewReader(""))
+		captureOutput(t, func() {
+			app.Execute(none, []string{"export-txt", inMemory})
+			app.Execute(none, []string{"export-txt", "--stream", streamed})
+		})
+		a, errA := ioutil.ReadFile(inMemory)
+		b, errB := ioutil.ReadFile(streamed)
+		if errA != nil || errB != nil {
+			t.Fatalf("%s: %v, %v", storage, errA, errB)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s: the streamed export differs:\n%s\nfrom the one in memory:\n%s", storage, b, a)
+		}
+		if !strings.Contains(string(a), ", 12 scrolls\n") {
+			t.Errorf("%s: the export does not count 12 scrolls:\n%s", storage, a)
+		}
+	}
+	
+	// An empty archive streams the same header and nothing else.
+	app := newTestApp(t, StorageJSON)
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	captureOutput(t, func() { app.StreamPlainText(path) })
+	if data, _ := ioutil.ReadFile(path); string(data) != app.plainTextExport(nil) {
+		t.Errorf("streaming an empty archive wrote %q", data)
+	}
+}
+
+func TestMultilineContentSurvivesInscribeAndModify(t *testing.T) {
+	for input, want := range map[string]string{
+		"one\ntwo\n.\nafter\n":  "one\ntwo",
+		"one\n\nthree\n.\n":     "one\n\nthree",
+		"one\r\ntwo\r\n.\r\n":   "one\ntwo",
+		"one\ntwo":              "one\ntwo",
+		"\n":                    "",
+		".\n":                   "",
+		"  indented\n\n\n.\n":   "  indented",
+		"ends at EOF\nno dot\n": "ends at EOF\nno dot",
+	} {
+		if got := readMultiline(bufio.NewReader(strings.NewReader(input))); got != want {
+			t.Errorf("readMultiline(%q) = %q, want %q", input, got, want)
+		}
+	}
+	
+	app := newTestApp(t, StorageJSON)
+	body := "First line\n\n  indented third\n" + strings.Repeat("long ", 30)
+	reader := bufio.NewReader(strings.NewReader("Poem\n" + body + "\n.\nverse\n"))
+	captureOutput(t, func() { app.Execute(reader, []string{"inscribe"}) })
+	if len(app.Notes) != 1 || app.Notes[0].Content != body {
+		t.Fatalf("inscribing stored %+v", app.Notes)
+	}
+	if !reflect.DeepEqual(app.Notes[0].Tags, []string{"verse"}) {
+		t.Errorf("the runes after the content were read as %q", app.Notes[0].Tags)
+	}
+	
+	out := captureOutput(t, func() { app.ViewNote(1) })
+	if !strings.Contains(out, "First line\n\n  indented third\n") {
+		t.Errorf("view does not print the body intact:\n%s", out)
+	}
+	out = captureOutput(t, func() { app.ListNotes(ListOptions{Format: OutputTable}) })
+	preview := out[strings.Index(out, "Preview: ")+len("Preview: "):]
+	preview = preview[:strings.Index(preview, "\n")]
+	if !strings.HasPrefix(preview, "First line indented third long") || len([]rune(preview)) != 103 {
+		t.Errorf("the one-line preview is %q", preview)
+	}
+	
+	saved := stdin
+	stdin = bufio.NewReader(strings.NewReader("\nnew one\nnew two\n.\n\n"))
+	defer func() { stdin = saved }()
+	captureOutput(t, func() { app.EditScroll(1) })
+	if note := app.Notes[0]; note.Title != "Poem" || note.Content != "new one\nnew two" || !reflect.DeepEqual(note.Tags, []string{"verse"}) {
+		t.Errorf("modifying gave %q %q %q", note.Title, note.Content, note.Tags)
+	}
+}
+
+func TestExportMarkdownIsSelfContained(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.Settings.DateFormat = "2006-01-02"
+	src := filepath.Join(t.TempDir(), "scroll_20260101_1.png")
+	if err := ioutil.WriteFile(src, []byte("\x89PNG\r\n\x1a\nimage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.CreateTextNote("Trip Plan", "day one\nday two", []string{"travel", "q3"})
+	app.Notes = append(app.Notes, Note{ID: 2, Title: "Board", Type: "screenshot", Screenshot: filepath.Base(src), FilePath: src, CreatedAt: app.Notes[0].CreatedAt, UpdatedAt: app.Notes[0].UpdatedAt})
+	day := app.Notes[0].CreatedAt.Format("2006-01-02")
+	
+	dest := filepath.Join(t.TempDir(), "out")
+	reader := bufio.NewReader(strings.NewReader("1\n" + dest + "\n"))
+	captureOutput(t, func() {
+		app.Execute(reader, []string{"export"})
+		app.ExportMarkdown(2, dest)
+	})
+	
+	text, err := ioutil.ReadFile(filepath.Join(dest, "1-trip-plan.md"))
+	want := "# Trip Plan\n\nCreated: " + day + "  \nUpdated: " + day + "  \nTags: q3, travel\n\nday one\nday two\n"
+	if err != nil || string(text) != want {
+		t.Errorf("the text scroll was exported as %q (%v), want %q", text, err, want)
+	}
+	image, err := ioutil.ReadFile(filepath.Join(dest, "2-board.md"))
+	if err != nil || !strings.HasSuffix(string(image), "\n![Board](scroll_20260101_1.png)\n") {
+		t.Errorf("the image scroll was exported as %q (%v)", image, err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dest, "scroll_20260101_1.png")); err != nil || !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		t.Errorf("the image was not copied beside the export: %v", err)
+	}
+	
+	stderr := captureFile(t, &os.Stderr, func() { app.ExportMarkdown(9, dest) })
+	if app.ExitCode != ExitNotFound || !strings.Contains(stderr, "Scroll with ID 9 not found") {
+		t.Errorf("exporting a missing scroll gave exit %d, %q", app.ExitCode, stderr)
+	}
+}
+
+func TestExportAllMarkdownNewestFirstWithContents(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 9, day, 12, 0, 0, 0, time.UTC) }
+	src := filepath.Join(t.TempDir(), "board.png")
+	if err := ioutil.WriteFile(src, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, StorageJSON)
+	app.Notes = []Note{
+		{ID: 1, Title: "Oldest", Type: "text", Content: "first", CreatedAt: at(1), UpdatedAt: at(1)},
+		{ID: 2, Title: "Board", Type: "screenshot", Screenshot: "board.png", FilePath: src, CreatedAt: at(3), UpdatedAt: at(3)},
+		{ID: 3, Title: "Middle", Type: "text", Content: "second", CreatedAt: at(2), UpdatedAt: at(2)},
+	}
+	
+	path := filepath.Join(t.TempDir(), "all.md")
+	out := captureOutput(t, func() { app.Execute(bufio.NewReader(strings.NewReader("")), []string{"export-md", path}) })
+	if !strings.Contains(out, "Exported 3 scrolls as Markdown to "+path+", with 1 images in ") {
+		t.Errorf("export-md printed %q", out)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	
+	contents := "## Contents\n\n- [#2 Board](#scroll-2)\n- [#3 Middle](#scroll-3)\n- [#1 Oldest](#scroll-1)\n"
+	if !strings.Contains(text, contents) {
+		t.Errorf("the contents are not newest first:\n%s", text)
+	}
+	last := -1
+	for _, id := range []int{2, 3, 1} {
+		heading := fmt.Sprintf("\n---\n\n<a id=\"scroll-%d\"></a>\n\n## #%d ", id, id)
+		i := strings.Index(text, heading)
+		if i < 0 || i < last {
+			t.Errorf("scroll #%d is missing or out of order:\n%s", id, text)
+		}
+		last = i
+	}
+	if !strings.Contains(text, "![Board](images/board.png)") {
+		t.Errorf("the image is not referenced by its relative path:\n%s", text)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "images", "board.png")); err != nil {
+		t.Errorf("the image was not copied into images/: %v", err)
+	}
+}
+
+func TestScriptedOutputIsPlainJSON(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, "archives")
+	if _, _, code := runMain(t, home, "Kept\nx\n.\n\n", "--notes-dir", dir, "inscribe"); code != ExitOK {
+		t.Fatalf("inscribing exited with %d", code)
+	}
+	
+	stdout, stderr, code := runMain(t, home, "", "--notes-dir", dir, "--json", "view", "1")
+	var note Note
+	if code != ExitOK || stderr != "" {
+		t.Fatalf("view exited with %d and wrote %q to stderr", code, stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), &note); err != nil || note.ID != 1 || note.Title != "Kept" {
+		t.Fatalf("view printed %q, want the scroll as JSON (%v)", stdout, err)
+	}
+	
+	stdout, _, _ = runMain(t, home, "", "--notes-dir", dir, "--json", "list")
+	var notes []Note
+	if err := json.Unmarshal([]byte(stdout), &notes); err != nil || len(notes) != 1 || notes[0].Read {
+		t.Fatalf("list printed %q, want one unread scroll as JSON (%v)", stdout, err)
+	}
+	
+	t.Setenv("SKELOS_OUTPUT", "json")
+	stdout, _, _ = runMain(t, home, "", "--notes-dir", dir, "list")
+	if err := json.Unmarshal([]byte(stdout), &notes); err != nil || len(notes) != 1 {
+		t.Fatalf("list under SKELOS_OUTPUT=json printed %q (%v)", stdout, err)
+	}
+	stdout, _, _ = runMain(t, home, "", "--notes-dir", dir, "--output", "ids", "list")
+	if strings.TrimSpace(stdout) != "1" {
+		t.Errorf("--output ids under SKELOS_OUTPUT=json printed %q, want the flag to win", stdout)
+	}
+	
+	_, stderr, code = runMain(t, home, "", "--notes-dir", dir, "--json", "view", "9")
+	if code != ExitNotFound || !strings.Contains(stderr, "not found") {
+		t.Errorf("viewing a missing scroll as JSON exited with %d and wrote %q", code, stderr)
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	app := newTestApp(t, StorageJSON)
+	app.CreateTextNote("TODO fix the gate", "before 2024-03-01", []string{"chores"})
+	app.CreateTextNote("Remember the todo list", "someday", nil)
+	app.CreateTextNote("Journal", "nothing to do", []string{"TODO"})
+	
+	scope := SearchScope{Title: true, Content: true, Tags: true}
+	for _, c := range []struct {
+		mode, query string
+		want        []int
+	}{
+		{SearchPlain, "todo", []int{1, 2, 3}},
+		{SearchCaseSensitive, "TODO", []int{1, 3}},
+		{SearchCaseSensitive, "todo AND NOT TODO", []int{2}},
+		{SearchRegex, `^TODO`, []int{1, 3}},
+		{SearchRegex, `\d{4}-\d{2}-\d{2}`, []int{1}},
+	} {
+		notes, err := app.Search(c.query, SearchOptions{Scope: scope, Mode: c.mode})
+		if err != nil {
+			t.Fatalf("%s %q: %v", c.mode, c.query, err)
+		}
+		var ids []int
+		for _, note := range notes {
+			ids = append(ids, note.ID)
+		}
+		sort.Ints(ids)
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("%s %q found %v, want %v", c.mode, c.query, ids, c.want)
+		}
+	}
+	
+	regex := SearchOptions{Scope: scope, Mode: SearchRegex}
+	if _, err := app.Search("(unclosed", regex); err == nil || !strings.Contains(err.Error(), "invalid regular expression") {
+		t.Errorf("an invalid pattern gave %v", err)
+	}
+	errOut := captureFile(t, &os.Stderr, func() { app.SearchNotes("(unclosed", regex, ListOptions{Format: OutputTable}) })
+	if !strings.Contains(errOut, "Error in query: invalid regular expression") {
+		t.Errorf("an invalid pattern reported %q", errOut)
+	}
+	
+	out := captureOutput(t, func() { app.SearchNotes("^TODO", regex, ListOptions{Format: OutputTable}) })
+	for _, want := range []string{"[1] TODO fix the gate (text)\nMatched in: title\n", "[3] Journal (text)\nMatched in: runes\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("results do not say where the scroll matched, want %q in:\n%s", want, out)
+		}
+	}
+}